@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseCIDRList parses comma-separated CIDRs (IPv4 or IPv6); bare IPs are treated as single hosts
+func parseCIDRList(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", item)
+			}
+			if ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+		_, ipnet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", item)
+		}
+		nets = append(nets, ipnet)
+	}
+	return nets, nil
+}
+
+// clientAllowed reports whether the client IP is inside one of the -allow-cidr ranges
+// (all clients are allowed when no ranges are configured)
+func clientAllowed(r *http.Request) bool {
+	if len(allowedCIDRs) == 0 {
+		return true
+	}
+	ip := net.ParseIP(clientIP(r))
+	if ip == nil {
+		return false
+	}
+	for _, ipnet := range allowedCIDRs {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// cidrMiddleware rejects clients outside the -allow-cidr ranges with 403
+func cidrMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !clientAllowed(r) {
+			http.Error(w, "Access denied: your address is not allowed", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operator endpoints live below /admin/ (the -manage page and the configuration), apart from
+// what recipients use. They need -admin-token, which is separate from -upload-token and
+// -list-token so those can be handed to guests; without it they are only served to this
+// computer. Every client gets a small request budget there, and one that sends a few wrong
+// tokens is locked out for the session, so the token cannot be guessed.
+const (
+	adminUploadsPath    = "/admin/uploads" // The -manage page
+	adminConfigPath     = "/admin/config"  // The effective configuration as JSON
+	adminTokenParam     = "admin-token"    // Query parameter or form field carrying -admin-token (also accepted as X-Admin-Token)
+	adminRequestsPerMin = 60               // Requests per client IP and minute to /admin/
+	maxAdminTokenErrors = 5                // Wrong tokens per client IP before /admin/ stops answering it
+)
+
+// adminClient is what /admin/ remembers about one client IP
+type adminClient struct {
+	windowStart time.Time // Start of the current one-minute window
+	requests    int       // Requests in that window
+	tokenErrors int       // Wrong tokens in the whole session
+}
+
+var (
+	adminClients   = map[string]*adminClient{}
+	adminClientsMu sync.Mutex
+)
+
+// adminTokenFrom returns the token a request carries, "" if none
+func adminTokenFrom(r *http.Request) string {
+	if token := r.Header.Get("X-Admin-Token"); token != "" {
+		return token
+	}
+	if token := r.URL.Query().Get(adminTokenParam); token != "" {
+		return token
+	}
+	return r.PostFormValue(adminTokenParam)
+}
+
+// withAdminToken adds admin-token=... to the query of an /admin/ link under -admin-token
+func withAdminToken(link string) string {
+	if adminToken == "" {
+		return link
+	}
+	separator := "?"
+	if strings.Contains(link, "?") {
+		separator = "&"
+	}
+	return link + separator + adminTokenParam + "=" + url.QueryEscape(adminToken)
+}
+
+// adminOnly wraps an /admin/ handler with the rate limit and the token check: 429 over the
+// budget or after too many wrong tokens, 401 without a token, 403 with a wrong one (or from
+// another computer when there is no -admin-token)
+func adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r)
+		adminClientsMu.Lock()
+		client := adminClients[ip]
+		if client == nil {
+			client = &adminClient{}
+			adminClients[ip] = client
+		}
+		now := time.Now()
+		if now.Sub(client.windowStart) >= time.Minute {
+			client.windowStart, client.requests = now, 0
+		}
+		client.requests++
+		overBudget := client.requests > adminRequestsPerMin
+		lockedOut := client.tokenErrors >= maxAdminTokenErrors
+		retryAfter := client.windowStart.Add(time.Minute).Sub(now)
+		adminClientsMu.Unlock()
+		if lockedOut {
+			http.Error(w, "Too many wrong admin tokens", http.StatusTooManyRequests)
+			return
+		}
+		if overBudget {
+			w.Header().Set("Retry-After", fmt.Sprint(int(retryAfter.Seconds())+1))
+			http.Error(w, "Too many requests, try again in a minute", http.StatusTooManyRequests)
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			r.Body = http.MaxBytesReader(w, r.Body, 64*1024) // The token may be a form field, parsed below
+		}
+		if adminToken == "" {
+			if !isLocalClient(r) {
+				http.Error(w, "Only available on this computer (start pair with -admin-token to use it from elsewhere)", http.StatusForbidden)
+				return
+			}
+			next(w, r)
+			return
+		}
+		token := adminTokenFrom(r)
+		if token == "" {
+			http.Error(w, "Admin token required", http.StatusUnauthorized)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			adminClientsMu.Lock()
+			client.tokenErrors++
+			adminClientsMu.Unlock()
+			http.Error(w, "Wrong admin token", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// movedTo redirects an endpoint's old path to its /admin/ one, keeping the method and query
+func movedTo(path string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target := routePath(path)
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Chunk manifests for /chunks/: clients fetch each chunk with a Range request on
+// /download/ and re-fetch only the chunks whose SHA-256 does not match
+const (
+	manifestChunkSize    = 4 << 20 // Bytes per chunk (the last chunk may be shorter)
+	maxCachedManifests   = 256     // The cache is cleared when it grows beyond this
+	chunkManifestVersion = 1
+)
+
+// ChunkInfo describes one fixed-size chunk of a file
+type ChunkInfo struct {
+	Index  int    `json:"index"`
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+	SHA256 string `json:"sha256"`
+}
+
+// ChunkManifest is the JSON document served by /chunks/
+type ChunkManifest struct {
+	Version   int         `json:"version"`
+	Path      string      `json:"path"`
+	Download  string      `json:"download"` // URL path to fetch chunks from with Range requests
+	Size      int64       `json:"size"`
+	ChunkSize int64       `json:"chunk_size"`
+	SHA256    string      `json:"sha256"` // Hash of the whole file
+	Chunks    []ChunkInfo `json:"chunks"`
+}
+
+// manifestCacheKey identifies one version of a file; a changed modtime or size invalidates it
+type manifestCacheKey struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+var (
+	manifestCache   = make(map[manifestCacheKey]*ChunkManifest)
+	manifestCacheMu sync.Mutex
+)
+
+// buildChunkManifest hashes the file chunk by chunk (and as a whole) in a single pass
+func buildChunkManifest(path string, size int64) (*ChunkManifest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	manifest := &ChunkManifest{Version: chunkManifestVersion, Size: size, ChunkSize: manifestChunkSize, Chunks: []ChunkInfo{}}
+	whole := sha256.New()
+	buf := make([]byte, transferBufSize)
+	for offset := int64(0); offset < size; offset += manifestChunkSize {
+		length := min(int64(manifestChunkSize), size-offset)
+		chunk := sha256.New()
+		n, err := io.CopyBuffer(io.MultiWriter(chunk, whole), io.LimitReader(file, length), buf)
+		if err != nil {
+			return nil, err
+		}
+		if n != length {
+			return nil, fmt.Errorf("file changed while hashing")
+		}
+		manifest.Chunks = append(manifest.Chunks, ChunkInfo{
+			Index:  len(manifest.Chunks),
+			Offset: offset,
+			Length: length,
+			SHA256: hex.EncodeToString(chunk.Sum(nil)),
+		})
+	}
+	manifest.SHA256 = hex.EncodeToString(whole.Sum(nil))
+	return manifest, nil
+}
+
+// chunkManifest returns the cached manifest for the file version, building it on a miss
+func chunkManifest(path string, info os.FileInfo) (*ChunkManifest, error) {
+	key := manifestCacheKey{path: path, modTime: info.ModTime(), size: info.Size()}
+
+	manifestCacheMu.Lock()
+	cached, ok := manifestCache[key]
+	manifestCacheMu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	manifest, err := buildChunkManifest(path, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	manifestCacheMu.Lock()
+	if len(manifestCache) >= maxCachedManifests {
+		clear(manifestCache)
+	}
+	manifestCache[key] = manifest
+	manifestCacheMu.Unlock()
+	return manifest, nil
+}
+
+// chunksHandler serves the chunk manifest for an allowed download (same checks as /download/)
+func chunksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if shareExpired(w) {
+		return
+	}
+
+	_, servePath, decodedPath, ok := resolveDownloadRequest(w, r, "/chunks/")
+	if !ok {
+		return
+	}
+
+	fileInfo, err := os.Stat(servePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, fmt.Sprintf("File %s does not exist", decodedPath), http.StatusNotFound)
+		} else {
+			serverError(w, "Failed to get file information", err)
+		}
+		return
+	}
+	if fileInfo.IsDir() {
+		http.Error(w, fmt.Sprintf("%s is a directory, download is not supported", decodedPath), http.StatusBadRequest)
+		return
+	}
+
+	cached, err := chunkManifest(servePath, fileInfo)
+	if err != nil {
+		serverError(w, "Failed to hash file", err)
+		return
+	}
+
+	// The cached manifest is shared, so fill in the request-specific fields on a copy
+	manifest := *cached
+	manifest.Path = filepath.ToSlash(decodedPath)
+	manifest.Download = routePath("/download/" + url.PathEscape(manifest.Path))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(manifest)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Client confirmation (via -confirm-clients): the first request from an unknown client IP waits
+// until the operator answers a prompt on the terminal. Answers last for the session; requests
+// from this computer are never held. Prompts go to stderr, which stays on the terminal even
+// when stdout is redirected (-quiet, -print-urls, -qr-svg -).
+
+// clientDecision is the operator's answer for one IP; done is closed once it is known
+type clientDecision struct {
+	done    chan struct{}
+	allowed bool
+}
+
+var (
+	clientDecisions = make(map[string]*clientDecision)
+	clientPrompts   = make(chan string, 64) // IPs waiting for a prompt, asked one at a time
+	clientMu        sync.Mutex
+)
+
+// stdinIsTerminal reports whether stdin is interactive (prompts cannot be answered otherwise)
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// startClientPrompts reads the operator's answers from stdin. Once stdin is closed, every
+// client still waiting or arriving later is denied.
+func startClientPrompts() {
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for ip := range clientPrompts {
+			fmt.Fprintf(os.Stderr, "%sAllow %s? [y/N] ", glyph("❓ ", "? "), ip)
+			allowed := false
+			if scanner.Scan() {
+				answer := strings.ToLower(strings.TrimSpace(scanner.Text()))
+				allowed = answer == "y" || answer == "yes"
+			} else {
+				fmt.Fprintln(os.Stderr)
+				log.Printf("Warning: stdin closed, %s and all further new clients are denied", ip)
+			}
+			decide(ip, allowed)
+		}
+	}()
+}
+
+// decide records the answer for ip and releases its waiting requests
+func decide(ip string, allowed bool) {
+	clientMu.Lock()
+	decision := clientDecisions[ip]
+	clientMu.Unlock()
+	decision.allowed = allowed
+	close(decision.done)
+	if allowed {
+		fmt.Fprintf(os.Stderr, "Allowed %s for this session\n", ip)
+	} else {
+		fmt.Fprintf(os.Stderr, "Denied %s for this session\n", ip)
+	}
+}
+
+// clientDecisionFor returns the decision for ip, queueing a prompt if it is the first request
+func clientDecisionFor(ip string) *clientDecision {
+	clientMu.Lock()
+	decision, ok := clientDecisions[ip]
+	if !ok {
+		decision = &clientDecision{done: make(chan struct{})}
+		clientDecisions[ip] = decision
+	}
+	clientMu.Unlock()
+	if !ok {
+		clientPrompts <- ip // Outside the lock: a long queue must not block known clients
+	}
+	return decision
+}
+
+// clientPromptMiddleware holds requests from unknown clients until the operator has answered,
+// then serves or rejects them (403). A client that gives up waiting keeps its pending prompt.
+func clientPromptMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isLocalClient(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		decision := clientDecisionFor(clientIP(r))
+		select {
+		case <-decision.done:
+		case <-r.Context().Done():
+			return
+		}
+		if !decision.allowed {
+			http.Error(w, "Access denied by the operator", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
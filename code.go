@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"html/template"
+	"math/big"
+	"net/http"
+	"sync"
+)
+
+// Short numeric access code for recipients who can't scan the QR code (typed in on /code)
+const (
+	accessCodeDigits    = 6
+	maxAccessCodeErrors = 10 // Wrong attempts per client IP before /code stops accepting guesses
+)
+
+var (
+	accessCode       string // Generated at startup and printed in the banner
+	accessCodeErrors = map[string]int{}
+	accessCodeMu     sync.Mutex
+)
+
+// generateAccessCode returns a random zero-padded numeric code
+func generateAccessCode(digits int) (string, error) {
+	limit := big.NewInt(1)
+	for i := 0; i < digits; i++ {
+		limit.Mul(limit, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", digits, n), nil
+}
+
+// sharePath returns the page a recipient should land on for the current share
+func sharePath() string {
+	if allowSingleFilePath != "" {
+		return "/download/" + allowSingleFilePath
+	}
+	if hasDownloadList() {
+		return withListToken("/downloads")
+	}
+	return uploadPagePath()
+}
+
+// codeHandler shows the code entry page and redirects /code?c=NNNNNN to the share
+func codeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	message := ""
+	if entered := r.URL.Query().Get("c"); entered != "" {
+		ip := clientIP(r)
+
+		// Count the attempt as wrong before comparing, in the same lock as the check, so
+		// concurrent guesses can't all get past the limit before any of them is counted
+		accessCodeMu.Lock()
+		blocked := accessCodeErrors[ip] >= maxAccessCodeErrors
+		if !blocked {
+			accessCodeErrors[ip]++
+		}
+		accessCodeMu.Unlock()
+		if blocked {
+			http.Error(w, "Too many wrong codes", http.StatusTooManyRequests)
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(entered), []byte(accessCode)) == 1 {
+			accessCodeMu.Lock()
+			accessCodeErrors[ip]--
+			accessCodeMu.Unlock()
+			http.Redirect(w, r, routePath(sharePath()), http.StatusSeeOther)
+			return
+		}
+		message = `<div class="error">Wrong code, please try again</div>`
+	}
+
+	html := `
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>` + template.HTMLEscapeString(pageTitle("Enter code")) + `</title>
+    <style>
+        * {
+            margin: 0;
+            padding: 0;
+            box-sizing: border-box;
+        }
+
+        body {
+            max-width: 400px;
+            margin: 0 auto;
+            padding: 40px 15px;
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Arial, sans-serif;
+            text-align: center;
+        }
+
+        h1 {
+            font-size: 1.8rem;
+            color: #333;
+            margin-bottom: 20px;
+        }
+
+        input {
+            width: 100%;
+            padding: 12px;
+            font-size: 2rem;
+            letter-spacing: 0.3em;
+            text-align: center;
+            border: 1px solid #ccc;
+            border-radius: 4px;
+        }
+
+        button {
+            margin-top: 20px;
+            padding: 12px 30px;
+            width: 100%;
+            background-color: #4285f4;
+            color: white;
+            border: none;
+            border-radius: 4px;
+            font-size: 1rem;
+        }
+
+        .error {
+            color: #dc3545;
+            margin-bottom: 15px;
+        }
+    </style>
+</head>
+<body>
+    <h1>Enter the code shown on the computer</h1>
+    ` + message + `
+    <form method="get" action="` + routePath("/code") + `">
+        <input name="c" inputmode="numeric" pattern="[0-9]*" maxlength="` + fmt.Sprint(accessCodeDigits) + `" autocomplete="off" autofocus required>
+        <button type="submit">Open</button>
+    </form>
+    ` + string(sessionFooter()) + `
+</body>
+</html>
+`
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, html)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAccessCodeLockout(t *testing.T) {
+	newTestShare(t)
+	clear(accessCodeErrors) // Other tests guess wrong codes too
+	t.Cleanup(func() { clear(accessCodeErrors) })
+	mux := newTestMux()
+
+	// Guesses sent at once must not get past the limit before they are counted
+	var answered atomic.Int64
+	var wg sync.WaitGroup
+	for range 5 * maxAccessCodeErrors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response := httptest.NewRecorder()
+			mux.ServeHTTP(response, newRequest(http.MethodGet, "/code?c=000000", "", nil))
+			if response.Code != http.StatusTooManyRequests {
+				answered.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if answered.Load() != maxAccessCodeErrors {
+		t.Errorf("%d wrong codes were checked, want %d", answered.Load(), maxAccessCodeErrors)
+	}
+
+	// Once locked out, not even the right code is accepted
+	response := httptest.NewRecorder()
+	mux.ServeHTTP(response, newRequest(http.MethodGet, "/code?c="+accessCode, "", nil))
+	if response.Code != http.StatusTooManyRequests {
+		t.Errorf("right code after the lockout: %d, want %d", response.Code, http.StatusTooManyRequests)
+	}
+}
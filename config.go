@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// redactedValue replaces secrets in /admin/config; an unset secret is left empty
+const redactedValue = "[redacted]"
+
+// The base URL printed at startup and the address listened on ("" = all), for /admin/config
+var advertisedURL, listenAddress string
+
+// ConfigSummary is the effective configuration served on /admin/config
+type ConfigSummary struct {
+	URL       string            `json:"url"`
+	Ports     []string          `json:"ports"`
+	Bind      string            `json:"bind"` // "all" or the only address listened on (-bind-lan)
+	CIDRs     []string          `json:"allowed_cidrs"`
+	TLS       bool              `json:"tls"`
+	BasePath  string            `json:"base_path"`
+	WorkDir   string            `json:"work_dir"`
+	UploadDir string            `json:"upload_dir"`
+	SharedDir string            `json:"shared_dir"`
+	Files     []string          `json:"files"`   // Allowed downloads, relative to work_dir
+	Aliases   map[string]string `json:"aliases"` // -x alias=path
+	Features  []string          `json:"features"`
+	Settings  map[string]string `json:"settings"`
+	Limits    ConfigLimits      `json:"limits"`
+}
+
+// ConfigLimits are the size, count and time limits (0 or empty = none)
+type ConfigLimits struct {
+	MaxFileSize       int64  `json:"max_file_size"`
+	MaxTotal          int64  `json:"max_total"`
+	MaxDownloads      int64  `json:"max_downloads"`
+	ZipWarnSize       int64  `json:"zip_warn_size"`
+	UploadIdleTimeout string `json:"upload_idle_timeout"`
+	UploadWindowStart string `json:"upload_window_start"`
+	UploadWindowEnd   string `json:"upload_window_end"`
+	BufferSize        int    `json:"buffer_size"`
+	LogMaxSize        int64  `json:"log_max_size"`
+	DiskRate          int64  `json:"disk_rate"`
+	MaxFormSize       int64  `json:"max_form_size"`
+	MaxFormFields     int    `json:"max_form_fields"`
+}
+
+// redactSecret hides a secret's value but keeps whether it is set
+func redactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+// redactURL hides the password of a URL with credentials (e.g. a -mirror target)
+func redactURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.User == nil {
+		return raw
+	}
+	return parsed.Redacted()
+}
+
+// windowTime formats one bound of the -upload-window, "" if unbounded
+func windowTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// configSummary collects the configuration pair is running with, after flag parsing and validation
+func configSummary() ConfigSummary {
+	bind := "all"
+	if listenAddress != "" {
+		bind = listenAddress
+	}
+	var cidrs []string
+	for _, ipnet := range allowedCIDRs {
+		cidrs = append(cidrs, ipnet.String())
+	}
+	var files []string
+	for _, file := range getDownloadableFiles() {
+		files = append(files, file.RelPath)
+	}
+
+	var features []string
+	for name, enabled := range map[string]bool{
+		"sandbox":               sandboxMode,
+		"metrics":               metricsEnabled,
+		"api":                   apiSpecEnabled,
+		"notes":                 notesEnabled,
+		"history":               historyEnabled,
+		"manage":                manageEnabled,
+		"dedupe":                dedupeEnabled,
+		"fsync":                 fsyncUploads,
+		"strip-metadata":        stripMetadata,
+		"unzip":                 unzipUploads,
+		"tail":                  tailMode,
+		"snapshot":              snapshotDownloads,
+		"detect-changes":        detectChanges,
+		"precompressed":         precompressed,
+		"confirm":               confirmDownloads,
+		"confirm-clients":       confirmClients,
+		"ignore-case":           ignoreCase,
+		"follow-symlinks":       followSymlinks,
+		"bind-lan":              bindLAN,
+		"http3":                 http3Enabled,
+		"delete-after-download": deleteAfterDownload,
+		"icons":                 fileIcons,
+		"inbox":                 inboxMode,
+		"single-upload":         singleUpload,
+		"no-js":                 noJSForm,
+	} {
+		if enabled {
+			features = append(features, name)
+		}
+	}
+	slices.Sort(features)
+
+	return ConfigSummary{
+		URL:       advertisedURL,
+		Ports:     listenPorts,
+		Bind:      bind,
+		CIDRs:     cidrs,
+		TLS:       tlsEnabled,
+		BasePath:  basePath,
+		WorkDir:   currentWorkDir,
+		UploadDir: currentWorkDir,
+		SharedDir: sharedDir,
+		Files:     files,
+		Aliases:   downloadAliases,
+		Features:  features,
+		Settings: map[string]string{
+			"default":      defaultPage,
+			"on-conflict":  conflictMode,
+			"archive":      archiveFormat,
+			"zip-enc":      zipEncryptionName,
+			"from-archive": sourceArchive,
+			"upload-cmd":   uploadCommand,
+			"transform":    strings.Join(transformNames(), ","),
+			"mirror":       redactURL(mirrorTarget),
+			"unzip-dir":    unzipDir,
+			"state-dir":    stateDir,
+			"server-name":  serverName,
+			"title":        siteTitle,
+			"s3-bucket":    s3Bucket,
+			"s3-prefix":    s3Prefix,
+			"s3-region":    s3Region,
+			"s3-endpoint":  s3Endpoint,
+			"log-file":     logFile,
+			"client-ca":    tlsClientCAFile,
+			"file-mode":    fmt.Sprintf("%#o", uploadFileMode.Perm()),
+			"dir-mode":     fmt.Sprintf("%#o", uploadDirMode.Perm()),
+			"upload-token": redactSecret(uploadToken),
+			"admin-token":  redactSecret(adminToken),
+			"list-token":   redactSecret(listToken),
+			"zip-pass":     redactSecret(zipPassword),
+		},
+		Limits: ConfigLimits{
+			MaxFileSize:       maxFileSize,
+			MaxTotal:          maxTotalUpload,
+			MaxDownloads:      maxDownloads,
+			ZipWarnSize:       zipWarnSize,
+			UploadIdleTimeout: uploadIdleTimeout.String(),
+			UploadWindowStart: windowTime(uploadWindowStart),
+			UploadWindowEnd:   windowTime(uploadWindowEnd),
+			BufferSize:        transferBufSize,
+			LogMaxSize:        logMaxSize,
+			DiskRate:          diskRate,
+			MaxFormSize:       maxFormSize,
+			MaxFormFields:     maxFormFields,
+		},
+	}
+}
+
+// configHandler serves the effective configuration as JSON. It names local paths, so it is
+// an /admin/ endpoint, served behind adminOnly.
+func configHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(configSummary())
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+)
+
+// confirmTemplate is the page -confirm shows instead of starting a download right away
+var confirmTemplate = template.Must(template.New("confirm").Funcs(template.FuncMap{
+	"sessionFooter": sessionFooter,
+	"pageTitle":     pageTitle,
+}).Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{pageTitle (printf "Download %s?" .Name)}}</title>
+    <style>
+        * {
+            margin: 0;
+            padding: 0;
+            box-sizing: border-box;
+        }
+
+        body {
+            max-width: 400px;
+            margin: 0 auto;
+            padding: 40px 15px;
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Arial, sans-serif;
+            text-align: center;
+        }
+
+        h1 {
+            font-size: 1.5rem;
+            color: #333;
+            margin-bottom: 10px;
+            word-break: break-all;
+        }
+
+        .size {
+            font-size: 1.2rem;
+            color: #666;
+            margin-bottom: 30px;
+        }
+
+        .download-btn {
+            display: block;
+            padding: 12px 30px;
+            background-color: #28a745;
+            color: white;
+            border-radius: 4px;
+            text-decoration: none;
+            font-size: 1rem;
+        }
+
+        .back-link {
+            display: inline-block;
+            margin-top: 20px;
+            color: #4285f4;
+            text-decoration: none;
+        }
+    </style>
+</head>
+<body>
+    <h1>{{.Name}}</h1>
+    <div class="size">{{.Size}}</div>
+    <a href="{{.Href}}" class="download-btn">Download</a>
+    <a href="{{.Back}}" class="back-link">← Back</a>
+    {{sessionFooter}}
+</body>
+</html>
+`))
+
+// downloadConfirmed reports whether a download may start: always without -confirm, otherwise
+// only after the confirmation page (?go=1). Range requests come from download managers and
+// chunk fetchers that already know what they are fetching, so they are never held back.
+func downloadConfirmed(r *http.Request) bool {
+	return !confirmDownloads || r.URL.Query().Get("go") == "1" || r.Header.Get("Range") != "" || r.Method == http.MethodHead
+}
+
+// serveDownloadConfirm shows the file name and size with a button that repeats the request with ?go=1
+func serveDownloadConfirm(w http.ResponseWriter, r *http.Request, fileName string, size int64) {
+	query := r.URL.Query()
+	query.Set("go", "1")
+	href := (&url.URL{Path: routePath(r.URL.Path), RawQuery: query.Encode()}).String()
+
+	back := sharePath()
+	if back == r.URL.Path {
+		back = uploadPagePath()
+	}
+	back = routePath(back)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	confirmTemplate.Execute(w, struct {
+		Name, Size, Href, Back string
+	}{fileName, formatFileSize(size), href, back})
+}
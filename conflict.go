@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Upload name conflicts: an upload whose name already exists is compared with the existing
+// file by SHA-256 (the existing side is cached per file version, see chunkManifest). Identical
+// content is skipped; otherwise -on-conflict decides: error (409, the default), rename or overwrite.
+var conflictModes = []string{"error", "rename", "overwrite"}
+
+// errUploadConflict means the name exists with different content and -on-conflict is error
+var errUploadConflict = errors.New("already exists with different content")
+
+// uploadTarget is the file one upload is written to
+type uploadTarget struct {
+	savePath  string      // The requested location
+	writePath string      // Where the data goes: savePath, or a temp sibling if savePath exists
+	existing  os.FileInfo // The file already at savePath, nil if the name was free
+	hash      hash.Hash   // Hash of the incoming data while comparing
+}
+
+// createUploadTarget creates the file an upload is written to. A free name is written directly;
+// for a taken one the data goes to a temp file next to it (named like a .pair-part file, so crashes
+// leave nothing behind for long) and the original stays untouched until finish. size is the
+// announced size or -1: with -on-conflict error, a different size fails before any data is read.
+func createUploadTarget(savePath string, size int64) (*uploadTarget, *os.File, error) {
+	target := &uploadTarget{savePath: savePath, writePath: savePath}
+	file, err := os.OpenFile(savePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, uploadFileMode)
+	if err == nil || !os.IsExist(err) {
+		return target, file, err
+	}
+
+	existing, err := os.Stat(savePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !existing.Mode().IsRegular() || (conflictMode == "error" && size >= 0 && size != existing.Size()) {
+		return nil, nil, errUploadConflict
+	}
+	file, err = os.CreateTemp(filepath.Dir(savePath), "."+filepath.Base(savePath)+".*"+partSuffix)
+	if err != nil {
+		return nil, nil, err
+	}
+	target.writePath = file.Name()
+	target.existing = existing
+	target.hash = sha256.New()
+	return target, file, nil
+}
+
+// writer returns what the upload data should be written to (throttled by -disk-rate)
+func (t *uploadTarget) writer(file *os.File) io.Writer {
+	if t.hash == nil {
+		return throttleDisk(file)
+	}
+	return io.MultiWriter(throttleDisk(file), t.hash)
+}
+
+// finish settles a completely written (and closed) upload. It returns the path the file was
+// saved as, or "" if it was identical to the existing file and has been dropped.
+func (t *uploadTarget) finish() (string, error) {
+	if t.existing == nil {
+		return t.savePath, nil
+	}
+	if t.identical() {
+		os.Remove(t.writePath)
+		return "", nil
+	}
+
+	switch conflictMode {
+	case "overwrite":
+		if err := os.Rename(t.writePath, t.savePath); err != nil {
+			os.Remove(t.writePath)
+			return "", err
+		}
+		return t.savePath, nil
+	case "rename":
+		// Linking fails instead of replacing when another upload took the name in the meantime
+		for i := 1; ; i++ {
+			path := numberedPath(t.savePath, i)
+			err := os.Link(t.writePath, path)
+			if err == nil {
+				os.Remove(t.writePath)
+				return path, nil
+			}
+			if !os.IsExist(err) {
+				os.Remove(t.writePath)
+				return "", err
+			}
+		}
+	default:
+		os.Remove(t.writePath)
+		return "", errUploadConflict
+	}
+}
+
+// identical reports whether the written data matches the existing file
+func (t *uploadTarget) identical() bool {
+	info, err := os.Stat(t.writePath)
+	if err != nil || info.Size() != t.existing.Size() {
+		return false
+	}
+	manifest, err := chunkManifest(t.savePath, t.existing)
+	return err == nil && manifest.SHA256 == hex.EncodeToString(t.hash.Sum(nil))
+}
+
+// numberedPath returns "dir/name (i).ext" for "dir/name.ext"
+func numberedPath(path string, i int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(path, ext), i, ext)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dedupeEntry is the hash of one version of a file in the upload directory
+type dedupeEntry struct {
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+// The hash index (via -dedupe) is keyed by path and filled lazily: a file is only hashed
+// once another upload of the same size arrives, and again only after it changed. The lock is
+// held for the whole check, so two identical uploads finishing together can't both be kept.
+var (
+	dedupeIndex = make(map[string]dedupeEntry)
+	dedupeMu    sync.Mutex
+)
+
+// dedupeUpload removes a freshly saved upload if a file with the same content already exists
+// in its directory (no-op without -dedupe) and returns that file's name, or "" if the upload
+// was kept. Failures only keep the upload: a duplicate is untidy, losing a file is not.
+func dedupeUpload(savePath string) string {
+	if !dedupeEnabled {
+		return ""
+	}
+	dedupeMu.Lock()
+	defer dedupeMu.Unlock()
+
+	info, err := os.Stat(savePath)
+	if err != nil {
+		return ""
+	}
+	dir := filepath.Dir(savePath)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		fmt.Printf("Failed to check %s for duplicates: %v\n", savePath, err)
+		return ""
+	}
+
+	var sum string
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == info.Name() || !entry.Type().IsRegular() || strings.HasSuffix(name, partSuffix) {
+			continue
+		}
+		other, err := entry.Info()
+		if err != nil || other.Size() != info.Size() {
+			continue
+		}
+		if sum == "" {
+			if sum, err = indexedHash(savePath, info); err != nil {
+				fmt.Printf("Failed to check %s for duplicates: %v\n", savePath, err)
+				return ""
+			}
+		}
+		otherSum, err := indexedHash(filepath.Join(dir, name), other)
+		if err != nil || otherSum != sum {
+			continue
+		}
+		if err := os.Remove(savePath); err != nil {
+			fmt.Printf("Failed to remove duplicate %s: %v\n", savePath, err)
+			return ""
+		}
+		delete(dedupeIndex, savePath)
+		return name
+	}
+	return ""
+}
+
+// indexedHash returns the SHA-256 of a file from the index, hashing it if it is new or changed.
+// The caller holds dedupeMu.
+func indexedHash(path string, info os.FileInfo) (string, error) {
+	if entry, ok := dedupeIndex[path]; ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry.sum, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.CopyBuffer(hash, file, make([]byte, transferBufSize)); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	dedupeIndex[path] = dedupeEntry{size: info.Size(), modTime: info.ModTime(), sum: sum}
+	return sum, nil
+}
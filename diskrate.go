@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Write throttle for received files (via -disk-rate), for slow disks such as SD cards or network
+// mounts. All uploads share one budget, since they end up on the same disk. /put/, /resume and
+// /ws write what they read before reading on, so holding the writes back also slows down the
+// network side instead of buffering; the idle watchdog only counts time spent waiting for the
+// client, so throttled uploads are not aborted by -upload-idle-timeout.
+const diskRateChunk = 64 << 10 // Largest write made at once, so waits stay short and even
+
+var (
+	diskRateMu   sync.Mutex
+	diskRateNext time.Time // When the budget is free again (in the past while the disk is idle)
+)
+
+// diskRateWriter writes to w at no more than -disk-rate bytes per second in total
+type diskRateWriter struct {
+	w io.Writer
+}
+
+// throttleDisk wraps w with the -disk-rate throttle if one is set
+func throttleDisk(w io.Writer) io.Writer {
+	if diskRate <= 0 {
+		return w
+	}
+	return diskRateWriter{w}
+}
+
+func (dw diskRateWriter) Write(b []byte) (int, error) {
+	written := 0
+	for written < len(b) {
+		chunk := b[written:min(len(b), written+diskRateChunk)]
+		waitDiskRate(len(chunk))
+		n, err := dw.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// waitDiskRate reserves the time n bytes take at -disk-rate and sleeps until that slot starts.
+// An idle disk does not build up credit, so the rate also holds right after a pause.
+func waitDiskRate(n int) {
+	diskRateMu.Lock()
+	now := time.Now()
+	if diskRateNext.Before(now) {
+		diskRateNext = now
+	}
+	wait := diskRateNext.Sub(now)
+	diskRateNext = diskRateNext.Add(time.Duration(int64(n) * int64(time.Second) / diskRate))
+	diskRateMu.Unlock()
+	time.Sleep(wait)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// newDownloadServer shares a fresh directory holding name with data (via -d .)
+func newDownloadServer(t *testing.T, name string, data []byte) *httptest.Server {
+	t.Helper()
+	dir := newTestShare(t)
+	sharedDir = "."
+	t.Cleanup(func() { sharedDir = "" })
+	writeTestFile(t, dir, name, data)
+	server := httptest.NewServer(newTestMux())
+	t.Cleanup(server.Close)
+	return server
+}
+
+// get sends a GET for url with the given headers and returns the response and its body
+func get(url string, headers map[string]string) (*http.Response, []byte, error) {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	return response, body, err
+}
+
+func TestParallelRangeDownload(t *testing.T) {
+	data := make([]byte, 1<<20+12345)
+	rand.New(rand.NewSource(1)).Read(data)
+	server := newDownloadServer(t, "data.bin", data)
+	fileURL := server.URL + "/download/data.bin"
+
+	// What a download manager does first: learn the size and version
+	head, err := http.Head(fileURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head.Body.Close()
+	etag := head.Header.Get("ETag")
+	if head.Header.Get("Accept-Ranges") != "bytes" || etag == "" || head.ContentLength != int64(len(data)) {
+		t.Fatalf("HEAD: Accept-Ranges %q, ETag %q, length %d", head.Header.Get("Accept-Ranges"), etag, head.ContentLength)
+	}
+
+	// Then fetch the segments in parallel, each pinned to that version
+	const segments = 4
+	segmentSize := int64(len(data)+segments-1) / segments
+	parts := make([][]byte, segments)
+	var wg sync.WaitGroup
+	for i := range segments {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := int64(i) * segmentSize
+			end := min(start+segmentSize, int64(len(data))) - 1
+			response, body, err := get(fileURL, map[string]string{
+				"Range":    fmt.Sprintf("bytes=%d-%d", start, end),
+				"If-Range": etag,
+			})
+			if err != nil {
+				t.Errorf("segment %d: %v", i, err)
+				return
+			}
+			if response.StatusCode != http.StatusPartialContent {
+				t.Errorf("segment %d: %s", i, response.Status)
+				return
+			}
+			if want := fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)); response.Header.Get("Content-Range") != want {
+				t.Errorf("segment %d: Content-Range %q, want %q", i, response.Header.Get("Content-Range"), want)
+			}
+			parts[i] = body
+		}()
+	}
+	wg.Wait()
+	if !bytes.Equal(bytes.Join(parts, nil), data) {
+		t.Fatal("the reassembled segments differ from the file")
+	}
+}
+
+func TestRangeDownloadValidation(t *testing.T) {
+	data := []byte("0123456789abcdefghij")
+	server := newDownloadServer(t, "small.txt", data)
+	fileURL := server.URL + "/download/small.txt"
+	full, _, err := get(fileURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := strconv.Itoa(len(data))
+
+	for _, test := range []struct {
+		name         string
+		headers      map[string]string
+		status       int
+		body         string
+		contentRange string
+	}{
+		{"range", map[string]string{"Range": "bytes=5-9"}, http.StatusPartialContent, "56789", "bytes 5-9/" + size},
+		{"open-ended range", map[string]string{"Range": "bytes=15-"}, http.StatusPartialContent, "fghij", "bytes 15-19/" + size},
+		{"suffix range", map[string]string{"Range": "bytes=-3"}, http.StatusPartialContent, "hij", "bytes 17-19/" + size},
+		{"matching If-Range ETag", map[string]string{"Range": "bytes=0-3", "If-Range": full.Header.Get("ETag")}, http.StatusPartialContent, "0123", "bytes 0-3/" + size},
+		{"matching If-Range date", map[string]string{"Range": "bytes=0-3", "If-Range": full.Header.Get("Last-Modified")}, http.StatusPartialContent, "0123", "bytes 0-3/" + size},
+		{"If-Range of another version", map[string]string{"Range": "bytes=0-3", "If-Range": `"0-0"`}, http.StatusOK, string(data), ""},
+		{"If-Range of another date", map[string]string{"Range": "bytes=0-3", "If-Range": "Mon, 02 Jan 2006 15:04:05 GMT"}, http.StatusOK, string(data), ""},
+		{"start beyond the end", map[string]string{"Range": "bytes=20-"}, http.StatusRequestedRangeNotSatisfiable, "", "bytes */" + size},
+		{"end before start", map[string]string{"Range": "bytes=9-5"}, http.StatusRequestedRangeNotSatisfiable, "", "bytes */" + size},
+		{"multiple ranges", map[string]string{"Range": "bytes=0-1,5-6"}, http.StatusOK, string(data), ""},
+	} {
+		response, body, err := get(fileURL, test.headers)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if response.StatusCode != test.status {
+			t.Errorf("%s: %s, want %d", test.name, response.Status, test.status)
+			continue
+		}
+		if test.body != "" && string(body) != test.body {
+			t.Errorf("%s: body %q, want %q", test.name, body, test.body)
+		}
+		if response.Header.Get("Content-Range") != test.contentRange {
+			t.Errorf("%s: Content-Range %q, want %q", test.name, response.Header.Get("Content-Range"), test.contentRange)
+		}
+	}
+}
+
+func TestDownloadFraming(t *testing.T) {
+	data := bytes.Repeat([]byte("a line of the shared file\n"), 4096)
+	server := newDownloadServer(t, "log.txt", data)
+	fileURL := server.URL + "/download/log.txt"
+
+	// A plain download declares its length up front
+	response, body, err := get(fileURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.ContentLength != int64(len(data)) || len(response.TransferEncoding) != 0 || !bytes.Equal(body, data) {
+		t.Errorf("download: Content-Length %d, Transfer-Encoding %v, %d bytes; want Content-Length %d", response.ContentLength, response.TransferEncoding, len(body), len(data))
+	}
+
+	// -detect-changes needs chunked encoding for its trailer
+	detectChanges = true
+	response, body, err = get(fileURL, nil)
+	detectChanges = false
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.ContentLength != -1 || !slices.Equal(response.TransferEncoding, []string{"chunked"}) || !bytes.Equal(body, data) {
+		t.Errorf("-detect-changes: Content-Length %d, Transfer-Encoding %v, %d bytes; want chunked", response.ContentLength, response.TransferEncoding, len(body))
+	}
+	if changed := response.Trailer.Get(contentChangedHeader); changed != "false" {
+		t.Errorf("-detect-changes: %s trailer %q, want false", contentChangedHeader, changed)
+	}
+
+	// -tail streams without an end, so it can't declare a length either
+	tailMode = true
+	defer func() { tailMode = false }()
+	response, err = http.Get(fileURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.ContentLength != -1 || !slices.Equal(response.TransferEncoding, []string{"chunked"}) {
+		t.Errorf("-tail: Content-Length %d, Transfer-Encoding %v; want chunked", response.ContentLength, response.TransferEncoding)
+	}
+	if _, err := io.ReadFull(response.Body, make([]byte, len(data))); err != nil {
+		t.Errorf("-tail: reading the current content: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// FileEntry is one file of the download list as served on /api/files
+type FileEntry struct {
+	Number   int        `json:"number"` // Position in the list, as used by ?from=/to= archives
+	Name     string     `json:"name"`
+	Path     string     `json:"path"` // Relative to the working directory
+	Size     int64      `json:"size"`
+	Kind     string     `json:"kind"`               // image, video, audio, archive, document, code or file
+	ModTime  *time.Time `json:"mod_time,omitempty"` // Only for existing files, like Download
+	Exists   bool       `json:"exists"`
+	Download string     `json:"download,omitempty"` // URL path
+}
+
+// filesHandler serves the download list as JSON, for scripts and apps that show it themselves.
+// It lists what /downloads shows, in list order, without absolute paths.
+func filesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if listHidden(w, r) {
+		return
+	}
+	entries := []FileEntry{}
+	for i, file := range getDownloadableFiles() {
+		entry := FileEntry{
+			Number: i + 1,
+			Name:   file.FileName,
+			Path:   file.RelPath,
+			Size:   file.Size,
+			Kind:   file.Kind,
+			Exists: file.Exists,
+		}
+		if file.Exists {
+			entry.ModTime = &file.ModTime
+			entry.Download = downloadLink(file)
+		}
+		entries = append(entries, entry)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(entries)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+)
+
+// Limits for the fields of an upload form that are not files, such as "token" and "inbox"
+// (via -max-form-size and -max-form-fields). Go's multipart parser keeps those fields in memory,
+// so a client could otherwise send up to 10 MB of text or a thousand fields per request
+// without uploading a single file. File parts are not affected.
+var errFormLimit = errors.New("form fields over the limit")
+
+// errFormUnauthorized means a file part arrived before a valid -upload-token (header, ?token=
+// or an earlier "token" field), so it was refused before anything was written to disk
+var errFormUnauthorized = errors.New("upload token required")
+
+// parseUploadForm reads a multipart upload into r.MultipartForm like r.ParseMultipartForm(0),
+// but checks the non-file fields while they arrive: the request is passed through a pipe to
+// the standard parser, and cut off with errFormLimit as soon as the fields exceed the limits,
+// or with errFormUnauthorized at the first file part sent without the upload token.
+func parseUploadForm(r *http.Request) error {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return http.ErrNotMultipart
+	}
+	source := multipart.NewReader(r.Body, params["boundary"])
+	pipeReader, pipeWriter := io.Pipe()
+	relay := multipart.NewWriter(pipeWriter)
+	go func() {
+		pipeWriter.CloseWithError(relayFormParts(source, relay, uploadAuthorized(r)))
+	}()
+
+	form, err := multipart.NewReader(pipeReader, relay.Boundary()).ReadForm(0)
+	pipeReader.Close() // Stops the relay if the parser gave up early
+	if err != nil {
+		return err
+	}
+
+	// Make the fields available to r.FormValue, as r.ParseMultipartForm does
+	if err := r.ParseForm(); err != nil {
+		form.RemoveAll()
+		return err
+	}
+	for name, values := range form.Value {
+		r.Form[name] = append(r.Form[name], values...)
+		r.PostForm[name] = append(r.PostForm[name], values...)
+	}
+	r.MultipartForm = form
+	return nil
+}
+
+// relayFormParts copies every part from source to relay, counting the non-file fields. Unless
+// authorized, file parts are only accepted after a "token" field with the upload token.
+func relayFormParts(source *multipart.Reader, relay *multipart.Writer, authorized bool) error {
+	fields, fieldBytes := 0, int64(0)
+	for {
+		part, err := source.NextPart()
+		if err == io.EOF {
+			return relay.Close()
+		}
+		if err != nil {
+			return err
+		}
+		if part.FileName() != "" && !authorized {
+			return errFormUnauthorized
+		}
+		dst, err := relay.CreatePart(part.Header)
+		if err != nil {
+			return err
+		}
+		if part.FileName() != "" {
+			if _, err := io.Copy(dst, part); err != nil {
+				return err
+			}
+			continue
+		}
+
+		fields++
+		if maxFormFields > 0 && fields > maxFormFields {
+			return fmt.Errorf("%w: more than %d fields besides the files", errFormLimit, maxFormFields)
+		}
+		var src io.Reader = part
+		if maxFormSize > 0 {
+			src = io.LimitReader(part, maxFormSize-fieldBytes+1) // One byte more reveals an oversized form
+		}
+		var value bytes.Buffer
+		n, err := io.Copy(io.MultiWriter(dst, &value), src)
+		if err != nil {
+			return err
+		}
+		fieldBytes += n
+		if maxFormSize > 0 && fieldBytes > maxFormSize {
+			return fmt.Errorf("%w: fields besides the files are larger than %s", errFormLimit, formatFileSize(maxFormSize))
+		}
+		if part.FormName() == "token" {
+			authorized = authorized || validUploadToken(value.String())
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUploadTokenOrder(t *testing.T) {
+	t.Cleanup(func() { uploadToken = "" })
+	for _, test := range []struct {
+		name   string
+		fields [][2]string // Form fields in order; "files" is a file part
+		status int
+	}{
+		{"no token", [][2]string{{"files", "a"}}, http.StatusUnauthorized},
+		{"wrong token", [][2]string{{"token", "guess"}, {"files", "a"}}, http.StatusUnauthorized},
+		{"token after the file", [][2]string{{"files", "a"}, {"token", "s3cret"}}, http.StatusUnauthorized},
+		{"token before the file", [][2]string{{"token", "s3cret"}, {"files", "a"}}, http.StatusOK},
+	} {
+		dir := newTestShare(t)
+		uploadToken = "s3cret"
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		for _, field := range test.fields {
+			if field[0] == "files" {
+				part, _ := form.CreateFormFile("files", "upload.txt")
+				part.Write([]byte(field[1]))
+			} else {
+				form.WriteField(field[0], field[1])
+			}
+		}
+		form.Close()
+
+		response := httptest.NewRecorder()
+		newTestMux().ServeHTTP(response, newRequest(http.MethodPost, "/upload", body.String(), map[string]string{"Content-Type": form.FormDataContentType()}))
+		if response.Code != test.status {
+			t.Errorf("%s: %d %q, want %d", test.name, response.Code, response.Body, test.status)
+		}
+		_, err := os.Stat(filepath.Join(dir, "upload.txt"))
+		if saved := err == nil; saved != (test.status == http.StatusOK) {
+			t.Errorf("%s: file saved %v", test.name, saved)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Sharing from an archive (via -from-archive FILE): the download list shows the files inside a
+// .zip or .tar.gz, and /download/ streams an entry straight out of it, so a few files of a large
+// archive can be shared without extracting it. A zip entry is found through the archive's
+// directory; a .tar.gz has none and is read from the start up to the entry on every download.
+// Entries are indexed once at startup; names that are absolute or climb out with ".." are
+// left out, as are links, directories and repeated names.
+
+// sourceEntry is one shared file inside the -from-archive archive
+type sourceEntry struct {
+	name    string // Cleaned, slash-separated path inside the archive
+	size    int64
+	modTime time.Time
+	zipFile *zip.File // nil in a .tar.gz
+}
+
+var (
+	sourceArchivePath string        // Absolute path of the -from-archive file
+	sourceEntries     []sourceEntry // Sorted by name
+	sourceZip         *zip.ReadCloser
+)
+
+// sourceEntryName turns the name of an archive entry into a safe relative path, false if it
+// has none (absolute, or leaving the archive's root)
+func sourceEntryName(name string) (string, bool) {
+	name = strings.ReplaceAll(name, `\`, "/") // Zips made on Windows
+	name = strings.TrimPrefix(name, "./")     // tar -czf x.tgz .
+	return name, validStorageName(name)
+}
+
+// openSourceArchive indexes the entries of the -from-archive file
+func openSourceArchive(archivePath string) error {
+	lower := strings.ToLower(archivePath)
+	var err error
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		err = indexZipArchive(archivePath)
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		err = indexTarArchive(archivePath)
+	default:
+		return fmt.Errorf("%s is not a .zip, .tar.gz or .tgz file", archivePath)
+	}
+	if err != nil {
+		return err
+	}
+	sourceArchivePath = archivePath
+	slices.SortFunc(sourceEntries, func(a, b sourceEntry) int { return strings.Compare(a.name, b.name) })
+	return nil
+}
+
+func indexZipArchive(archivePath string) error {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, file := range reader.File {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		if name, ok := addSourceEntry(file.Name, seen); ok {
+			sourceEntries = append(sourceEntries, sourceEntry{name: name, size: int64(file.UncompressedSize64), modTime: file.Modified, zipFile: file})
+		}
+	}
+	sourceZip = reader // Kept open for the whole session, entries are read from it on demand
+	return nil
+}
+
+func indexTarArchive(archivePath string) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if name, ok := addSourceEntry(header.Name, seen); ok {
+			sourceEntries = append(sourceEntries, sourceEntry{name: name, size: header.Size, modTime: header.ModTime})
+		}
+	}
+}
+
+// addSourceEntry checks the name of a file entry and reports the name it is shared under
+func addSourceEntry(rawName string, seen map[string]bool) (string, bool) {
+	name, ok := sourceEntryName(rawName)
+	if !ok {
+		log.Printf("Warning: %s in -from-archive has an unsafe name and is not shared", rawName)
+		return "", false
+	}
+	if seen[name] {
+		log.Printf("Warning: %s appears more than once in -from-archive, only the first one is shared", name)
+		return "", false
+	}
+	seen[name] = true
+	return name, true
+}
+
+// findSourceEntry looks up a shared entry by name
+func findSourceEntry(name string) (sourceEntry, bool) {
+	i, found := slices.BinarySearchFunc(sourceEntries, name, func(entry sourceEntry, name string) int {
+		return strings.Compare(entry.name, name)
+	})
+	if !found {
+		return sourceEntry{}, false
+	}
+	return sourceEntries[i], true
+}
+
+// getSourceEntries returns the entries of the archive for the download list
+func getSourceEntries() []DownloadFileInfo {
+	files := make([]DownloadFileInfo, 0, len(sourceEntries))
+	for _, entry := range sourceEntries {
+		files = append(files, DownloadFileInfo{
+			FileName: path.Base(entry.name),
+			RelPath:  entry.name,
+			Size:     entry.size,
+			ModTime:  entry.modTime,
+			Kind:     fileKind(entry.name),
+			Exists:   true,
+		})
+	}
+	return files
+}
+
+// openSourceEntry returns a reader for the content of entry
+func openSourceEntry(entry sourceEntry) (io.ReadCloser, error) {
+	if entry.zipFile != nil {
+		return entry.zipFile.Open()
+	}
+
+	file, err := os.Open(sourceArchivePath)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err != nil {
+			file.Close()
+			if err == io.EOF {
+				err = fmt.Errorf("%s is no longer in the archive", entry.name)
+			}
+			return nil, err
+		}
+		if name, ok := sourceEntryName(header.Name); ok && name == entry.name && header.Typeflag == tar.TypeReg {
+			return struct {
+				io.Reader
+				io.Closer
+			}{reader, file}, nil
+		}
+	}
+}
+
+// serveSourceEntry answers /download/ from the -from-archive archive: whole entries only
+// (no Range), read out of the archive while they are sent
+func serveSourceEntry(w http.ResponseWriter, r *http.Request) {
+	name, err := url.PathUnescape(strings.TrimPrefix(r.URL.Path, "/download/"))
+	if err != nil || !validStorageName(name) {
+		http.Error(w, "Invalid file path", http.StatusBadRequest)
+		return
+	}
+	entry, ok := findSourceEntry(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("File %s does not exist", name), http.StatusNotFound)
+		return
+	}
+
+	fileName := path.Base(name)
+	if override := r.URL.Query().Get("name"); override != "" {
+		fileName = sanitizeDownloadName(override, fileName)
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", contentDisposition(fileName))
+	w.Header().Set("Accept-Ranges", "none")
+	w.Header().Set("Content-Length", fmt.Sprint(entry.size))
+	if !entry.modTime.IsZero() {
+		w.Header().Set("Last-Modified", entry.modTime.UTC().Format(http.TimeFormat))
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	body, err := openSourceEntry(entry)
+	if err != nil {
+		w.Header().Del("Content-Length")
+		serverError(w, fmt.Sprintf("Failed to read %s from the archive", name), err)
+		return
+	}
+	defer body.Close()
+
+	progress := newProgressPrinter(fileName, entry.size)
+	defer progress.finish()
+	written, err := io.CopyBuffer(io.MultiWriter(w, progressWriter{progress}), io.LimitReader(body, entry.size), make([]byte, transferBufSize))
+	metricBytesDownloaded.Add(written)
+	if err == nil && written < entry.size {
+		err = errors.New("the entry is shorter than listed")
+	}
+	if err == nil {
+		// Reading on to the end makes the zip reader verify the entry's checksum
+		if extra, readErr := io.Copy(io.Discard, body); readErr != nil {
+			err = readErr
+		} else if extra > 0 {
+			err = errors.New("the entry is longer than listed")
+		}
+	}
+	if err != nil {
+		fmt.Printf("Download of %s from %s ended after %d of %d bytes: %v\n", name, sourceArchivePath, written, entry.size, err)
+		panic(http.ErrAbortHandler) // The client must not take a damaged body for the whole file
+	}
+	metricDownloads.Add(1)
+	recordTransfer(r, "download", fileName, written)
+	countDownload()
+}
+
+// sourceArchiveConflicts returns the given download flags that work on files on disk, which
+// -from-archive refuses instead of silently ignoring
+func sourceArchiveConflicts() []string {
+	var conflicts []string
+	for name, used := range map[string]bool{
+		"-f":                     allowSingleFilePath != "",
+		"-x":                     len(allowMultiFilePaths) > 0,
+		"-d":                     sharedDir != "",
+		"-s3-bucket":             s3Bucket != "",
+		"-transform-cmd":         len(transformCommands) > 0,
+		"-confirm":               confirmDownloads,
+		"-tail":                  tailMode,
+		"-snapshot":              snapshotDownloads,
+		"-precompressed":         precompressed,
+		"-detect-changes":        detectChanges,
+		"-delete-after-download": deleteAfterDownload,
+	} {
+		if used {
+			conflicts = append(conflicts, name)
+		}
+	}
+	slices.Sort(conflicts)
+	return conflicts
+}
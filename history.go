@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Transfer history (via -history): one JSON object per line, appended as transfers complete,
+// so recent activity survives restarts. Unlike /metrics this is per transfer, not a counter.
+const (
+	maxHistoryEntries = 500 // Older entries are dropped from the page and when the file is compacted
+	historyTimeFormat = "2006-01-02 15:04:05"
+	historyFileName   = "history.jsonl" // Default file inside the state directory (-state-dir)
+)
+
+// HistoryEntry is one completed transfer
+type HistoryEntry struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"` // "upload" or "download"
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	Client    string    `json:"client"`
+}
+
+var (
+	history   []HistoryEntry // Loaded at startup, oldest first, at most maxHistoryEntries
+	historyMu sync.Mutex
+)
+
+// loadHistory reads the history file (a missing file is an empty history) and rewrites it
+// without the entries beyond the cap. Malformed lines are skipped.
+func loadHistory(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var entries []HistoryEntry
+	total := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			continue
+		}
+		total++
+		entries = append(entries, entry)
+		if len(entries) > maxHistoryEntries {
+			entries = entries[1:]
+		}
+	}
+	file.Close()
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	historyMu.Lock()
+	history = entries
+	historyMu.Unlock()
+
+	if total > len(entries) {
+		return compactHistory(path, entries)
+	}
+	return nil
+}
+
+// compactHistory replaces the history file with just the given entries
+func compactHistory(path string, entries []HistoryEntry) error {
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			file.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// recordTransfer appends a completed transfer to the history (no-op without -history).
+// Write failures are only logged: the transfer itself has already succeeded.
+func recordTransfer(r *http.Request, direction, name string, size int64) {
+	if !historyEnabled {
+		return
+	}
+	entry := HistoryEntry{Time: time.Now(), Direction: direction, Name: name, Size: size, Client: clientIP(r)}
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	history = append(history, entry)
+	if len(history) > maxHistoryEntries {
+		history = history[len(history)-maxHistoryEntries:]
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	file, err := os.OpenFile(historyPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		fmt.Printf("Failed to write history: %v\n", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		fmt.Printf("Failed to write history: %v\n", err)
+	}
+}
+
+// historyTemplate renders the history newest first; html/template escapes file names
+var historyTemplate = template.Must(template.New("history").Funcs(template.FuncMap{
+	"sessionFooter": sessionFooter,
+	"pageTitle":     pageTitle,
+	"when":          func(t time.Time) string { return t.Local().Format(historyTimeFormat) },
+	"size":          formatFileSize,
+	"arrow": func(direction string) string {
+		if direction == "upload" {
+			return "⬆️"
+		}
+		return "⬇️"
+	},
+}).Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{pageTitle "Transfer History"}}</title>
+    <style>
+        * {
+            margin: 0;
+            padding: 0;
+            box-sizing: border-box;
+        }
+
+        body {
+            max-width: 800px;
+            margin: 0 auto;
+            padding: 20px 15px;
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Arial, sans-serif;
+            line-height: 1.5;
+        }
+
+        h1 {
+            font-size: 1.8rem;
+            color: #333;
+            text-align: center;
+            margin-bottom: 20px;
+        }
+
+        table {
+            width: 100%;
+            border-collapse: collapse;
+        }
+
+        td {
+            padding: 8px 6px;
+            border-bottom: 1px solid #eee;
+            word-break: break-all;
+        }
+
+        .meta {
+            color: #999;
+            font-size: 0.8rem;
+            white-space: nowrap;
+        }
+
+        .empty-message {
+            text-align: center;
+            color: #666;
+            margin: 20px 0;
+        }
+
+        .back-link {
+            display: inline-block;
+            margin-top: 20px;
+            color: #4285f4;
+            text-decoration: none;
+        }
+    </style>
+</head>
+<body>
+    <h1>Transfer History</h1>
+    {{if .Entries}}
+    <table>
+        {{range .Entries}}
+        <tr>
+            <td>{{arrow .Direction}}</td>
+            <td>{{.Name}}</td>
+            <td class="meta">{{size .Size}}</td>
+            <td class="meta">{{when .Time}}<br>{{.Client}}</td>
+        </tr>
+        {{end}}
+    </table>
+    {{else}}
+    <div class="empty-message">No transfers recorded yet</div>
+    {{end}}
+    <a href="{{.UploadPage}}" class="back-link">← Back to Upload</a>
+    {{sessionFooter}}
+</body>
+</html>
+`))
+
+// historyHandler shows the recorded transfers, newest first
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	historyMu.Lock()
+	entries := make([]HistoryEntry, len(history))
+	for i, entry := range history {
+		entries[len(history)-1-i] = entry
+	}
+	historyMu.Unlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	historyTemplate.Execute(w, struct {
+		Entries    []HistoryEntry
+		UploadPage string
+	}{entries, routePath(uploadPagePath())})
+}
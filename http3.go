@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// Experimental HTTP/3 (via -http3): the same handlers are also served over QUIC on the UDP
+// ports matching -ports, and every HTTPS response advertises them in an Alt-Svc header.
+// Browsers that support HTTP/3 switch over for later requests; everything else simply
+// keeps using HTTP/1.1 or HTTP/2 over TCP.
+
+// altSvcMaxAge is how long (in seconds) browsers may remember the Alt-Svc advertisement
+const altSvcMaxAge = 3600
+
+// altSvcMiddleware advertises HTTP/3 on the UDP port matching the TCP port the client used
+func altSvcMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		port := listenPorts[0]
+		if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+			if _, localPort, err := net.SplitHostPort(addr.String()); err == nil {
+				port = localPort
+			}
+		}
+		w.Header().Set("Alt-Svc", fmt.Sprintf(`h3=":%s"; ma=%d`, port, altSvcMaxAge))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// startHTTP3 serves handler over QUIC on every -ports port (on UDP) and returns the server so
+// it can be closed together with the TCP one. Ports that can't be bound are only reported.
+func startHTTP3(listenHost string, handler http.Handler, tlsConfig *tls.Config) *http3.Server {
+	server := &http3.Server{Handler: handler, TLSConfig: http3.ConfigureTLSConfig(tlsConfig)}
+	for _, port := range listenPorts {
+		conn, err := net.ListenPacket("udp", net.JoinHostPort(listenHost, port))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to start HTTP/3 on UDP port %s, clients will use TCP: %v\n", port, err)
+			continue
+		}
+		go func() {
+			if err := server.Serve(conn); err != nil && err != http.ErrServerClosed {
+				fmt.Fprintf(os.Stderr, "HTTP/3 on UDP port %s stopped: %v\n", port, err)
+			}
+		}()
+	}
+	return server
+}
+
+// stopHTTP3 closes the HTTP/3 server, if there is one, after its requests have finished
+func stopHTTP3(server *http3.Server) {
+	if server != nil {
+		server.Shutdown(context.Background())
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// File kinds shown on the download list (the icons via -icons), derived from the extension
+const (
+	kindImage    = "image"
+	kindVideo    = "video"
+	kindAudio    = "audio"
+	kindArchive  = "archive"
+	kindDocument = "document"
+	kindCode     = "code"
+	kindOther    = "file"
+)
+
+// kindExtensions maps lowercase extensions (with the dot) to their kind
+var kindExtensions = map[string]string{}
+
+func init() {
+	for kind, exts := range map[string]string{
+		kindImage:    ".jpg .jpeg .png .gif .webp .heic .heif .bmp .tif .tiff .svg .ico .raw .dng",
+		kindVideo:    ".mp4 .mov .m4v .mkv .webm .avi .wmv .flv .3gp .mpg .mpeg",
+		kindAudio:    ".mp3 .m4a .aac .wav .flac .ogg .opus .wma",
+		kindArchive:  ".zip .tar .gz .tgz .bz2 .xz .zst .7z .rar .iso .dmg .apk .ipa",
+		kindDocument: ".pdf .doc .docx .odt .rtf .txt .md .xls .xlsx .ods .csv .ppt .pptx .odp .epub .pages .numbers .key",
+		kindCode:     ".go .c .h .cpp .hpp .rs .py .js .ts .java .kt .swift .rb .php .sh .ps1 .html .css .json .xml .yaml .yml .toml .sql",
+	} {
+		for _, ext := range strings.Fields(exts) {
+			kindExtensions[ext] = kind
+		}
+	}
+}
+
+// kindIcons are the emoji shown in front of file names with -icons
+var kindIcons = map[string]string{
+	kindImage:    "🖼️",
+	kindVideo:    "🎬",
+	kindAudio:    "🎵",
+	kindArchive:  "📦",
+	kindDocument: "📄",
+	kindCode:     "📝",
+	kindOther:    "📁",
+}
+
+// fileKind classifies a file name by its extension (kindOther if it is unknown)
+func fileKind(name string) string {
+	if kind, ok := kindExtensions[strings.ToLower(filepath.Ext(name))]; ok {
+		return kind
+	}
+	return kindOther
+}
+
+// kindIcon returns the list icon of a file with -icons, as HTML ready to put before its name
+func kindIcon(kind string) string {
+	if !fileIcons {
+		return ""
+	}
+	return `<span class="kind-icon" title="` + kind + `">` + kindIcons[kind] + `</span> `
+}
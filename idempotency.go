@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Idempotency-Key support for /upload: a retried request with the same key gets the
+// original success response instead of saving the files again (or failing with 409)
+const (
+	idempotencyHeader     = "Idempotency-Key"
+	idempotencyTTL        = time.Hour // Completed keys are forgotten after this long
+	maxIdempotencyEntries = 1000      // Upper bound on remembered keys
+	maxIdempotencyKeyLen  = 255
+)
+
+// idempotencyEntry is either an upload in progress (done == false) or its stored response
+type idempotencyEntry struct {
+	done     bool
+	response string
+	expires  time.Time
+}
+
+var (
+	idempotencyKeys   = make(map[string]*idempotencyEntry)
+	idempotencyKeysMu sync.Mutex
+)
+
+// beginIdempotent handles a request carrying an Idempotency-Key. It returns true if the
+// response was already written (replay or conflict); otherwise the key is marked in progress.
+func beginIdempotent(w http.ResponseWriter, key string) bool {
+	if len(key) > maxIdempotencyKeyLen {
+		http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+		return true
+	}
+
+	idempotencyKeysMu.Lock()
+	defer idempotencyKeysMu.Unlock()
+	pruneIdempotentLocked()
+
+	if entry, ok := idempotencyKeys[key]; ok {
+		if !entry.done {
+			http.Error(w, "A request with this Idempotency-Key is still in progress", http.StatusConflict)
+			return true
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(entry.response))
+		return true
+	}
+
+	idempotencyKeys[key] = &idempotencyEntry{expires: time.Now().Add(idempotencyTTL)}
+	return false
+}
+
+// finishIdempotent stores the success response for key, or forgets the key if the upload failed
+// (so the client can retry it)
+func finishIdempotent(key string, success bool, response string) {
+	idempotencyKeysMu.Lock()
+	defer idempotencyKeysMu.Unlock()
+
+	if !success {
+		delete(idempotencyKeys, key)
+		return
+	}
+	idempotencyKeys[key] = &idempotencyEntry{done: true, response: response, expires: time.Now().Add(idempotencyTTL)}
+}
+
+// pruneIdempotentLocked drops expired keys and, if still over the cap, the oldest completed ones
+func pruneIdempotentLocked() {
+	now := time.Now()
+	for key, entry := range idempotencyKeys {
+		if now.After(entry.expires) {
+			delete(idempotencyKeys, key)
+		}
+	}
+	for len(idempotencyKeys) >= maxIdempotencyEntries {
+		oldestKey := ""
+		var oldest time.Time
+		for key, entry := range idempotencyKeys {
+			if entry.done && (oldestKey == "" || entry.expires.Before(oldest)) {
+				oldestKey, oldest = key, entry.expires
+			}
+		}
+		if oldestKey == "" {
+			return
+		}
+		delete(idempotencyKeys, oldestKey)
+	}
+}
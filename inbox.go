@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Inbox mode (via -inbox): every upload goes into a folder of the upload directory named after
+// the sender, taken from the "inbox" form field (the upload page asks for it) or ?inbox= query
+const (
+	inboxField         = "inbox"
+	inboxDefaultFolder = "unnamed" // For uploads without a name
+	maxInboxNameLen    = 64
+)
+
+// inboxFolder turns a sender's name into a folder name: letters and digits are kept, spaces
+// become "-", anything else is dropped (so no separators, dots or traversal can get through)
+func inboxFolder(name string) string {
+	var folder strings.Builder
+	for _, r := range strings.TrimSpace(name) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			folder.WriteRune(r)
+		case unicode.IsSpace(r):
+			folder.WriteRune('-')
+		}
+		if folder.Len() >= maxInboxNameLen {
+			break
+		}
+	}
+	if cleaned := strings.Trim(folder.String(), "-_"); cleaned != "" {
+		return cleaned
+	}
+	return inboxDefaultFolder
+}
+
+// uploadDir returns the directory an upload from the named sender is saved into, creating
+// it if needed: the upload directory itself, or the sender's folder in it with -inbox
+func uploadDir(sender string) (string, error) {
+	if !inboxMode {
+		return currentWorkDir, nil
+	}
+	dir := filepath.Join(currentWorkDir, inboxFolder(sender))
+	if err := os.MkdirAll(dir, uploadDirMode); err != nil {
+		return "", fmt.Errorf("failed to create inbox folder: %w", err)
+	}
+	return dir, nil
+}
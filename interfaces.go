@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/jackpal/gateway"
+)
+
+// rankNames describe the interface ranks in the -list-interfaces output
+var rankNames = map[int]string{
+	ifaceRankPhysical: "physical",
+	ifaceRankVirtual:  "virtual/VPN",
+	ifaceRankDocker:   "container",
+}
+
+// listInterfaces prints every network interface with its flags and addresses, how the address
+// selection sees each of them, and the address that would be advertised (via -list-interfaces)
+func listInterfaces(w io.Writer) error {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve network interfaces: %w", err)
+	}
+
+	gwIP, gwErr := gateway.DiscoverGateway()
+	if gwErr != nil {
+		fmt.Fprintf(w, "Default gateway: not found (%v)\n", gwErr)
+	} else {
+		fmt.Fprintf(w, "Default gateway: %s\n", gwIP)
+	}
+
+	fmt.Fprintln(w, "\nNetwork interfaces:")
+	for _, iface := range interfaces {
+		kind := rankNames[interfaceRank(iface.Name)]
+		if iface.Flags&net.FlagLoopback != 0 {
+			kind = "loopback"
+		}
+		flags := iface.Flags.String()
+		if iface.Flags&net.FlagUp == 0 {
+			flags = "down|" + flags
+		}
+		fmt.Fprintf(w, "  %s (%s): %s\n", iface.Name, kind, flags)
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			fmt.Fprintf(w, "    failed to get addresses: %v\n", err)
+			continue
+		}
+		if len(addrs) == 0 {
+			fmt.Fprintln(w, "    no addresses")
+		}
+		for _, addr := range addrs {
+			fmt.Fprintf(w, "    %-42s %s\n", addr, describeAddr(iface, addr, gwIP))
+		}
+	}
+
+	ip, reason, err := selectLocalIP()
+	if err != nil {
+		fmt.Fprintf(w, "\nNo address selected: %v\n", err)
+		return nil
+	}
+	fmt.Fprintf(w, "\nAdvertised address: %s\n  because %s\n", ip, reason)
+	return nil
+}
+
+// describeAddr says whether the address selection can use addr, and how
+func describeAddr(iface net.Interface, addr net.Addr, gwIP net.IP) string {
+	var notes []string
+	if iface.Flags&net.FlagUp == 0 {
+		notes = append(notes, "not used: interface is down")
+	} else if ipv4, ipnet := usableIPv4(addr); ipv4 != nil {
+		notes = append(notes, "IPv4 candidate")
+		if gwIP != nil && ipnet.Contains(gwIP) {
+			notes = append(notes, "same subnet as the gateway")
+		}
+	} else if ip, linkLocal := usableIPv6(addr, iface.Name); ip != "" && iface.Flags&net.FlagLoopback == 0 {
+		if linkLocal {
+			notes = append(notes, "IPv6 link-local candidate (last resort, as "+ip+")")
+		} else {
+			notes = append(notes, "IPv6 global candidate")
+		}
+	} else {
+		notes = append(notes, "not used: loopback or not a unicast address")
+	}
+	return strings.Join(notes, ", ")
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// Share-wide download limit (via -max-downloads): once this many downloads have completed,
+// every file stops being served. Downloads already running when the limit is reached may
+// still finish, so the final count can end up slightly above the limit.
+var (
+	completedDownloads atomic.Int64          // Completed full downloads (Range requests are not counted)
+	shareClosed        = make(chan struct{}) // Closed when the limit is reached
+	closeShareOnce     sync.Once
+)
+
+// shareExpired writes 410 Gone and returns true once the -max-downloads limit has been reached
+func shareExpired(w http.ResponseWriter) bool {
+	if maxDownloads <= 0 || completedDownloads.Load() < maxDownloads {
+		return false
+	}
+	http.Error(w, fmt.Sprintf("This share has expired after %d downloads", maxDownloads), http.StatusGone)
+	return true
+}
+
+// countDownload records one completed download and closes the share when it hits the limit
+func countDownload() {
+	count := completedDownloads.Add(1)
+	if maxDownloads <= 0 || count < maxDownloads {
+		return
+	}
+	closeShareOnce.Do(func() {
+		fmt.Printf("Download limit of %d reached, files are no longer served\n", maxDownloads)
+		close(shareClosed)
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/subtle"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// listTokenParam is the query parameter that carries -list-token (also accepted as X-List-Token)
+const listTokenParam = "list-token"
+
+// listAuthorized reports whether the request may see the whole catalog: the download list,
+// /api/files and the archives. Always true without -list-token; /download/ links never check it.
+func listAuthorized(r *http.Request) bool {
+	if listToken == "" {
+		return true
+	}
+	for _, token := range []string{r.Header.Get("X-List-Token"), r.URL.Query().Get(listTokenParam)} {
+		if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(listToken)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// listHidden rejects a catalog request without the list token with 401
+func listHidden(w http.ResponseWriter, r *http.Request) bool {
+	if listAuthorized(r) {
+		return false
+	}
+	http.Error(w, "List token required (direct download links work without it)", http.StatusUnauthorized)
+	return true
+}
+
+// withListToken adds list-token=... to the query of a catalog link under -list-token
+func withListToken(link string) string {
+	if listToken == "" {
+		return link
+	}
+	separator := "?"
+	if strings.Contains(link, "?") {
+		separator = "&"
+	}
+	return link + separator + listTokenParam + "=" + url.QueryEscape(listToken)
+}
+
+// listTokenField is a hidden form field that keeps the list token in a GET form's query
+func listTokenField() string {
+	if listToken == "" {
+		return ""
+	}
+	return `<input type="hidden" name="` + listTokenParam + `" value="` + template.HTMLEscapeString(listToken) + `">`
+}
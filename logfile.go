@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"sync"
+)
+
+// rotatingLog is the -log-file that log output (warnings and the -v access log) goes to instead
+// of the terminal. Once a write would take it past -log-max-size, the file is renamed to FILE.1
+// (replacing the previous one) and a new one is started, so at most twice the limit is kept.
+type rotatingLog struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64 // 0 = never rotate
+	file    *os.File
+	size    int64
+}
+
+// openRotatingLog opens (appending to) or creates the log file at path
+func openRotatingLog(path string, maxSize int64) (*rotatingLog, error) {
+	l := &rotatingLog{path: path, maxSize: maxSize}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *rotatingLog) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	l.file, l.size = file, info.Size()
+	return nil
+}
+
+func (l *rotatingLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(p)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			// Keep logging into the old file rather than losing lines
+			os.Stderr.WriteString("Failed to rotate log file " + l.path + ": " + err.Error() + "\n")
+		}
+	}
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// rotate moves the current file to FILE.1 and starts a new one
+func (l *rotatingLog) rotate() error {
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return err
+	}
+	old := l.file
+	if err := l.open(); err != nil {
+		l.file = old // Still open, now under the .1 name
+		return err
+	}
+	old.Close()
+	return nil
+}
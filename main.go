@@ -1,37 +1,148 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/jackpal/gateway"
 	"github.com/mdp/qrterminal/v3"
+	"github.com/quic-go/quic-go/http3"
+	"html/template"
 	"io"
+	"io/fs"
 	"log"
+	"math"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"rsc.io/qr"
+	"slices"
 	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 )
 
-// Global variables
+// Global variables: set from the flags in main before the server starts and only read by
+// handlers afterwards. State that changes while serving (counters, notes, history, tokens...)
+// lives next to its feature behind its own mutex or atomic, never in a plain global;
+// TestConcurrentRequests checks the handlers for races under go test -race.
 var (
-	allowSingleFilePath string   // Single file allowed (via -f)
-	allowMultiFilePaths []string // Multiple files allowed (via -x, comma-separated)
-	currentWorkDir      string   // Current working directory (absolute path)
-	showHelp            bool     // Show help information (via -h)
+	allowSingleFilePath string        // Single file allowed (via -f)
+	allowMultiFilePaths []string      // Multiple files allowed (via -x, comma-separated)
+	sharedDir           string        // Directory whose files are all allowed, recursively (via -d)
+	sourceArchive       string        // .zip or .tar.gz whose entries are shared without extracting them (via -from-archive)
+	globPatterns        []string      // Only share -d files whose base name matches one of these (via -glob)
+	excludePatterns     []string      // Omit -d files and folders matching one of these by name or relative path (via -exclude)
+	currentWorkDir      string        // Current working directory (absolute path)
+	showHelp            bool          // Show help information (via -h)
+	showVersion         bool          // Print the build version and exit (via -version)
+	transferBufSize     int           // Buffer size used by upload/download loops (via -bufsize)
+	disableQR           bool          // Skip terminal QR code generation (via -no-qr)
+	qrSVGPath           string        // Also write the QR code as SVG to this file, "-" for stdout (via -qr-svg)
+	allQRCodes          bool          // Also print a QR code for the upload page next to the download one (via -qr-all)
+	qrInline            bool          // Put a small -f file into the QR code instead of its URL (via -qr-inline)
+	statusLine          bool          // Keep a live count of received files and bytes below the QR code (via -status)
+	printURLs           bool          // Print "type=URL" lines to stdout after binding, everything else to stderr (via -print-urls)
+	quietMode           bool          // Suppress the banner, QR codes and activity messages; errors are still shown (via -quiet)
+	preferredIface      string        // Interface preferred for the advertised/QR address (via -prefer)
+	requiredIface       string        // Interface whose global address is advertised, without fallback (via -iface)
+	metricsEnabled      bool          // Expose Prometheus-style counters on /metrics (via -metrics)
+	zipPassword         string        // Password for /download-zip archives (via -zip-pass)
+	zipEncryptionName   string        // Encryption scheme for password-protected archives (via -zip-enc)
+	singleFileAlias     string        // Download name offered for the -f file (via -as)
+	uploadIdleTimeout   time.Duration // Abort uploads that make no progress for this long (via -upload-idle-timeout, 0 = never)
+	fsyncUploads        bool          // Flush each saved upload to disk before reporting success (via -fsync)
+	asciiOutput         bool          // Plain ASCII terminal output, no emoji/block characters (via -ascii or non-UTF-8 locale)
+	followSymlinks      bool          // Serve symlink targets outside the working directory (via -follow-symlinks)
+	realWorkDir         string        // currentWorkDir with symlinks resolved (for symlink containment checks)
+	verbose             bool          // Print transfer progress and extra diagnostics (via -v)
+	stripMetadata       bool          // Remove EXIF/GPS metadata from uploaded JPEG/PNG images (via -strip-metadata)
+	uploadToken         string        // Token required for uploads; downloads stay open (via -upload-token)
+	notesEnabled        bool          // Serve the in-memory notes board on /notes (via -notes)
+	uploadFileMode      os.FileMode   // Permissions of saved uploads (via -file-mode)
+	uploadDirMode       os.FileMode   // Permissions of created upload directories (via -dir-mode)
+	mirrorTarget        string        // Directory or URL that saved uploads are copied to (via -mirror)
+	ignoreCase          bool          // Match download paths case-insensitively (via -ignore-case)
+	allowedCIDRs        []*net.IPNet  // Client address ranges allowed to connect (via -allow-cidr, empty = all)
+	unzipUploads        bool          // Extract uploaded .zip archives after saving (via -unzip)
+	unzipDir            string        // Subfolder of the upload directory to extract into (via -unzip-dir)
+	sandboxMode         bool          // Read-only: reject uploads and every feature that writes to disk (via -sandbox)
+	stateDir            string        // Directory for state.json and other data kept across restarts (via -state-dir)
+	manageEnabled       bool          // Serve /admin/uploads to list, download and delete received files (via -manage)
+	dedupeEnabled       bool          // Delete uploads whose content already exists in the upload directory (via -dedupe)
+	historyEnabled      bool          // Record completed transfers on disk and serve /history (via -history)
+	historyPath         string        // JSON-lines transfer history file (via -history-file)
+	tlsEnabled          bool          // Serve HTTPS, with a self-signed certificate unless -cert is given (via -tls)
+	tlsCertFile         string        // PEM certificate for HTTPS (via -cert)
+	tlsKeyFile          string        // PEM private key for -cert (via -key)
+	tlsClientCAFile     string        // PEM CA certificates that clients must present a certificate from (via -client-ca)
+	tlsMinVersionName   string        // Minimum accepted TLS version (via -tls-min)
+	tlsCipherNames      string        // TLS 1.2 cipher suites, comma-separated (via -tls-ciphers, empty = modern set)
+	messageHTML         string        // Instructions shown above the upload form (via -message, text or .html/.md file)
+	defaultPage         string        // Page served on / (via -default: upload, downloads or code)
+	detectChanges       bool          // Report files modified mid-download in an X-Content-Changed trailer (via -detect-changes)
+	precompressed       bool          // Serve FILE.gz with Content-Encoding: gzip when FILE is requested (via -precompressed)
+	snapshotDownloads   bool          // Serve a temp copy of each file for consistent downloads (via -snapshot)
+	tailMode            bool          // Follow files on /download/ like tail -f, streaming appended data (via -tail)
+	confirmDownloads    bool          // Show a name/size confirmation page before each download (via -confirm)
+	uploadRedirect      string        // URL browsers are sent to after a successful upload (via -upload-redirect)
+	listenPorts         []string      // TCP ports to listen on, the first one is advertised (via -ports, default 8080)
+	confirmClients      bool          // Ask on the terminal before serving a new client IP (via -confirm-clients)
+	bindLAN             bool          // Listen only on the advertised LAN address instead of all interfaces (via -bind-lan)
+	selfTest            bool          // Request the advertised URL after startup to check it answers (via -selftest)
+	listIfaces          bool          // Print the network interfaces and the address selection, then exit (via -list-interfaces)
+	sniffAllowTypes     []string      // Media types uploads must have, detected from their content (via -sniff-allow)
+	maxFileSize         int64         // Largest accepted size of a single uploaded file (via -max-file-size, 0 = unlimited)
+	conflictMode        string        // Uploads to a taken name with different content: error, rename or overwrite (via -on-conflict)
+	uploadCommand       string        // Shell command each uploaded file is streamed into instead of being saved (via -upload-cmd)
+	maxDownloads        int64         // Stop serving files after this many completed downloads (via -max-downloads, 0 = unlimited)
+	exitAfterDownloads  bool          // Shut the server down once -max-downloads is reached (via -max-downloads-exit)
+	archiveFormat       string        // Archive the list page's Download All button offers: zip or tar.gz (via -archive)
+	zipWarnSize         int64         // Confirm /download-zip when the files add up to more than this (via -zip-warn, 0 = never)
+	listPageSize        int           // Files per /downloads page (via -per-page, 0 = all on one page)
+	basePath            string        // URL prefix all routes and links live under, e.g. /share (via -base-path, empty = none)
+	serverName          string        // Server header sent with every response (via -server-name, empty = none)
+	fileIcons           bool          // Show a file type icon in front of each name on the download list (via -icons)
+	inboxMode           bool          // Save uploads into a folder named after the sender (via -inbox)
+	maxTotalUpload      int64         // Cap on the bytes saved by all uploads of this session (via -max-total, 0 = unlimited)
+	singleUpload        bool          // Accept exactly one file per upload request (via -single-upload)
+	http3Enabled        bool          // Also serve HTTP/3 over QUIC and advertise it via Alt-Svc, needs TLS (via -http3)
+	deleteAfterDownload bool          // Remove a file from disk once it has been downloaded in full (via -delete-after-download)
+	noJSForm            bool          // Serve a plain HTML form upload page without JavaScript (via -no-js)
+	listToken           string        // Token required to see the download list, /api/files and archives (via -list-token)
+	adminToken          string        // Token required for the /admin/ endpoints from other computers (via -admin-token)
+	siteTitle           string        // Name of the share shown in page titles, headings and QR prompts (via -title)
+	s3Bucket            string        // S3 bucket that uploads are moved to and downloads come from (via -s3-bucket)
+	s3Prefix            string        // Key prefix inside the bucket (via -s3-prefix)
+	s3Region            string        // Region the bucket is in (via -s3-region)
+	s3Endpoint          string        // S3-compatible service to use instead of AWS (via -s3-endpoint)
+	logFile             string        // File that log output goes to instead of the terminal (via -log-file)
+	logMaxSize          int64         // Size at which the -log-file is rotated (via -log-max-size, 0 = never)
+	apiSpecEnabled      bool          // Serve an OpenAPI description of the HTTP API on /openapi.json (via -api)
+	diskRate            int64         // Cap on the bytes per second written by uploads (via -disk-rate, 0 = unlimited)
+	maxFormSize         int64         // Cap on the non-file fields of an upload form in bytes (via -max-form-size, 0 = Go's 10 MB)
+	maxFormFields       int           // Cap on the number of non-file fields of an upload form (via -max-form-fields, 0 = unlimited)
 )
 
 // DownloadFileInfo represents file info for download list page
 type DownloadFileInfo struct {
-	FileName string // Just the filename (e.g., test.txt)
-	RelPath  string // Relative path to current dir (e.g., uploads/test.txt)
-	AbsPath  string // Absolute path (e.g., /home/user/app/uploads/test.txt)
-	Size     int64  // File size in bytes
-	Exists   bool   // Whether the file exists
+	FileName string    // Just the filename (e.g., test.txt)
+	RelPath  string    // Relative path to current dir (e.g., uploads/test.txt)
+	Alias    string    // Friendly name from -x alias=path, used in links instead of RelPath ("" if none)
+	AbsPath  string    // Absolute path (e.g., /home/user/app/uploads/test.txt)
+	Size     int64     // File size in bytes
+	ModTime  time.Time // Last modification time (zero if the file does not exist)
+	Kind     string    // File type from the extension: image, video, audio, archive, document, code or file
+	Exists   bool      // Whether the file exists
 }
 
 // Use ascii blocks to form the QR Code
@@ -40,37 +151,295 @@ const BLACK_BLACK = " "
 const WHITE_BLACK = "▀"
 const WHITE_WHITE = "█"
 
+// ASCII-only QR modules (used with -ascii): two characters per module keep it square
+const ASCII_BLACK = "  "
+const ASCII_WHITE = "##"
+
+// qrConfig returns the terminal QR code settings (half blocks, or plain ASCII with -ascii)
+func qrConfig() qrterminal.Config {
+	config := qrterminal.Config{
+		Level:          qrterminal.M,
+		Writer:         os.Stdout,
+		HalfBlocks:     true,
+		BlackChar:      BLACK_BLACK,
+		WhiteBlackChar: WHITE_BLACK,
+		WhiteChar:      WHITE_WHITE,
+		BlackWhiteChar: BLACK_WHITE,
+		QuietZone:      1,
+	}
+	if asciiOutput {
+		// Full blocks only use BlackChar/WhiteChar
+		config.HalfBlocks = false
+		config.BlackChar = ASCII_BLACK
+		config.WhiteChar = ASCII_WHITE
+	}
+	return config
+}
+
+// qrComfortableLengths is, per error correction level, the longest URL (in bytes) that still fits
+// a version 10 QR code (57x57 modules); longer ones get dense enough that phones struggle
+var qrComfortableLengths = map[qr.Level]int{
+	qrterminal.L: 271,
+	qrterminal.M: 213,
+	qrterminal.H: 119,
+}
+
+// warnLongQRURL warns when a URL will produce a hard to scan QR code at the given level
+func warnLongQRURL(target string, level qr.Level) {
+	limit, ok := qrComfortableLengths[level]
+	if !ok || len(target) <= limit {
+		return
+	}
+	log.Printf("Warning: the QR code URL is %d characters long (over %d), its QR code may be too dense to scan; "+
+		"use a short -x alias=path name, or let recipients type the code on /code instead", len(target), limit)
+}
+
+// printQR prints one labeled QR code: the title above it and the encoded URL in plain text
+// below, so several codes on one screen (or in a photo of it) can be told apart
+func printQR(title, target string, config qrterminal.Config) {
+	fmt.Printf("\n%s%s\n", glyph("📱️", "> "), title)
+	qrterminal.GenerateWithConfig(target, config)
+	fmt.Println(target)
+}
+
+// printShareQRCodes prints the QR code of the share URL (with -qr-all also the upload page's),
+// at startup and again on request (SIGUSR1). Without QR codes only the URLs are printed.
+func printShareQRCodes(prompt, shareURL, baseURL string) {
+	uploadURL := baseURL + uploadPageLink()
+	withUpload := allQRCodes && !sandboxMode && shareURL != uploadURL
+	if disableQR {
+		fmt.Printf("\n%s\n", shareURL)
+		if withUpload {
+			fmt.Println(uploadURL)
+		}
+		return
+	}
+	config := qrConfig()
+	if qrInlineContent != "" {
+		printInlineQR(allowSingleFilePath, shareURL, config)
+	} else {
+		printQR(qrPrompt(prompt), shareURL, config)
+	}
+	if withUpload {
+		printQR(qrPrompt("Scan below qrcode to upload files."), uploadURL, config)
+	}
+}
+
+// Transfer buffer size bounds (for -bufsize)
+const (
+	minBufSize = 4 * 1024         // 4KB
+	maxBufSize = 64 * 1024 * 1024 // 64MB
+)
+
+// maxPerPage bounds the ?per= page size of the download list
+const maxPerPage = 1000
+
+// Interface ranks used to pick the most-likely-reachable address (lower is better)
+const (
+	ifaceRankPhysical = iota // Ethernet/Wi-Fi
+	ifaceRankVirtual         // VPN, VM and tunnel interfaces
+	ifaceRankDocker          // Container bridges and veth pairs
+)
+
+// Name prefixes (lowercase) identifying virtual and container interfaces
+var (
+	dockerIfacePrefixes  = []string{"docker", "br-", "veth", "cni", "flannel", "podman"}
+	virtualIfacePrefixes = []string{"virbr", "vmnet", "vboxnet", "tun", "tap", "wg", "utun", "ppp", "tailscale", "zt", "ham", "vethernet", "vmware", "virtualbox"}
+)
+
 // localIPString adds error return value to expose internal errors to upper layer processing
 // Return values: localIP(string), error
 func localIPString() (string, error) {
+	ip, _, err := selectLocalIP()
+	return ip, err
+}
+
+// selectLocalIP picks the advertised address and also says why it was chosen (for -list-interfaces)
+func selectLocalIP() (string, string, error) {
+	// -iface overrides discovery entirely and fails rather than advertising another network
+	if requiredIface != "" {
+		ip, err := getLocalIPForInterface(requiredIface, true)
+		if err != nil {
+			return "", "", fmt.Errorf("-iface: %w", err)
+		}
+		return ip, fmt.Sprintf("global address of interface %s, set via -iface", requiredIface), nil
+	}
+
+	// An explicitly preferred interface wins; fall back to gateway discovery if it can't be used
+	if preferredIface != "" {
+		ip, err := getLocalIPForInterface(preferredIface, false)
+		if err == nil {
+			return ip, fmt.Sprintf("address of interface %s, preferred via -prefer", preferredIface), nil
+		}
+		log.Printf("Warning: preferred interface not usable, falling back to gateway discovery: %v", err)
+	}
+
 	// Discover the default gateway's IP address
 	gwIP, err := gateway.DiscoverGateway()
 	if err != nil {
+		// IPv6-only networks have no IPv4 default route; advertise an IPv6 address instead
+		if ip, ipv6Err := getLocalIPv6(); ipv6Err == nil {
+			return ip, fmt.Sprintf("no IPv4 default gateway (%v), best ranked IPv6 address", err), nil
+		}
 		// No longer directly Fatal, but return error for upper layer processing
-		return "", fmt.Errorf("failed to discover gateway: %w", err)
+		return "", "", fmt.Errorf("failed to discover gateway: %w", err)
 	}
 
 	// Find the local IP address associated with the interface that connects to the gateway
-	localIP, err := getLocalIPForGateway(gwIP)
+	localIP, ifaceName, err := getLocalIPForGateway(gwIP)
 	if err != nil {
-		return "", fmt.Errorf("failed to find local IP for gateway: %w", err)
+		return "", "", fmt.Errorf("failed to find local IP for gateway: %w", err)
 	}
 
 	// Additional validation: prevent returning nil IP
 	if localIP == nil {
-		return "", fmt.Errorf("local IP address is nil")
+		return "", "", fmt.Errorf("local IP address is nil")
+	}
+
+	return localIP.String(), fmt.Sprintf("interface %s is in the same subnet as the default gateway %s (best ranked match)", ifaceName, gwIP), nil
+}
+
+// interfaceRank classifies an interface by name (physical over virtual, non-Docker over Docker)
+func interfaceRank(name string) int {
+	lower := strings.ToLower(name)
+	for _, prefix := range dockerIfacePrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return ifaceRankDocker
+		}
+	}
+	for _, prefix := range virtualIfacePrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return ifaceRankVirtual
+		}
+	}
+	return ifaceRankPhysical
+}
+
+// usableIPv4 returns the IPv4 address of addr if it is a global unicast, non-loopback address
+func usableIPv4(addr net.Addr) (net.IP, *net.IPNet) {
+	ipnet, ok := addr.(*net.IPNet)
+	if !ok {
+		return nil, nil
+	}
+
+	// Only keep IPv4 addresses, and filter loopback/non-global unicast addresses
+	ipv4 := ipnet.IP.To4()
+	if ipv4 == nil || !ipv4.IsGlobalUnicast() || ipv4.IsLoopback() {
+		return nil, nil
+	}
+	return ipv4, ipnet
+}
+
+// usableIPv6 returns the IPv6 address of addr as a string, with a "%zone" suffix for link-local
+// addresses (they are only reachable through that interface), or "" if addr is not usable
+func usableIPv6(addr net.Addr, ifaceName string) (ip string, linkLocal bool) {
+	ipnet, ok := addr.(*net.IPNet)
+	if !ok || ipnet.IP.To4() != nil || ipnet.IP.To16() == nil || ipnet.IP.IsLoopback() {
+		return "", false
+	}
+	if ipnet.IP.IsLinkLocalUnicast() {
+		return ipnet.IP.String() + "%" + ifaceName, true
+	}
+	if ipnet.IP.IsGlobalUnicast() {
+		return ipnet.IP.String(), false
+	}
+	return "", false
+}
+
+// bestIPv6 picks an interface's IPv6 address, preferring global over link-local ones
+func bestIPv6(addrs []net.Addr, ifaceName string) (ip string, linkLocal bool) {
+	for _, addr := range addrs {
+		candidate, isLinkLocal := usableIPv6(addr, ifaceName)
+		if candidate != "" && (ip == "" || linkLocal && !isLinkLocal) {
+			ip, linkLocal = candidate, isLinkLocal
+		}
+	}
+	return ip, linkLocal
+}
+
+// getLocalIPv6 returns the IPv6 address of the best ranked interface, preferring global
+// addresses; a link-local address (with its zone) is only used if nothing else exists
+func getLocalIPv6() (string, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve network interfaces: %w", err)
 	}
 
-	return localIP.String(), nil
+	bestIP, bestLinkLocal, bestRank := "", false, 0
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		ip, linkLocal := bestIPv6(addrs, iface.Name)
+		if ip == "" {
+			continue
+		}
+		rank := interfaceRank(iface.Name)
+		if bestIP == "" || bestLinkLocal && !linkLocal || bestLinkLocal == linkLocal && rank < bestRank {
+			bestIP, bestLinkLocal, bestRank = ip, linkLocal, rank
+		}
+	}
+
+	if bestIP == "" {
+		return "", fmt.Errorf("no usable IPv6 address found")
+	}
+	return bestIP, nil
+}
+
+// urlHost formats an address for use in a URL: IPv6 addresses are bracketed and the "%" that
+// starts a zone ID is escaped as "%25" (RFC 6874), e.g. [fe80::1%25eth0]
+func urlHost(ip string) string {
+	if !strings.Contains(ip, ":") {
+		return ip
+	}
+	return "[" + strings.Replace(ip, "%", "%25", 1) + "]"
+}
+
+// getLocalIPForInterface returns the first usable IPv4 address of the named interface,
+// falling back to its IPv6 address (global preferred, link-local with zone otherwise unless
+// globalOnly is set)
+func getLocalIPForInterface(name string, globalOnly bool) (string, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", fmt.Errorf("interface %s: %w", name, err)
+	}
+	if iface.Flags&net.FlagUp == 0 {
+		return "", fmt.Errorf("interface %s is down", name)
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", fmt.Errorf("failed to get addresses for interface %s: %w", name, err)
+	}
+	for _, addr := range addrs {
+		if ipv4, _ := usableIPv4(addr); ipv4 != nil {
+			return ipv4.String(), nil
+		}
+	}
+	if ip, linkLocal := bestIPv6(addrs, iface.Name); ip != "" && !(linkLocal && globalOnly) {
+		return ip, nil
+	}
+	if globalOnly {
+		return "", fmt.Errorf("interface %s has no global unicast IPv4 or IPv6 address", name)
+	}
+	return "", fmt.Errorf("interface %s has no usable IP address", name)
 }
 
 // getLocalIPForGateway finds the local IP that is in the same subnet as the gateway IP
-func getLocalIPForGateway(gwIP net.IP) (net.IP, error) {
+// (if several interfaces match, the best ranked one is used) and the name of its interface
+func getLocalIPForGateway(gwIP net.IP) (net.IP, string, error) {
 	interfaces, err := net.Interfaces()
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve network interfaces: %w", err)
+		return nil, "", fmt.Errorf("failed to retrieve network interfaces: %w", err)
 	}
 
+	var bestIP net.IP
+	var bestName string
+	bestRank := 0
 	for _, iface := range interfaces {
 		// Skip disabled network cards
 		if iface.Flags&net.FlagUp == 0 {
@@ -84,33 +453,150 @@ func getLocalIPForGateway(gwIP net.IP) (net.IP, error) {
 			continue
 		}
 
+		rank := interfaceRank(iface.Name)
 		for _, addr := range addrs {
-			ipnet, ok := addr.(*net.IPNet)
-			if !ok {
-				continue
-			}
-
-			// Only keep IPv4 addresses, and filter loopback/non-global unicast addresses
-			ipv4 := ipnet.IP.To4()
-			if ipv4 == nil || !ipv4.IsGlobalUnicast() || ipv4.IsLoopback() {
+			ipv4, ipnet := usableIPv4(addr)
+			if ipv4 == nil {
 				continue
 			}
 
 			// Check if the gateway is in the subnet of the current network card
-			if ipnet.Contains(gwIP) {
-				return ipv4, nil
+			if ipnet.Contains(gwIP) && (bestIP == nil || rank < bestRank) {
+				bestIP, bestName, bestRank = ipv4, iface.Name, rank
 			}
 		}
 	}
 
-	return nil, fmt.Errorf("no local IPv4 address found in the same subnet as gateway %s", gwIP.String())
+	if bestIP == nil {
+		return nil, "", fmt.Errorf("no local IPv4 address found in the same subnet as gateway %s", gwIP.String())
+	}
+	return bestIP, bestName, nil
+}
+
+// defaultPages are the accepted -default values
+var defaultPages = []string{"upload", "downloads", "code"}
+
+// rootHandler serves the -default landing page on / (unknown paths are 404)
+func rootHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	switch defaultPage {
+	case "downloads":
+		downloadsListHandler(w, r)
+	case "code":
+		codeHandler(w, r)
+	default:
+		uploadFormHandler(w, r)
+	}
+}
+
+// routePath prefixes a route with -base-path for use in generated links and redirects
+func routePath(route string) string {
+	return basePath + route
+}
+
+// normalizeBasePath turns "share", "/share/" etc. into "/share" ("" and "/" mean no prefix)
+func normalizeBasePath(p string) (string, error) {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return "", nil
+	}
+	if strings.ContainsAny(p, "?#%") || strings.Contains("/"+p+"/", "/../") || strings.Contains("/"+p+"/", "/./") {
+		return "", fmt.Errorf("invalid path prefix %q", p)
+	}
+	return "/" + p, nil
+}
+
+// registerRoutes adds every endpoint enabled by the flags to mux
+func registerRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/", rootHandler)                    // Root path: upload page (or the -default page)
+	mux.HandleFunc("/upload", uploadHandler)            // Upload API (GET: upload page)
+	mux.HandleFunc("/downloads", downloadsListHandler)  // Download list page (simplified)
+	mux.HandleFunc("/download/", downloadHandler)       // Download API (fixed prefix)
+	mux.HandleFunc("/chunks/", chunksHandler)           // Per-chunk SHA-256 manifest for /download/ Range requests
+	mux.HandleFunc("/resume", resumeHandler)            // Resumable upload API (raw body + offset header)
+	mux.HandleFunc("/put/", putHandler)                 // Raw PUT upload API (curl -T)
+	mux.HandleFunc("/ws", wsHandler)                    // WebSocket upload API (framed binary chunks)
+	mux.HandleFunc("/download-zip", downloadZipHandler) // All allowed files as one ZIP archive
+	mux.HandleFunc("/download-tar", downloadTarHandler) // The same as .tar.gz (refused with -zip-pass)
+	mux.HandleFunc("/code", codeHandler)                // Numeric code entry (scan-free access)
+	if metricsEnabled {
+		mux.HandleFunc("/metrics", metricsHandler) // Prometheus-style counters
+	}
+	if apiSpecEnabled {
+		mux.HandleFunc("/openapi.json", openAPIHandler) // OpenAPI description of the routes above
+	}
+	if notesEnabled {
+		mux.HandleFunc("/notes", notesHandler) // In-memory notes board
+	}
+	if historyEnabled {
+		mux.HandleFunc("/history", historyHandler) // Transfers recorded across restarts
+	}
+	mux.HandleFunc("/api/files", filesHandler) // The download list as JSON
+
+	// Operator endpoints, behind -admin-token and a stricter rate limit (the old paths redirect)
+	if manageEnabled {
+		mux.HandleFunc(adminUploadsPath, adminOnly(manageHandler)) // Received files with download and delete buttons
+		mux.HandleFunc("/uploads", movedTo(adminUploadsPath))
+	}
+	mux.HandleFunc(adminConfigPath, adminOnly(configHandler)) // Effective configuration as JSON
+	mux.HandleFunc("/api/config", movedTo(adminConfigPath))
+}
+
+// basePathHandler serves next under -base-path: the prefix is stripped before route matching,
+// the bare prefix redirects to prefix + "/" and anything outside it is 404
+func basePathHandler(next http.Handler) http.Handler {
+	stripped := http.StripPrefix(basePath, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == basePath {
+			target := basePath + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, basePath+"/") {
+			http.NotFound(w, r)
+			return
+		}
+		stripped.ServeHTTP(w, r)
+	})
+}
+
+// serverHeaderMiddleware sets the Server header of every response to -server-name (none if empty)
+func serverHeaderMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if serverName != "" {
+			w.Header().Set("Server", serverName)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// uploadPagePath returns where the upload page lives: / by default, /upload if -default
+// puts another page on /
+func uploadPagePath() string {
+	if defaultPage == "upload" || sandboxMode { // -sandbox has no upload page, links lead to / instead
+		return "/"
+	}
+	return "/upload"
 }
 
 // uploadFormHandler returns the HTML page with file upload form and progress bar
 func uploadFormHandler(w http.ResponseWriter, r *http.Request) {
-	// Only match GET requests for the root path
-	if r.Method != http.MethodGet || r.URL.Path != "/" {
-		http.NotFound(w, r)
+	// Only match GET requests (served on / and /upload)
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if uploadsDisabled(w) {
+		return
+	}
+	if noJSForm {
+		serveNoJSUploadForm(w, r)
 		return
 	}
 
@@ -121,7 +607,7 @@ func uploadFormHandler(w http.ResponseWriter, r *http.Request) {
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>Upload files</title>
+    <title>{{PAGE_TITLE}}</title>
     <style>
         /* Reset default styles */
         * {
@@ -138,6 +624,19 @@ func uploadFormHandler(w http.ResponseWriter, r *http.Request) {
             line-height: 1.5;
         }
         
+        .message {
+            margin-bottom: 20px;
+            color: #333;
+        }
+
+        .message p, .message ul, .message ol {
+            margin-bottom: 10px;
+        }
+
+        .message ul, .message ol {
+            padding-left: 20px;
+        }
+
         .upload-box {
             padding: 25px 15px;
             border: 2px dashed #ccc;
@@ -159,6 +658,15 @@ func uploadFormHandler(w http.ResponseWriter, r *http.Request) {
             font-size: 1rem;
         }
         
+        #tokenInput, #inboxInput {
+            margin-bottom: 20px;
+            padding: 10px;
+            width: 100%;
+            font-size: 1rem;
+            border: 1px solid #ccc;
+            border-radius: 4px;
+        }
+        
         #uploadBtn {
             padding: 12px 30px;
             background-color: #4285f4;
@@ -199,6 +707,12 @@ func uploadFormHandler(w http.ResponseWriter, r *http.Request) {
             border-radius: 10px;
         }
         
+        #pasteHint {
+            color: #999;
+            font-size: 0.8rem;
+            margin-top: 8px;
+        }
+
         #progressText {
             color: #666;
             font-size: 0.9rem;
@@ -258,11 +772,17 @@ func uploadFormHandler(w http.ResponseWriter, r *http.Request) {
             }
         }
     </style>
+    {{THEME_HEAD}}
 </head>
 <body>
+    {{THEME_TOGGLE}}
+    {{MESSAGE}}
     <div class="upload-box">
-        <h1>Upload files</h1>
-        <input type="file" id="fileInput" name="files" multiple accept="*/*">
+        <h1>{{PAGE_HEADING}}</h1>
+        <input type="file" id="fileInput" name="files" {{MULTIPLE}}accept="*/*">
+        <div id="pasteHint">or paste an image (screenshot) anywhere on this page</div>
+        {{INBOX_INPUT}}
+        {{TOKEN_INPUT}}
         <br>
         <button id="uploadBtn" onclick="uploadFiles()">Upload</button>
         
@@ -274,18 +794,22 @@ func uploadFormHandler(w http.ResponseWriter, r *http.Request) {
         
         <!-- Upload result display -->
         <div id="result"></div>
-        <a id="backBtn" href="/">Back to Upload page</a>
-        <a href="/downloads" class="download-link">📌 Go to Download List Page</a>
+        <a id="backBtn" href="{{UPLOAD_PAGE}}">Back to Upload page</a>
+        <a href="{{BASE_PATH}}/downloads" class="download-link">📌 Go to Download List Page</a>
+        {{NOTES_LINK}}
     </div>
+    {{SESSION_FOOTER}}
 
     <script>
-        // Global variable
+        // Global variables
         let xhr;
+        const uploadToken = '{{UPLOAD_TOKEN}}';
+        const singleUpload = {{SINGLE_UPLOAD}};
 
-        // Core file upload function
-        function uploadFiles() {
+        // Core file upload function (pastedFiles: images from the clipboard instead of the picker)
+        function uploadFiles(pastedFiles) {
             const fileInput = document.getElementById('fileInput');
-            const files = fileInput.files;
+            const files = pastedFiles || fileInput.files;
             const uploadBtn = document.getElementById('uploadBtn');
             const progressContainer = document.getElementById('progressContainer');
             const progressBar = document.getElementById('progressBar');
@@ -298,6 +822,10 @@ func uploadFormHandler(w http.ResponseWriter, r *http.Request) {
                 showResult('Please select at least one file!', 'error');
                 return;
             }
+            if (singleUpload && files.length > 1) {
+                showResult('Please upload one file at a time.', 'error');
+                return;
+            }
 
             // Disable upload button and show progress bar
             uploadBtn.disabled = true;
@@ -308,13 +836,23 @@ func uploadFormHandler(w http.ResponseWriter, r *http.Request) {
 
             // Build FormData (match server field name)
             const formData = new FormData();
+            const inboxInput = document.getElementById('inboxInput');
+            if (inboxInput) {
+                formData.append('inbox', inboxInput.value);
+            }
             for (let i = 0; i < files.length; i++) {
-                formData.append('files', files[i]);
+                formData.append('files', files[i], files[i].name);
             }
 
             // Create XHR object and listen to upload progress
             xhr = new XMLHttpRequest();
-            xhr.open('POST', '/upload', true);
+            xhr.open('POST', '{{BASE_PATH}}/upload', true);
+            xhr.setRequestHeader('X-Requested-With', 'XMLHttpRequest');
+            const tokenInput = document.getElementById('tokenInput');
+            const token = tokenInput ? tokenInput.value : uploadToken;
+            if (token) {
+                xhr.setRequestHeader('X-Upload-Token', token);
+            }
 
             // Listen to progress event (core: get upload progress)
             xhr.upload.addEventListener('progress', function(e) {
@@ -329,11 +867,20 @@ func uploadFormHandler(w http.ResponseWriter, r *http.Request) {
             // Listen to upload completion
             xhr.addEventListener('load', function() {
                 if (xhr.status >= 200 && xhr.status < 300) {
-                    // Upload success
-                    showResult(xhr.responseText, 'success');
+                    // Upload success (with -upload-redirect the server answers JSON with the next URL)
+                    if ((xhr.getResponseHeader('Content-Type') || '').indexOf('application/json') === 0) {
+                        const reply = JSON.parse(xhr.responseText);
+                        if (reply.redirect) {
+                            window.location.href = reply.redirect;
+                            return;
+                        }
+                        showResult(reply.message, 'success');
+                    } else {
+                        showResult(xhr.responseText, 'success');
+                    }
                 } else {
                     // Upload failed
-                    showResult('Upload failed: ' + xhr.statusText, 'error');
+                    showResult('Upload failed: ' + (xhr.responseText || xhr.statusText), 'error');
                 }
                 resetUI();
             });
@@ -370,6 +917,34 @@ func uploadFormHandler(w http.ResponseWriter, r *http.Request) {
             uploadBtn.disabled = false;
         }
 
+        // Pasted images have no name (or just "image.png"), so they are uploaded as
+        // pasted-YYYYMMDD-HHMMSS[-N].ext, with the extension taken from the image type
+        function pastedFileName(type, index, count) {
+            const pad = n => String(n).padStart(2, '0');
+            const now = new Date();
+            const stamp = now.getFullYear() + pad(now.getMonth() + 1) + pad(now.getDate()) + '-' +
+                pad(now.getHours()) + pad(now.getMinutes()) + pad(now.getSeconds());
+            const ext = (type.split('/')[1] || 'png').replace('jpeg', 'jpg').replace(/[^a-z0-9]/gi, '');
+            return 'pasted-' + stamp + (count > 1 ? '-' + (index + 1) : '') + '.' + ext;
+        }
+
+        // Upload clipboard images right away; pasting text (e.g. into the token field) is left alone
+        document.addEventListener('paste', function(e) {
+            const items = (e.clipboardData && e.clipboardData.items) || [];
+            const images = [];
+            for (let i = 0; i < items.length; i++) {
+                if (items[i].kind === 'file' && items[i].type.indexOf('image/') === 0) {
+                    images.push(items[i].getAsFile());
+                }
+            }
+            if (images.length === 0 || document.getElementById('uploadBtn').disabled) {
+                return;
+            }
+            e.preventDefault();
+            uploadFiles(images.map((image, i) =>
+                new File([image], pastedFileName(image.type, i, images.length), { type: image.type })));
+        });
+
         // Cancel upload (optional: use when adding cancel button)
         function cancelUpload() {
             if (xhr) {
@@ -380,93 +955,487 @@ func uploadFormHandler(w http.ResponseWriter, r *http.Request) {
 </body>
 </html>
 `
+	// Embed the upload token only for visitors who opened the page with it (?token=),
+	// everyone else gets a field to type it in when -upload-token is set
+	tokenInput, pageToken := "", ""
+	if uploadToken != "" {
+		if validUploadToken(r.URL.Query().Get("token")) {
+			pageToken = uploadToken
+		} else {
+			tokenInput = `<input type="password" id="tokenInput" placeholder="Upload token" autocomplete="off">`
+		}
+	}
+	html = strings.ReplaceAll(html, "{{TOKEN_INPUT}}", tokenInput)
+
+	// With -inbox, uploads are sorted into a folder per sender
+	inboxInput := ""
+	if inboxMode {
+		inboxInput = `<input type="text" id="inboxInput" placeholder="Your name" maxlength="64" autocomplete="name">`
+	}
+	html = strings.ReplaceAll(html, "{{INBOX_INPUT}}", inboxInput)
+
+	notesLink := ""
+	if notesEnabled {
+		notesLink = `<a href="` + routePath("/notes") + `" class="download-link">📝 Notes Board</a>`
+	}
+	html = strings.ReplaceAll(html, "{{NOTES_LINK}}", notesLink)
+	html = strings.ReplaceAll(html, "{{SESSION_FOOTER}}", string(sessionFooter()))
+	html = strings.ReplaceAll(html, "{{THEME_HEAD}}", string(themeHead()))
+	html = strings.ReplaceAll(html, "{{THEME_TOGGLE}}", string(themeToggle()))
+	html = strings.ReplaceAll(html, "{{PAGE_TITLE}}", template.HTMLEscapeString(pageTitle("Upload files")))
+	html = strings.ReplaceAll(html, "{{PAGE_HEADING}}", template.HTMLEscapeString(pageHeading("Upload files")))
+	html = strings.ReplaceAll(html, "{{UPLOAD_PAGE}}", routePath(uploadPagePath()))
+	html = strings.ReplaceAll(html, "{{SINGLE_UPLOAD}}", strconv.FormatBool(singleUpload))
+	multiple := "multiple "
+	if singleUpload {
+		multiple = ""
+	}
+	html = strings.ReplaceAll(html, "{{MULTIPLE}}", multiple)
+	html = strings.ReplaceAll(html, "{{BASE_PATH}}", basePath)
+	html = strings.ReplaceAll(html, "{{UPLOAD_TOKEN}}", template.JSEscapeString(pageToken))
+
+	// Last, so placeholders inside the -message content stay literal
+	message := ""
+	if messageHTML != "" {
+		message = `<div class="message">` + messageHTML + `</div>`
+	}
+	html = strings.ReplaceAll(html, "{{MESSAGE}}", message)
+
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	fmt.Fprint(w, html)
 }
 
+// clientIP returns the IP address part of the request's remote address
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// serverError answers 500 with message alone and logs err: os errors name absolute paths on
+// this computer, which clients are not shown
+func serverError(w http.ResponseWriter, message string, err error) {
+	http.Error(w, serverFailure(message, err).Error(), http.StatusInternalServerError)
+}
+
+// serverFailure logs err and returns an error carrying only message, for answers that are not
+// plain HTTP errors (/ws replies)
+func serverFailure(message string, err error) error {
+	log.Printf("%s: %v", message, err)
+	return errors.New(message)
+}
+
+// validUploadToken compares a client-supplied token with -upload-token in constant time
+func validUploadToken(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(uploadToken)) == 1
+}
+
+// uploadAuthorized checks the upload token from the X-Upload-Token header or ?token= query
+// (before the body is read), or from the "token" form field of a parsed form
+func uploadAuthorized(r *http.Request) bool {
+	if uploadToken == "" {
+		return true
+	}
+	if validUploadToken(r.Header.Get("X-Upload-Token")) || validUploadToken(r.URL.Query().Get("token")) {
+		return true
+	}
+	return r.MultipartForm != nil && len(r.MultipartForm.Value["token"]) > 0 && validUploadToken(r.MultipartForm.Value["token"][0])
+}
+
+// idleReader extends the connection read deadline before every read, so a client
+// that stops sending for longer than timeout makes the read fail instead of blocking forever
+type idleReader struct {
+	body    io.ReadCloser
+	rc      *http.ResponseController
+	timeout time.Duration
+}
+
+func (ir *idleReader) Read(p []byte) (int, error) {
+	// Ignore http.ErrNotSupported: the upload then simply has no watchdog
+	_ = ir.rc.SetReadDeadline(time.Now().Add(ir.timeout))
+	return ir.body.Read(p)
+}
+
+func (ir *idleReader) Close() error {
+	return ir.body.Close()
+}
+
+// watchUploadIdle installs the -upload-idle-timeout watchdog on the request body
+func watchUploadIdle(w http.ResponseWriter, r *http.Request) {
+	if uploadIdleTimeout > 0 {
+		r.Body = &idleReader{body: r.Body, rc: http.NewResponseController(w), timeout: uploadIdleTimeout}
+	}
+}
+
+// isTimeout reports whether err was caused by an expired read deadline
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
 // uploadHandler handles file upload requests
 func uploadHandler(w http.ResponseWriter, r *http.Request) {
+	// GET shows the upload page, so it stays reachable when -default puts another page on /
+	if r.Method == http.MethodGet {
+		uploadFormHandler(w, r)
+		return
+	}
 	if r.Method != http.MethodPost {
 		http.Error(w, "Only POST method is supported", http.StatusMethodNotAllowed)
 		return
 	}
+	if uploadsDisabled(w) {
+		return
+	}
+
+	// Reject unauthorized clients before touching the disk (a "token" form field must come
+	// before the files, parseUploadForm refuses file parts until it has seen the token)
+	if !uploadAuthorized(r) && !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		http.Error(w, "Upload token required", http.StatusUnauthorized)
+		return
+	}
 
-	// Parse multipart/form-data (no size limit)
-	err := r.ParseMultipartForm(0) // 0 means no limit on memory buffer size
+	// Scripts can ask for newline-delimited JSON progress instead of the single response
+	var uploadProgress *uploadProgressWriter
+	if wantsUploadProgress(r) {
+		uploadProgress = startUploadProgress(w, r)
+		defer uploadProgress.finish()
+		w = uploadProgress
+	}
+
+	// With -upload-cmd the files go straight into the command instead of onto the disk
+	if uploadCommand != "" {
+		pipeUploads(w, r)
+		return
+	}
+
+	// Parse multipart/form-data (no size limit for files), aborting stalled clients. Every file
+	// part is spooled to a temp file as it arrives, so file data is never held in memory; the
+	// other fields are, within -max-form-size and -max-form-fields.
+	watchUploadIdle(w, r)
+	err := parseUploadForm(r)
 	if err != nil {
+		if isTimeout(err) {
+			http.Error(w, fmt.Sprintf("Upload aborted: no data received for %s", uploadIdleTimeout), http.StatusRequestTimeout)
+			return
+		}
+		if errors.Is(err, errFormLimit) {
+			http.Error(w, fmt.Sprintf("Upload rejected: %v", err), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if errors.Is(err, errFormUnauthorized) {
+			http.Error(w, "Upload token required", http.StatusUnauthorized)
+			return
+		}
 		http.Error(w, fmt.Sprintf("Failed to parse form: %v", err), http.StatusBadRequest)
 		return
 	}
+	defer r.MultipartForm.RemoveAll()
+	if !uploadAuthorized(r) {
+		http.Error(w, "Upload token required", http.StatusUnauthorized)
+		return
+	}
+
+	// Replay the stored response for a retried Idempotency-Key instead of saving again
+	idempotencyKey := r.Header.Get(idempotencyHeader)
+	if idempotencyKey != "" && beginIdempotent(w, idempotencyKey) {
+		return
+	}
+	var responseMsg string
+	uploadSucceeded := false
+	if idempotencyKey != "" {
+		defer func() { finishIdempotent(idempotencyKey, uploadSucceeded, responseMsg) }()
+	}
 
 	files := r.MultipartForm.File["files"]
 	if len(files) == 0 {
 		http.Error(w, "No files were uploaded", http.StatusBadRequest)
 		return
 	}
+	if singleUpload && len(files) > 1 {
+		http.Error(w, fmt.Sprintf("Only one file per upload is accepted, got %d", len(files)), http.StatusBadRequest)
+		return
+	}
+
+	// Reserve the batch against -max-total, giving back whatever is not kept in the end
+	var batchSize, keptSize int64
+	for _, fileHeader := range files {
+		if maxFileSize <= 0 || fileHeader.Size <= maxFileSize {
+			batchSize += fileHeader.Size
+		}
+	}
+	if !reserveUploadSpace(batchSize) {
+		uploadSpaceExceeded(w)
+		return
+	}
+	defer func() { releaseUploadSpace(batchSize - keptSize) }()
 
-	// Create save directory (under current working directory)
+	// Create save directory (under current working directory, with -inbox the sender's folder)
 	//saveDir := filepath.Join(currentWorkDir, "uploads")
-	saveDir := currentWorkDir
-	if err := os.MkdirAll(saveDir, 0755); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create save directory: %v", err), http.StatusInternalServerError)
+	saveDir, err := uploadDir(r.FormValue(inboxField))
+	if err != nil {
+		serverError(w, "Failed to create save directory", err)
+		return
+	}
+	if err := os.MkdirAll(saveDir, uploadDirMode); err != nil {
+		serverError(w, "Failed to create save directory", err)
 		return
 	}
 
 	// Iterate and save files
-	var uploadedFiles []string
-	buf := make([]byte, 1024*1024) // 1MB buffer
+	var uploadedFiles, strippedFiles, unzipNotes, rejectedFiles, blockedFiles, skippedFiles, renamedFiles, duplicateFiles []string
+	buf := make([]byte, transferBufSize)
 	for _, fileHeader := range files {
+		// Files over -max-file-size are skipped, the rest of the batch is still saved
+		if maxFileSize > 0 && fileHeader.Size > maxFileSize {
+			rejectedFiles = append(rejectedFiles, fileHeader.Filename)
+			continue
+		}
+
 		file, err := fileHeader.Open()
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to open file %s: %v", fileHeader.Filename, err), http.StatusInternalServerError)
+			serverError(w, fmt.Sprintf("Failed to open file %s", fileHeader.Filename), err)
 			return
 		}
 		defer file.Close()
 
-		savePath := filepath.Join(saveDir, fileHeader.Filename)
-		// Check if file exists to avoid overwriting
-		if _, err := os.Stat(savePath); err == nil {
-			http.Error(w, fmt.Sprintf("File %s already exists", fileHeader.Filename), http.StatusConflict)
+		// Check the content type from the first bytes (via -sniff-allow) before creating anything
+		src, err := sniffUpload(file, fileHeader.Filename)
+		var sniffErr *sniffError
+		if errors.As(err, &sniffErr) {
+			blockedFiles = append(blockedFiles, fmt.Sprintf("%s (%s)", fileHeader.Filename, sniffErr.contentType))
+			continue
+		}
+		if err != nil {
+			serverError(w, fmt.Sprintf("Failed to read file %s", fileHeader.Filename), err)
 			return
 		}
 
-		dstFile, err := os.Create(savePath)
+		// An existing name is compared by checksum once received, then handled per -on-conflict
+		target, dstFile, err := createUploadTarget(filepath.Join(saveDir, fileHeader.Filename), fileHeader.Size)
+		if errors.Is(err, errUploadConflict) {
+			http.Error(w, fmt.Sprintf("File %s %v", fileHeader.Filename, err), http.StatusConflict)
+			return
+		}
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to create file %s: %v", fileHeader.Filename, err), http.StatusInternalServerError)
+			serverError(w, fmt.Sprintf("Failed to create file %s", fileHeader.Filename), err)
 			return
 		}
 		defer dstFile.Close()
+		dst := target.writer(dstFile)
 
-		// Write file in chunks
-		for {
-			n, err := file.Read(buf)
-			if n > 0 {
-				if _, err := dstFile.Write(buf[:n]); err != nil {
-					http.Error(w, fmt.Sprintf("Failed to write file %s: %v", fileHeader.Filename, err), http.StatusInternalServerError)
-					return
-				}
-			}
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				http.Error(w, fmt.Sprintf("Failed to read file %s: %v", fileHeader.Filename, err), http.StatusInternalServerError)
-				return
-			}
+		// Write the file (abandoning it if it turns out larger than -max-file-size)
+		if maxFileSize > 0 {
+			src = io.LimitReader(src, maxFileSize+1) // One byte more than allowed reveals an oversized file
 		}
-
-		// Set file permissions
-		if err := os.Chmod(savePath, 0644); err != nil {
-			fmt.Printf("Failed to set permissions for file %s: %v\n", savePath, err)
+		written, err := io.CopyBuffer(dst, src, buf)
+		metricBytesUploaded.Add(written)
+		if err == nil && maxFileSize > 0 && written > maxFileSize {
+			removePartial(dstFile, target.writePath)
+			rejectedFiles = append(rejectedFiles, fileHeader.Filename)
+			continue
+		}
+		if err != nil {
+			removePartial(dstFile, target.writePath)
+			serverError(w, fmt.Sprintf("Failed to write file %s", fileHeader.Filename), err)
+			return
+		}
+		if err := closeSaved(dstFile); err != nil {
+			os.Remove(target.writePath)
+			serverError(w, fmt.Sprintf("Failed to write file %s", fileHeader.Filename), err)
+			return
 		}
 
-		uploadedFiles = append(uploadedFiles, fileHeader.Filename)
+		savePath, err := target.finish()
+		if errors.Is(err, errUploadConflict) {
+			http.Error(w, fmt.Sprintf("File %s %v", fileHeader.Filename, err), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			serverError(w, fmt.Sprintf("Failed to save file %s", fileHeader.Filename), err)
+			return
+		}
+		if savePath == "" {
+			skippedFiles = append(skippedFiles, fileHeader.Filename)
+			continue
+		}
+		saved := finishUpload(r, savePath, fileHeader.Size)
+		if saved.name != fileHeader.Filename {
+			renamedFiles = append(renamedFiles, fmt.Sprintf("%s as %s", fileHeader.Filename, saved.name))
+		}
+		if saved.stripped {
+			strippedFiles = append(strippedFiles, saved.name)
+		}
+		if saved.duplicateOf != "" {
+			duplicateFiles = append(duplicateFiles, fmt.Sprintf("%s = %s", saved.name, saved.duplicateOf))
+			continue
+		}
+		if saved.unzipNote != "" {
+			unzipNotes = append(unzipNotes, saved.unzipNote)
+		}
+		uploadedFiles = append(uploadedFiles, saved.name)
+		keptSize += fileHeader.Size
+		uploadProgress.fileSaved(saved.name, fileHeader.Size)
+	}
+
+	// Every file was over the limit or of a blocked type: nothing was saved
+	alreadyPresent := len(skippedFiles) + len(duplicateFiles)
+	if len(uploadedFiles) == 0 && alreadyPresent == 0 && len(blockedFiles) > 0 {
+		msg := fmt.Sprintf("Upload rejected, type not allowed: %s", strings.Join(blockedFiles, ", "))
+		if len(rejectedFiles) > 0 {
+			msg += fmt.Sprintf("; larger than the %s per-file limit: %s", formatFileSize(maxFileSize), strings.Join(rejectedFiles, ", "))
+		}
+		http.Error(w, msg, http.StatusUnsupportedMediaType)
+		return
+	}
+	if len(uploadedFiles) == 0 && alreadyPresent == 0 && len(rejectedFiles) > 0 {
+		http.Error(w, fmt.Sprintf("Upload rejected: %s larger than the %s per-file limit", strings.Join(rejectedFiles, ", "), formatFileSize(maxFileSize)), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Return upload success response (re-sending files that are already there counts as success)
+	if len(uploadedFiles) > 0 || alreadyPresent == 0 {
+		responseMsg = fmt.Sprintf("Successfully uploaded %d files: %s", len(uploadedFiles), strings.Join(uploadedFiles, ", "))
+	} else {
+		responseMsg = "Nothing new to upload"
+	}
+	if inboxMode {
+		responseMsg += fmt.Sprintf(" (in folder %s)", filepath.Base(saveDir))
+	}
+	if len(skippedFiles) > 0 {
+		responseMsg += fmt.Sprintf(" (already present, skipped: %s)", strings.Join(skippedFiles, ", "))
+	}
+	if len(renamedFiles) > 0 {
+		responseMsg += fmt.Sprintf(" (renamed: %s)", strings.Join(renamedFiles, ", "))
+	}
+	if len(duplicateFiles) > 0 {
+		responseMsg += fmt.Sprintf(" (duplicates removed: %s)", strings.Join(duplicateFiles, ", "))
+	}
+	if len(strippedFiles) > 0 {
+		responseMsg += fmt.Sprintf(" (metadata stripped: %s)", strings.Join(strippedFiles, ", "))
+	}
+	if len(unzipNotes) > 0 {
+		responseMsg += fmt.Sprintf(" (%s)", strings.Join(unzipNotes, "; "))
+	}
+	if len(rejectedFiles) > 0 {
+		responseMsg += fmt.Sprintf(" (rejected, larger than %s: %s)", formatFileSize(maxFileSize), strings.Join(rejectedFiles, ", "))
+	}
+	if len(blockedFiles) > 0 {
+		responseMsg += fmt.Sprintf(" (rejected, type not allowed: %s)", strings.Join(blockedFiles, ", "))
+	}
+	uploadSucceeded = true
+	writeUploadSuccess(w, r, responseMsg)
+}
+
+// savedUpload is what finishUpload did with one saved file, for the upload response
+type savedUpload struct {
+	name        string // Base name the file was saved as
+	stripped    bool   // Image metadata was removed (via -strip-metadata)
+	duplicateOf string // Existing file with the same content, the upload itself was removed (via -dedupe)
+	unzipNote   string // Result of extracting a .zip (via -unzip)
+}
+
+// finishUpload takes a completely written upload at savePath through the steps every route
+// shares (form, /put/, /ws and /resume): permissions, metadata stripping, the counters and
+// history, then -dedupe, -unzip, -mirror and the storage backend
+func finishUpload(r *http.Request, savePath string, size int64) savedUpload {
+	saved := savedUpload{name: filepath.Base(savePath)}
+	syncDir(savePath)
+	if err := os.Chmod(savePath, uploadFileMode); err != nil {
+		fmt.Printf("Failed to set permissions for file %s: %v\n", savePath, err)
+	}
+
+	// Remove privacy-sensitive metadata from images (other files are left untouched)
+	if stripMetadata {
+		stripped, err := stripImageMetadata(savePath)
+		if err != nil {
+			fmt.Printf("Failed to strip metadata from %s: %v\n", savePath, err)
+		}
+		saved.stripped = stripped
+	}
+	metricUploads.Add(1)
+	recordTransfer(r, "upload", saved.name, size)
+
+	// Drop the new copy if the same content is already there (via -dedupe)
+	if saved.duplicateOf = dedupeUpload(savePath); saved.duplicateOf != "" {
+		return saved
 	}
+	saved.unzipNote = unzipUpload(savePath)
+	mirrorUpload(savePath)
+	storeUpload(savePath)
+	return saved
+}
+
+// message describes a file saved by /put/, /ws or /resume (saveDir is its folder)
+func (saved savedUpload) message(saveDir string, size int64) string {
+	msg := fmt.Sprintf("Saved %s (%d bytes)", saved.name, size)
+	if inboxMode {
+		msg += fmt.Sprintf(" (in folder %s)", filepath.Base(saveDir))
+	}
+	if saved.stripped {
+		msg += " (metadata stripped)"
+	}
+	if saved.duplicateOf != "" {
+		return fmt.Sprintf("%s, identical to %s: duplicate removed", msg, saved.duplicateOf)
+	}
+	if saved.unzipNote != "" {
+		msg += fmt.Sprintf(" (%s)", saved.unzipNote)
+	}
+	return msg
+}
 
-	// Return upload success response
+// writeUploadSuccess sends the success message, or hands off to the -upload-redirect page:
+// the upload page's XHR gets JSON, plain forms a 303. A -no-js form gets the message as a page.
+func writeUploadSuccess(w http.ResponseWriter, r *http.Request, msg string) {
+	if uploadRedirect != "" && !wantsUploadProgress(r) {
+		if r.Header.Get("X-Requested-With") == "XMLHttpRequest" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{"message": msg, "redirect": uploadRedirect})
+		} else {
+			http.Redirect(w, r, uploadRedirect, http.StatusSeeOther)
+		}
+		return
+	}
+	if noJSResultWanted(r) {
+		serveNoJSResult(w, msg)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
-	responseMsg := fmt.Sprintf("Successfully uploaded %d files: %s", len(uploadedFiles), strings.Join(uploadedFiles, ", "))
-	fmt.Fprint(w, responseMsg)
+	fmt.Fprint(w, msg)
+}
+
+// removePartial closes and deletes a file whose upload did not complete
+func removePartial(dstFile *os.File, savePath string) {
+	dstFile.Close()
+	if err := os.Remove(savePath); err != nil {
+		fmt.Printf("Failed to remove partial file %s: %v\n", savePath, err)
+	}
+}
+
+// closeSaved closes a completely written file, with -fsync after flushing it to disk so a
+// crash or sudden sleep can't leave a saved upload incomplete
+func closeSaved(dstFile *os.File) error {
+	if fsyncUploads {
+		if err := dstFile.Sync(); err != nil {
+			dstFile.Close()
+			return err
+		}
+	}
+	return dstFile.Close()
+}
+
+// syncDir flushes the directory entry of a saved file with -fsync, so the new name survives a
+// crash too. Best effort: not every platform can sync a directory.
+func syncDir(savePath string) {
+	if !fsyncUploads {
+		return
+	}
+	if dir, err := os.Open(filepath.Dir(savePath)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
 }
 
 // getDownloadableFiles returns list of downloadable files (from -f or -x)
@@ -483,32 +1452,223 @@ func getDownloadableFiles() []DownloadFileInfo {
 		for _, relPath := range allowMultiFilePaths {
 			absPath := filepath.Clean(filepath.Join(currentWorkDir, relPath))
 			fileInfo := getFileInfo(relPath, absPath)
+			fileInfo.Alias = downloadAlias(relPath)
 			files = append(files, fileInfo)
 		}
+	} else if sharedDir != "" {
+		files = getSharedDirFiles()
+	} else if sourceArchive != "" {
+		files = getSourceEntries()
+	} else if remoteStorage() {
+		files = getStoredFiles()
+	}
+
+	return files
+}
+
+// downloadLink returns the /download/ URL path of a listed file (its -x alias if it has one)
+func downloadLink(file DownloadFileInfo) string {
+	// Encode relative path for URL (supports spaces/special chars)
+	name := file.RelPath
+	if file.Alias != "" {
+		name = file.Alias
+	}
+	return routePath("/download/" + url.PathEscape(name))
+}
+
+// totalFileSize adds up the sizes of the files that currently exist
+func totalFileSize(files []DownloadFileInfo) int64 {
+	var total int64
+	for _, file := range files {
+		if file.Exists {
+			total += file.Size
+		}
+	}
+	return total
+}
+
+// getSharedDirFiles walks the -d directory and returns every regular file matching -glob
+func getSharedDirFiles() []DownloadFileInfo {
+	var files []DownloadFileInfo
+	root := filepath.Clean(filepath.Join(currentWorkDir, sharedDir))
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable entries are skipped, the rest of the tree is still shared
+			log.Printf("Warning: failed to read %s: %v", path, err)
+			return nil
+		}
+		if path != root {
+			if dirRel, err := filepath.Rel(root, path); err == nil && isExcluded(filepath.ToSlash(dirRel), entry.Name()) {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if entry.IsDir() || !matchesGlob(entry.Name()) {
+			return nil
+		}
+		relPath, err := filepath.Rel(currentWorkDir, path)
+		if err != nil {
+			return nil
+		}
+		files = append(files, getFileInfo(filepath.ToSlash(relPath), path))
+		return nil
+	})
+	if err != nil {
+		log.Printf("Warning: failed to walk shared directory %s: %v", root, err)
 	}
 
 	return files
 }
 
+// matchesGlob reports whether a base name matches any -glob pattern (all names match without -glob)
+func matchesGlob(name string) bool {
+	if len(globPatterns) == 0 {
+		return true
+	}
+	for _, pattern := range globPatterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// isExcluded reports whether a -d entry matches an -exclude pattern, by base name or by its
+// slash-separated path relative to the shared directory (excluded folders are skipped entirely)
+func isExcluded(relPath, name string) bool {
+	for _, pattern := range excludePatterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, relPath); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePatternList splits comma-separated glob patterns, rejecting malformed ones
+func parsePatternList(value string) ([]string, error) {
+	var patterns []string
+	for _, p := range strings.Split(value, ",") {
+		pattern := strings.TrimSpace(p)
+		if pattern == "" {
+			continue
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// hasDownloadList reports whether the share has a download list page (-x, -d, -from-archive or -s3-bucket)
+func hasDownloadList() bool {
+	return len(allowMultiFilePaths) > 0 || sharedDir != "" || sourceArchive != "" || s3Bucket != ""
+}
+
+// downloadAliases maps the extra /download/ names of -x files to their paths (via -x alias=path)
+var downloadAliases = map[string]string{}
+
+// downloadAlias returns the (alphabetically first) -x alias of relPath, "" if it has none
+func downloadAlias(relPath string) string {
+	var found string
+	for alias, target := range downloadAliases {
+		if target == relPath && (found == "" || alias < found) {
+			found = alias
+		}
+	}
+	return found
+}
+
+// resolveAlias returns the -x path an alias stands for (matched like paths with -ignore-case)
+func resolveAlias(name string) (string, bool) {
+	if target, ok := downloadAliases[name]; ok {
+		return target, true
+	}
+	if ignoreCase {
+		for alias, target := range downloadAliases {
+			if strings.EqualFold(alias, name) {
+				return target, true
+			}
+		}
+	}
+	return "", false
+}
+
 // getFileInfo returns DownloadFileInfo for a given path
 func getFileInfo(relPath, absPath string) DownloadFileInfo {
 	fileInfo := DownloadFileInfo{
 		FileName: filepath.Base(absPath),
 		RelPath:  relPath,
 		AbsPath:  absPath,
+		Kind:     fileKind(absPath),
 		Exists:   false,
 	}
 
+	// Symlinks pointing outside the working directory are hidden unless -follow-symlinks is set
+	realPath, err := resolveServedPath(absPath)
+	if err != nil {
+		return fileInfo
+	}
+
 	// Check if file exists and get size
-	stat, err := os.Stat(absPath)
+	stat, err := os.Stat(realPath)
 	if err == nil && !stat.IsDir() {
 		fileInfo.Exists = true
 		fileInfo.Size = stat.Size()
+		fileInfo.ModTime = stat.ModTime()
 	}
 
 	return fileInfo
 }
 
+// workDirPath resolves a command-line path against the current working directory
+// (absolute paths are kept as they are)
+func workDirPath(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Clean(filepath.Join(currentWorkDir, path))
+}
+
+// isWithinDir reports whether path is dir itself or located below it
+func isWithinDir(dir, path string) bool {
+	relPath, err := filepath.Rel(dir, path)
+	return err == nil && relPath != ".." && !strings.HasPrefix(relPath, ".."+string(filepath.Separator))
+}
+
+// checkSharePath rejects a -f/-x/-d entry that is absolute or has a ".." component: shared
+// paths are always relative to the current directory, and such entries could never be served
+func checkSharePath(p string) error {
+	if filepath.IsAbs(p) || strings.HasPrefix(p, "/") || strings.HasPrefix(p, `\`) || filepath.VolumeName(p) != "" {
+		return fmt.Errorf("absolute paths are not supported, run pair from a parent directory and give a path relative to it")
+	}
+	for _, part := range strings.FieldsFunc(p, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if part == ".." {
+			return fmt.Errorf("\"..\" is not allowed, shared files must be inside the current directory")
+		}
+	}
+	return nil
+}
+
+// resolveServedPath resolves symlinks in an allowed path at request time, so a link always
+// serves its current target. Without -follow-symlinks the target must stay inside the working directory.
+func resolveServedPath(absPath string) (string, error) {
+	realPath, err := filepath.EvalSymlinks(absPath)
+	if err != nil {
+		return "", err
+	}
+	if !followSymlinks && !isWithinDir(realWorkDir, realPath) {
+		return "", fmt.Errorf("%s resolves to %s outside the working directory (use -follow-symlinks to allow)", absPath, realPath)
+	}
+	return realPath, nil
+}
+
 // formatFileSize converts bytes to human-readable format (B, KB, MB, GB)
 func formatFileSize(bytes int64) string {
 	const unit = 1024
@@ -525,15 +1685,32 @@ func formatFileSize(bytes int64) string {
 
 // downloadsListHandler shows the list of downloadable files (responsive design, simplified)
 func downloadsListHandler(w http.ResponseWriter, r *http.Request) {
-	// Only match GET requests for /downloads
-	if r.Method != http.MethodGet || r.URL.Path != "/downloads" {
+	// Only match GET requests (served on /downloads, and on / with -default downloads)
+	if r.Method != http.MethodGet {
 		http.NotFound(w, r)
 		return
 	}
+	// With -list-token only holders of the token see the catalog, /download/ links stay open
+	if listHidden(w, r) {
+		return
+	}
 
-	// Get downloadable files list
+	// Get downloadable files list, and the part of it this page shows
 	files := getDownloadableFiles()
 	totalFiles := len(files)
+	filter := strings.TrimSpace(r.URL.Query().Get("q"))
+	shown, numbers := filterFiles(files, filter)
+	sortKey, sortOrder, err := listSort(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid sort: %v", err), http.StatusBadRequest)
+		return
+	}
+	sortFiles(shown, numbers, sortKey, sortOrder)
+	pageFiles, offset, _, page, pages, err := listPage(r, shown)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid page: %v", err), http.StatusBadRequest)
+		return
+	}
 
 	// Generate HTML for download list (simplified, no stats/path/status)
 	html := `
@@ -542,7 +1719,7 @@ func downloadsListHandler(w http.ResponseWriter, r *http.Request) {
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>Download Files List</title>
+    <title>` + template.HTMLEscapeString(pageTitle("Download Files List")) + `</title>
     <style>
         /* Reset default styles */
         * {
@@ -599,10 +1776,12 @@ func downloadsListHandler(w http.ResponseWriter, r *http.Request) {
             font-weight: 600;
         }
         
-        /* Column width adjustments (only Filename, Size, Action) */
-        th:nth-child(1), td:nth-child(1) { width: 60%; } /* Filename */
-        th:nth-child(2), td:nth-child(2) { width: 20%; } /* Size */
-        th:nth-child(3), td:nth-child(3) { width: 20%; } /* Action */
+        /* Column widths, by class so adding a column doesn't shift them */
+        .col-index { width: 6%; }
+        .col-name { width: 50%; }
+        .col-size { width: 14%; }
+        .col-modified { width: 16%; }
+        .col-action { width: 14%; }
         
         .download-btn {
             padding: 8px 12px;
@@ -635,6 +1814,78 @@ func downloadsListHandler(w http.ResponseWriter, r *http.Request) {
             font-size: 0.95rem;
         }
         
+        .summary {
+            margin-top: 15px;
+            color: #666;
+            font-size: 0.95rem;
+        }
+
+        .download-all-btn {
+            display: block;
+            margin: 15px 0 5px;
+            padding: 12px;
+            background-color: #4285f4;
+            color: white;
+            border-radius: 4px;
+            text-decoration: none;
+            text-align: center;
+            font-size: 1rem;
+            font-weight: 600;
+        }
+
+        .download-all-btn:hover {
+            background-color: #3367d6;
+        }
+
+        .sort-link {
+            color: inherit;
+            text-decoration: none;
+        }
+
+        .search {
+            display: flex;
+            gap: 8px;
+            margin: 15px 0 5px;
+        }
+
+        .search input {
+            flex: 1;
+            min-width: 0;
+            padding: 8px;
+            border: 1px solid #ccc;
+            border-radius: 4px;
+            font-size: 1rem;
+        }
+
+        .search button {
+            padding: 8px 14px;
+            border: 1px solid #4285f4;
+            border-radius: 4px;
+            color: #4285f4;
+            background: white;
+            font-size: 0.95rem;
+            cursor: pointer;
+        }
+
+        .pagination {
+            display: flex;
+            justify-content: space-between;
+            align-items: center;
+            gap: 10px;
+            margin: 15px 0;
+            color: #666;
+            font-size: 0.95rem;
+        }
+
+        .pagination a {
+            color: #4285f4;
+            text-decoration: none;
+        }
+
+        .kind-icon {
+            margin-right: 2px;
+        }
+
         .empty-message {
             text-align: center;
             color: #666;
@@ -647,6 +1898,10 @@ func downloadsListHandler(w http.ResponseWriter, r *http.Request) {
 
         /* Media queries for smaller screens */
         @media (max-width: 480px) {
+            .modified {
+                display: none;
+            }
+
             h1 {
                 font-size: 1.5rem;
             }
@@ -662,54 +1917,94 @@ func downloadsListHandler(w http.ResponseWriter, r *http.Request) {
             }
         }
     </style>
+    ` + string(themeHead()) + `
 </head>
 <body>
+    ` + string(themeToggle()) + `
     <div class="list-container">
-        <h1>Downloadable Files</h1>
-        <a href="/" class="back-link">← Back to Upload</a>
+        <h1>` + template.HTMLEscapeString(pageHeading("Downloadable Files")) + `</h1>
     `
+	if !sandboxMode { // -sandbox has no upload page to go back to
+		html += `<a href="` + routePath(uploadPagePath()) + `" class="back-link">← Back to Upload</a>`
+	}
 
 	// Add files table or empty message
 	if totalFiles == 0 {
-		html += `<div class="empty-message">No downloadable files configured (use -f or -x parameter)</div>`
+		html += `<div class="empty-message">No downloadable files configured (use -f, -x or -d parameter)</div>`
 	} else {
+		totalSize := formatFileSize(totalFileSize(files))
+		html += fmt.Sprintf(`
+        <div class="summary">%d files, %s total</div>`, totalFiles, totalSize)
+		if totalFiles > 1 {
+			if !remoteStorage() && sourceArchive == "" { // Bucket files and archive entries are not packed into archives
+				archiveRoute, archiveLabel := archiveLink()
+				html += fmt.Sprintf(`
+        <a href="%s" class="download-all-btn">Download All (%s, %s)</a>`, template.HTMLEscapeString(withListToken(routePath(archiveRoute))), totalSize, archiveLabel)
+			}
+			html += fmt.Sprintf(`
+        <form class="search" method="get" action="%s">
+            <input type="search" name="q" value="%s" placeholder="Filter by name">%s
+            <button type="submit">Filter</button>
+        </form>`, routePath(r.URL.Path), template.HTMLEscapeString(filter), listTokenField())
+		}
+		if filter != "" {
+			html += fmt.Sprintf(`
+        <div class="summary">%d of %d files match "%s" · <a href="%s">Show all</a></div>`,
+				len(shown), totalFiles, template.HTMLEscapeString(filter), template.HTMLEscapeString(withListToken(routePath(r.URL.Path))))
+		}
 		html += `
         <div class="table-container">
             <table>
                 <tr>
-                    <th>Filename</th>
-                    <th>Size</th>
-                    <th>Action</th>
+                    <th class="col-index">#</th>
+                    <th class="col-name">` + sortHeader(r, "name", "Filename", sortKey, sortOrder) + `</th>
+                    <th class="col-size">` + sortHeader(r, "size", "Size", sortKey, sortOrder) + `</th>
+                    <th class="col-modified modified">` + sortHeader(r, "date", "Modified", sortKey, sortOrder) + `</th>
+                    <th class="col-action">Action</th>
                 </tr>
         `
-		// Add all files from -x (or -f) to table (only filename, size, action)
-		for _, file := range files {
+		// Add the files of this page to the table, numbered in the whole list.
+		// Names come from uploads, -d scans, buckets and archives, so they are escaped like the title.
+		for i, file := range pageFiles {
 			btnDisabled := "disabled"
 			btnHref := ""
 
 			if file.Exists {
 				btnDisabled = ""
-				// Encode relative path for URL (supports spaces/special chars)
-				encodedPath := url.PathEscape(file.RelPath)
-				btnHref = fmt.Sprintf("/download/%s", encodedPath)
+				btnHref = downloadLink(file)
+				if confirmDownloads {
+					// The list already shows name and size, so it counts as the confirmation
+					btnHref += "?go=1"
+				}
+			}
+
+			modified := ""
+			if file.Exists {
+				modified = file.ModTime.Format("2006-01-02 15:04")
 			}
 
-			// Add row for each file (only filename, size, download button)
+			// Add row for each file (number, name, size, modification time, download button)
 			html += fmt.Sprintf(`
             <tr>
-                <td>%s</td>
-                <td>%s</td>
-                <td>
+                <td class="col-index">%d</td>
+                <td class="col-name">%s</td>
+                <td class="col-size">%s</td>
+                <td class="col-modified modified">%s</td>
+                <td class="col-action">
                     <a href="%s" class="download-btn" %s>Download</a>
                 </td>
             </tr>
-            `, file.FileName, formatFileSize(file.Size), btnHref, btnDisabled)
+            `, numbers[offset+i], kindIcon(file.Kind)+template.HTMLEscapeString(file.FileName), formatFileSize(file.Size), modified, template.HTMLEscapeString(btnHref), btnDisabled)
 		}
 		html += `</table></div>`
+		if pages > 1 {
+			html += paginationHTML(r, numbers[offset:offset+len(pageFiles)], page, pages)
+		}
 	}
 
 	html += `
     </div>
+    ` + string(sessionFooter()) + `
 </body>
 </html>
 `
@@ -718,95 +2013,473 @@ func downloadsListHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, html)
 }
 
-// downloadHandler handles file download requests (ONLY current directory files)
-func downloadHandler(w http.ResponseWriter, r *http.Request) {
-	// Only handle GET method
-	if r.Method != http.MethodGet {
-		http.Error(w, "Only GET method is supported", http.StatusMethodNotAllowed)
-		return
+// listPage picks the files of one /downloads page: ?page=N (1-based) with ?per= files (default
+// -per-page, at most maxPerPage), or every file when the size is 0. A page past the end shows
+// the last one. It returns the page's files, their offset in the list, the page size, page and page count.
+func listPage(r *http.Request, files []DownloadFileInfo) ([]DownloadFileInfo, int, int, int, int, error) {
+	per, page := listPageSize, 1
+	var err error
+	query := r.URL.Query()
+	if value := query.Get("per"); value != "" {
+		if per, err = strconv.Atoi(value); err != nil || per < 1 || per > maxPerPage {
+			return nil, 0, 0, 0, 0, fmt.Errorf("per=%s is not a number from 1 to %d", value, maxPerPage)
+		}
 	}
-
-	// 1. Extract raw path after /download/ and decode URL
-	rawPath := strings.TrimPrefix(r.URL.Path, "/download/")
-	if rawPath == "" {
-		http.Error(w, fmt.Sprintf("Please specify relative path (under %s) e.g., /download/uploads/test.txt", currentWorkDir), http.StatusBadRequest)
-		return
+	if value := query.Get("page"); value != "" {
+		if page, err = strconv.Atoi(value); err != nil || page < 1 {
+			return nil, 0, 0, 0, 0, fmt.Errorf("page=%s is not a positive number", value)
+		}
+	}
+	if per == 0 || len(files) <= per {
+		return files, 0, per, 1, 1, nil
 	}
+	pages := (len(files) + per - 1) / per
+	page = min(page, pages)
+	offset := (page - 1) * per
+	return files[offset:min(offset+per, len(files))], offset, per, page, pages, nil
+}
 
-	// Decode URL-encoded path
-	decodedPath, err := url.PathUnescape(rawPath)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to decode file path: %v", err), http.StatusBadRequest)
-		return
+// filterFiles keeps the files whose relative path contains filter (case-insensitive) and
+// returns them with their numbers in the whole list, which ?from=/to= archives refer to
+func filterFiles(files []DownloadFileInfo, filter string) ([]DownloadFileInfo, []int) {
+	var shown []DownloadFileInfo
+	var numbers []int
+	needle := strings.ToLower(filter)
+	for i, file := range files {
+		if needle == "" || strings.Contains(strings.ToLower(file.RelPath), needle) ||
+			file.Alias != "" && strings.Contains(strings.ToLower(file.Alias), needle) {
+			shown = append(shown, file)
+			numbers = append(numbers, i+1)
+		}
 	}
+	return shown, numbers
+}
 
-	// 2. Resolve to absolute path under current working directory (FORBID absolute/parent paths)
-	targetPath := filepath.Join(currentWorkDir, decodedPath)
-	// Clean path to remove ../ or ./
-	cleanTargetPath := filepath.Clean(targetPath)
+// listSortKeys and listSortOrders are the accepted ?sort= and ?order= values of the download list
+var (
+	listSortKeys   = []string{"name", "size", "date"}
+	listSortOrders = []string{"asc", "desc"}
+)
 
-	// 3. Critical check: ensure the file is within current working directory
-	relPath, err := filepath.Rel(currentWorkDir, cleanTargetPath)
-	if err != nil || strings.HasPrefix(relPath, "..") {
-		http.Error(w, fmt.Sprintf("Access denied: File must be within current directory (%s)", currentWorkDir), http.StatusForbidden)
-		return
+// listSort reads ?sort= and ?order= (default name, ascending; newest first for date)
+func listSort(r *http.Request) (string, string, error) {
+	key, order := r.URL.Query().Get("sort"), r.URL.Query().Get("order")
+	if key == "" {
+		key = "name"
 	}
-
-	// 4. Check if file is in allowed list (supports multiple files from -x)
-	allowed := false
-	downloadableFiles := getDownloadableFiles()
-	for _, file := range downloadableFiles {
-		if file.AbsPath == cleanTargetPath && file.Exists {
-			allowed = true
-			break
+	if !slices.Contains(listSortKeys, key) {
+		return "", "", fmt.Errorf("sort=%s (use name, size, date)", key)
+	}
+	if order == "" {
+		order = "asc"
+		if key == "date" {
+			order = "desc"
 		}
 	}
-	if !allowed {
-		http.Error(w, "Access denied: File is not in allowed download list", http.StatusForbidden)
-		return
+	if !slices.Contains(listSortOrders, order) {
+		return "", "", fmt.Errorf("order=%s (use asc, desc)", order)
 	}
+	return key, order, nil
+}
 
-	// 5. Check if file exists (double check)
-	fileInfo, err := os.Stat(cleanTargetPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			http.Error(w, fmt.Sprintf("File %s does not exist (under %s)", decodedPath, currentWorkDir), http.StatusNotFound)
-		} else {
-			http.Error(w, fmt.Sprintf("Failed to get file information: %v", err), http.StatusInternalServerError)
+// sortFiles sorts the files of the download list and their list numbers together (stable, so
+// equal sizes or times keep the list order)
+func sortFiles(files []DownloadFileInfo, numbers []int, key, order string) {
+	type row struct {
+		file   DownloadFileInfo
+		number int
+	}
+	rows := make([]row, len(files))
+	for i := range files {
+		rows[i] = row{files[i], numbers[i]}
+	}
+	slices.SortStableFunc(rows, func(a, b row) int {
+		var c int
+		switch key {
+		case "size":
+			c = cmp.Compare(a.file.Size, b.file.Size)
+		case "date":
+			c = a.file.ModTime.Compare(b.file.ModTime)
+		default:
+			c = cmp.Compare(strings.ToLower(a.file.RelPath), strings.ToLower(b.file.RelPath))
 		}
-		return
+		if order == "desc" {
+			c = -c
+		}
+		return c
+	})
+	for i, row := range rows {
+		files[i], numbers[i] = row.file, row.number
 	}
+}
 
-	// Forbid directory download
-	if fileInfo.IsDir() {
-		http.Error(w, fmt.Sprintf("%s is a directory, download is not supported", decodedPath), http.StatusBadRequest)
-		return
+// listLink links to the download list with the current query changed by set (which sees a copy)
+func listLink(r *http.Request, set func(url.Values)) string {
+	query := r.URL.Query()
+	set(query)
+	return template.HTMLEscapeString(routePath(r.URL.Path) + "?" + query.Encode())
+}
+
+// sortHeader renders a column header that sorts by key, reversing the order if it already does
+func sortHeader(r *http.Request, key, label, sortKey, sortOrder string) string {
+	order, arrow := "", ""
+	if key == sortKey {
+		order, arrow = "desc", " ▲"
+		if sortOrder == "desc" {
+			order, arrow = "asc", " ▼"
+		}
+	}
+	href := listLink(r, func(query url.Values) {
+		query.Set("sort", key)
+		query.Del("order")
+		if order != "" {
+			query.Set("order", order)
+		}
+		query.Del("page") // A new order starts at the top
+	})
+	return fmt.Sprintf(`<a href="%s" class="sort-link">%s%s</a>`, href, label, arrow)
+}
+
+// paginationHTML renders the previous/next links of the download list (keeping filter and
+// sort), and a link to download just this page's files as one archive when they are a
+// contiguous part of the list (numbers are the page's list numbers)
+func paginationHTML(r *http.Request, numbers []int, page, pages int) string {
+	pageLink := func(n int) string {
+		return listLink(r, func(query url.Values) { query.Set("page", strconv.Itoa(n)) })
+	}
+	prev, next := "<span></span>", "<span></span>"
+	if page > 1 {
+		prev = fmt.Sprintf(`<a href="%s">← Previous</a>`, pageLink(page-1))
+	}
+	if page < pages {
+		next = fmt.Sprintf(`<a href="%s">Next →</a>`, pageLink(page+1))
+	}
+	html := fmt.Sprintf(`
+        <div class="pagination">%s<span>Page %d of %d</span>%s</div>`, prev, page, pages, next)
+	count := len(numbers)
+	first, last := slices.Min(numbers), slices.Max(numbers)
+	if count > 1 && last-first == count-1 && !remoteStorage() && sourceArchive == "" { // Filtered or reordered pages may not be a range
+		archiveRoute, archiveLabel := archiveLink()
+		html += fmt.Sprintf(`
+        <div class="pagination"><a href="%s">Download files %d-%d (%s)</a></div>`,
+			template.HTMLEscapeString(withListToken(fmt.Sprintf("%s?from=%d&to=%d", routePath(archiveRoute), first, last))), first, last, archiveLabel)
+	}
+	return html
+}
+
+// parseByteRange parses a single "bytes=start-end", "bytes=start-" or "bytes=-suffix" Range
+// header against a file of the given size. It returns the start offset and length, a length
+// of -1 if the header should be ignored (other units, multiple ranges), and ok == false
+// if the range is malformed or not satisfiable.
+func parseByteRange(header string, size int64) (int64, int64, bool) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, -1, true
+	}
+	startStr, endStr, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false
+	}
+
+	if startStr == "" {
+		// Suffix range: the last N bytes
+		suffix, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || suffix <= 0 || size == 0 {
+			return 0, 0, false
+		}
+		if suffix > size {
+			suffix = size
+		}
+		return size - suffix, suffix, true
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+	end := size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end - start + 1, true
+}
+
+// fileETag returns a strong validator for one version of a file (size and modification time)
+func fileETag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+// ifRangeMatches reports whether a Range request may be answered with a part of the current
+// file: without If-Range always, otherwise only if its ETag or date names this version
+func ifRangeMatches(r *http.Request, etag string, modTime time.Time) bool {
+	ifRange := strings.TrimSpace(r.Header.Get("If-Range"))
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) {
+		return ifRange == etag
+	}
+	date, err := http.ParseTime(ifRange)
+	return err == nil && modTime.Truncate(time.Second).Equal(date)
+}
+
+// resolveDownloadRequest maps a request path under prefix (e.g. /download/) to an allowed
+// file, applying the traversal, allow-list and symlink checks. It returns the allowed path,
+// the symlink-resolved path to serve and the decoded request path. On failure the error response
+// has already been written and the bool is false.
+func resolveDownloadRequest(w http.ResponseWriter, r *http.Request, prefix string) (string, string, string, bool) {
+	// 1. Extract raw path after the route prefix and decode URL
+	rawPath := strings.TrimPrefix(r.URL.Path, prefix)
+	if rawPath == "" {
+		http.Error(w, fmt.Sprintf("Please specify a relative path, e.g. %suploads/test.txt", prefix), http.StatusBadRequest)
+		return "", "", "", false
+	}
+
+	// Decode URL-encoded path
+	decodedPath, err := url.PathUnescape(rawPath)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to decode file path: %v", err), http.StatusBadRequest)
+		return "", "", "", false
+	}
+
+	// 2. Resolve to absolute path under current working directory (FORBID absolute/parent paths);
+	// an -x alias stands for its file and goes through the same checks
+	requestedPath := decodedPath
+	if target, ok := resolveAlias(decodedPath); ok {
+		requestedPath = target
+	}
+	targetPath := filepath.Join(currentWorkDir, requestedPath)
+	// Clean path to remove ../ or ./
+	cleanTargetPath := filepath.Clean(targetPath)
+
+	// 3. Critical check: ensure the file is within current working directory
+	relPath, err := filepath.Rel(currentWorkDir, cleanTargetPath)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		http.Error(w, "Access denied: File must be within the shared directory", http.StatusForbidden)
+		return "", "", "", false
+	}
+
+	// 4. Check if file is in allowed list (supports multiple files from -x)
+	allowed := false
+	downloadableFiles := getDownloadableFiles()
+	for _, file := range downloadableFiles {
+		if file.AbsPath == cleanTargetPath && file.Exists {
+			allowed = true
+			break
+		}
+	}
+
+	// With -ignore-case, fall back to a case-insensitive match (the real path is served)
+	if !allowed && ignoreCase {
+		var matches []string
+		for _, file := range downloadableFiles {
+			if file.Exists && strings.EqualFold(file.AbsPath, cleanTargetPath) {
+				matches = append(matches, file.AbsPath)
+			}
+		}
+		if len(matches) > 1 {
+			http.Error(w, fmt.Sprintf("Ambiguous file name: %d allowed files match %s when ignoring case", len(matches), decodedPath), http.StatusConflict)
+			return "", "", "", false
+		}
+		if len(matches) == 1 {
+			cleanTargetPath = matches[0]
+			allowed = true
+		}
+	}
+
+	if !allowed {
+		http.Error(w, "Access denied: File is not in allowed download list", http.StatusForbidden)
+		return "", "", "", false
+	}
+
+	// Resolve symlinks now so the latest target is served (and contained unless -follow-symlinks)
+	servePath, err := resolveServedPath(cleanTargetPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, fmt.Sprintf("File %s does not exist", decodedPath), http.StatusNotFound)
+		} else {
+			http.Error(w, "Access denied: File resolves outside the current directory", http.StatusForbidden)
+		}
+		return "", "", "", false
+	}
+
+	return cleanTargetPath, servePath, decodedPath, true
+}
+
+// downloadHandler handles file download requests (ONLY current directory files)
+func downloadHandler(w http.ResponseWriter, r *http.Request) {
+	// Only handle GET method (and HEAD, which download managers send to learn size and range support)
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Only GET method is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if shareExpired(w) {
+		return
+	}
+	if remoteStorage() {
+		serveStoredFile(w, r)
+		return
+	}
+	if sourceArchive != "" {
+		serveSourceEntry(w, r)
+		return
+	}
+
+	// 1-4. Resolve the request to an allowed file
+	cleanTargetPath, servePath, decodedPath, ok := resolveDownloadRequest(w, r, "/download/")
+	if !ok {
+		return
+	}
+
+	// 5. Check if file exists (double check)
+	fileInfo, err := os.Stat(servePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, fmt.Sprintf("File %s does not exist", decodedPath), http.StatusNotFound)
+		} else {
+			serverError(w, "Failed to get file information", err)
+		}
+		return
+	}
+
+	// Forbid directory download
+	if fileInfo.IsDir() {
+		http.Error(w, fmt.Sprintf("%s is a directory, download is not supported", decodedPath), http.StatusBadRequest)
+		return
 	}
 
-	// 6. Open file (only within current directory)
-	file, err := os.Open(cleanTargetPath)
+	// 6. Open file (only within current directory, or a followed symlink target)
+	file, err := os.Open(servePath)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to open file: %v", err), http.StatusInternalServerError)
+		serverError(w, "Failed to open file", err)
 		return
 	}
 	defer file.Close()
 
-	// 7. Set download response headers
+	// 7. Set download response headers (?name= or -as override the on-disk name)
 	fileName := filepath.Base(cleanTargetPath)
+	if override := r.URL.Query().Get("name"); override != "" {
+		fileName = sanitizeDownloadName(override, fileName)
+	} else if singleFileAlias != "" && allowSingleFilePath != "" && cleanTargetPath == filepath.Clean(filepath.Join(currentWorkDir, allowSingleFilePath)) {
+		fileName = sanitizeDownloadName(singleFileAlias, fileName)
+	}
+
+	// ?transform=NAME sends the output of that -transform-cmd instead of the file (no confirmation,
+	// so it works for thumbnails in <img> tags)
+	if r.URL.Query().Has(transformParam) {
+		serveTransformed(w, r, file, servePath, fileName)
+		return
+	}
+
+	// With -confirm, show name and size first so a large download never starts by accident
+	if !downloadConfirmed(r) {
+		serveDownloadConfirm(w, r, fileName, fileInfo.Size())
+		return
+	}
+
+	// With -tail, follow the file instead of sending what it holds right now
+	if tailRequested(r) {
+		serveTail(w, r, file, servePath, fileName)
+		return
+	}
+
+	// With -precompressed, send an up-to-date FILE.gz as is to clients that accept gzip
+	// (the download keeps the plain name, browsers decompress it while saving)
+	if precompressed {
+		w.Header().Set("Vary", "Accept-Encoding")
+	}
+	if gzFile, gzInfo, gzPath := openPrecompressed(r, cleanTargetPath, fileInfo); gzFile != nil {
+		defer gzFile.Close()
+		file, fileInfo, servePath = gzFile, gzInfo, gzPath
+		w.Header().Set("Content-Encoding", "gzip")
+	}
+
 	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", fileName))
-	w.Header().Set("Content-Length", strconv.FormatInt(fileInfo.Size(), 10))
+	w.Header().Set("Content-Disposition", contentDisposition(fileName))
+	w.Header().Set("Accept-Ranges", "bytes")
+	etag := fileETag(fileInfo)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", fileInfo.ModTime().UTC().Format(http.TimeFormat))
+
+	// A single "Range: bytes=..." request is served as 206 Partial Content (e.g. to re-fetch one
+	// chunk listed by /chunks/, or one segment of a download manager's parallel requests, each
+	// of which opens the file and seeks on its own); anything else gets the whole file. A
+	// segment requested with If-Range for an older version of the file gets the whole new file.
+	var reader io.Reader = file
+	length := fileInfo.Size()
+	partial := false
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" && ifRangeMatches(r, etag, fileInfo.ModTime()) {
+		start, n, ok := parseByteRange(rangeHeader, fileInfo.Size())
+		if !ok {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", fileInfo.Size()))
+			http.Error(w, "Requested range not satisfiable", http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		if n >= 0 {
+			if _, err := file.Seek(start, io.SeekStart); err != nil {
+				serverError(w, "Failed to seek file", err)
+				return
+			}
+			reader = io.LimitReader(file, n)
+			length = n
+			partial = true
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+n-1, fileInfo.Size()))
+		}
+	}
+
+	// With -snapshot, serve a private copy so a process writing the file can't corrupt the
+	// download (only of the requested range, parallel segments would each copy the whole file)
+	if snapshotDownloads && r.Method != http.MethodHead {
+		snapshot, err := snapshotFile(file, length)
+		if err != nil {
+			serverError(w, "Failed to snapshot file", err)
+			return
+		}
+		defer removeSnapshot(snapshot)
+		reader = snapshot
+	}
+	// -detect-changes reports a mid-transfer modification in a trailer, which HTTP/1.1 can only
+	// send with chunked encoding, so Content-Length is left out in that mode
+	// Otherwise the body is sent with identity encoding: Flush does not switch a response with
+	// Content-Length to chunks, and at most length bytes are read so a file that grows during
+	// the transfer can't overrun the declared size
+	checkChanges := detectChanges && !snapshotDownloads
+	if checkChanges {
+		w.Header().Set("Trailer", contentChangedHeader)
+	} else {
+		w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+		reader = io.LimitReader(reader, length)
+	}
+	if partial {
+		w.WriteHeader(http.StatusPartialContent)
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
 
-	// 8. Stream file in chunks
-	buf := make([]byte, 1024*1024)
+	// 8. Stream file in chunks (with a progress line on the terminal in -v mode)
+	progress := newProgressPrinter(fileName, length)
+	defer progress.finish()
+	buf := make([]byte, transferBufSize)
+	var sent int64
 	for {
-		n, err := file.Read(buf)
+		n, err := reader.Read(buf)
 		if n > 0 {
 			_, writeErr := w.Write(buf[:n])
 			if writeErr != nil {
 				fmt.Printf("Failed to write download response: %v\n", writeErr)
 				return
 			}
+			sent += int64(n)
+			metricBytesDownloaded.Add(int64(n))
+			progress.add(int64(n))
 			// Flush to ensure real-time transmission
 			if flusher, ok := w.(http.Flusher); ok {
 				flusher.Flush()
@@ -816,11 +2489,229 @@ func downloadHandler(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 		if err != nil {
+			// The status line has gone out already; aborting the connection is the only way to
+			// tell the client, a chunked body would otherwise end as if it were complete
 			fmt.Printf("Failed to read download file: %v\n", err)
-			http.Error(w, "Failed to read file", http.StatusInternalServerError)
-			return
+			panic(http.ErrAbortHandler)
+		}
+	}
+	if !checkChanges && sent < length {
+		// The file shrank mid-transfer; a body shorter than its Content-Length is cut off too
+		fmt.Printf("Download of %s ended after %d of %d bytes, the file was truncated\n", fileName, sent, length)
+		panic(http.ErrAbortHandler)
+	}
+	if checkChanges {
+		changed := fileChanged(servePath, fileInfo.Size(), fileInfo.ModTime())
+		w.Header().Set(contentChangedHeader, strconv.FormatBool(changed))
+		if changed {
+			log.Printf("Warning: %s changed while it was being downloaded", decodedPath)
+		}
+	}
+	metricDownloads.Add(1)
+	if !partial {
+		// Range requests (e.g. chunk re-fetches) would flood the history and the -max-downloads count
+		recordTransfer(r, "download", fileName, length)
+		countDownload()
+		deleteDownloaded(r, cleanTargetPath)
+	}
+}
+
+// deleteDownloaded removes a file after a complete download with -delete-after-download. The
+// shared path is removed (for a symlink the link, not its target), and only if the client
+// did not go away before the end of the response.
+func deleteDownloaded(r *http.Request, path string) {
+	if !deleteAfterDownload || r.Context().Err() != nil {
+		return
+	}
+	if err := os.Remove(path); err != nil {
+		fmt.Printf("Failed to delete %s after download: %v\n", path, err)
+		return
+	}
+	fmt.Printf("Deleted %s after a complete download by %s\n", path, clientIP(r))
+}
+
+// parsePorts parses a comma-separated list of TCP ports (1-65535) without duplicates
+func parsePorts(list string) ([]string, error) {
+	var ports []string
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		port, err := strconv.Atoi(item)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %q", item)
+		}
+		item = strconv.Itoa(port) // "08080" and "8080" are the same port
+		if slices.Contains(ports, item) {
+			return nil, fmt.Errorf("port %s is listed twice", item)
+		}
+		ports = append(ports, item)
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("no port given")
+	}
+	return ports, nil
+}
+
+// parseSize parses a human-readable size such as 512, 64K, 4M or 1G (case-insensitive, optional trailing B)
+func parseSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	str = strings.TrimSuffix(str, "B")
+	if str == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	multiplier := int64(1)
+	switch str[len(str)-1] {
+	case 'K':
+		multiplier = 1024
+	case 'M':
+		multiplier = 1024 * 1024
+	case 'G':
+		multiplier = 1024 * 1024 * 1024
+	}
+	if multiplier != 1 {
+		str = str[:len(str)-1]
+	}
+
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	if n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+	return n * multiplier, nil
+}
+
+// sanitizeDownloadName turns a user-chosen download name into a safe file name
+// (no directories, control characters or quotes); falls back to fallback if nothing is left
+func sanitizeDownloadName(name, fallback string) string {
+	// Drop any directory part, regardless of which separator the client used
+	name = name[strings.LastIndexAny(name, `/\`)+1:]
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." {
+		return fallback
+	}
+	return name
+}
+
+// contentDisposition builds an attachment header with an ASCII fallback name
+// and the exact UTF-8 name encoded per RFC 5987 (filename*=UTF-8 with percent-encoding)
+func contentDisposition(fileName string) string {
+	var ascii, encoded strings.Builder
+	for _, r := range fileName {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			ascii.WriteByte('_')
+		} else {
+			ascii.WriteRune(r)
+		}
+	}
+	for _, b := range []byte(fileName) {
+		// RFC 5987 attr-char: ALPHA / DIGIT / "!#$&+-.^_`|~"
+		if ('a' <= b && b <= 'z') || ('A' <= b && b <= 'Z') || ('0' <= b && b <= '9') || strings.IndexByte("!#$&+-.^_`|~", b) >= 0 {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return fmt.Sprintf("attachment; filename=\"%s\"; filename*=UTF-8''%s", ascii.String(), encoded.String())
+}
+
+// glyph returns fancy for UTF-8 terminals and plain in ASCII-only mode
+func glyph(fancy, plain string) string {
+	if asciiOutput {
+		return plain
+	}
+	return fancy
+}
+
+// localeIsUTF8 reports whether the terminal locale (LC_ALL > LC_CTYPE > LANG) looks UTF-8 capable.
+// An unset locale is assumed to be UTF-8, as on most modern terminals.
+func localeIsUTF8() bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			value = strings.ToUpper(value)
+			return strings.Contains(value, "UTF-8") || strings.Contains(value, "UTF8")
 		}
 	}
+	return true
+}
+
+// progressInterval limits how often the -v progress line is redrawn
+const progressInterval = 500 * time.Millisecond
+
+// progressPrinter shows "sent / total (percent)" for one download, redrawn in place via carriage return
+type progressPrinter struct {
+	name      string
+	total     int64
+	sent      int64
+	lastPrint time.Time
+}
+
+// newProgressPrinter returns nil (a no-op printer) unless -v is set
+func newProgressPrinter(name string, total int64) *progressPrinter {
+	if !verbose {
+		return nil
+	}
+	return &progressPrinter{name: name, total: total}
+}
+
+func (p *progressPrinter) add(n int64) {
+	if p == nil {
+		return
+	}
+	p.sent += n
+	if time.Since(p.lastPrint) >= progressInterval {
+		p.print()
+	}
+}
+
+func (p *progressPrinter) print() {
+	percent := 100.0
+	if p.total > 0 {
+		percent = float64(p.sent) * 100 / float64(p.total)
+	}
+	fmt.Printf("\r- Sending %s: %s / %s (%.0f%%)   ", p.name, formatFileSize(p.sent), formatFileSize(p.total), percent)
+	p.lastPrint = time.Now()
+}
+
+// finish prints the final state and ends the progress line
+func (p *progressPrinter) finish() {
+	if p == nil {
+		return
+	}
+	p.print()
+	fmt.Println()
+}
+
+// uploadPageLink returns the path (with "?token=..." under -upload-token) to append to the
+// base URL for upload page links; empty for the plain root page
+func uploadPageLink() string {
+	link := uploadPagePath()
+	if uploadToken != "" {
+		link += "?token=" + url.QueryEscape(uploadToken)
+	}
+	if link == "/" && basePath == "" {
+		return ""
+	}
+	return link
+}
+
+// parseFileMode parses an octal permission string such as 644 or 0664
+func parseFileMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(strings.TrimPrefix(s, "0o"), 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid octal permissions %q (expected e.g. 0644)", s)
+	}
+	return os.FileMode(mode), nil
 }
 
 // printHelp shows help information
@@ -832,24 +2723,220 @@ func printHelp() {
 	fmt.Fprintln(writer, "  pair [OPTIONS]")
 	fmt.Fprintln(writer, "")
 	fmt.Fprintln(writer, "Options:")
-	fmt.Fprintln(writer, "  -h        Show this help message and exit")
-	fmt.Fprintln(writer, "  -f PATH   Specify single file to allow download (relative to current dir)")
-	fmt.Fprintln(writer, "  -x PATHS  Specify multiple files to allow download (comma-separated, no spaces)")
-	fmt.Fprintln(writer, "            Example: -x file1.txt,file2.pdf,data/file3.zip")
+	fmt.Fprintln(writer, "  -h\tShow this help message and exit")
+	fmt.Fprintln(writer, "  -version\tPrint the version, git commit and Go version of this build and exit")
+	fmt.Fprintln(writer, "  -v\tVerbose output: access log (with request IDs) and progress of active downloads")
+	fmt.Fprintln(writer, "  -log-file PATH\tWrite log output (warnings, and the access log with -v) to PATH instead of the terminal")
+	fmt.Fprintln(writer, "  -log-max-size SIZE\tRotate the -log-file to PATH.1 when it would grow beyond SIZE (default 10M, 0 = never)")
+	fmt.Fprintln(writer, "  -message TEXT|FILE\tShow instructions above the upload form (plain text, or a .html, .md or .txt file)")
+	fmt.Fprintln(writer, "  -default PAGE\tPage shown on / : upload (default), downloads or code (upload page moves to /upload)")
+	fmt.Fprintln(writer, "  -f PATH\tSpecify single file to allow download (relative to current dir)")
+	fmt.Fprintln(writer, "  -x PATHS\tSpecify multiple files to allow download (comma-separated, no spaces)")
+	fmt.Fprintln(writer, "\tExample: -x file1.txt,file2.pdf,data/file3.zip")
+	fmt.Fprintln(writer, "\tAn entry alias=path also serves the file as /download/alias (e.g. -x latest=builds/app.bin)")
+	fmt.Fprintln(writer, "  -tls\tServe HTTPS with a self-signed certificate generated at startup")
+	fmt.Fprintln(writer, "  -cert FILE -key FILE\tServe HTTPS with this PEM certificate and key (implies -tls)")
+	fmt.Fprintln(writer, "  -tls-min VERSION\tMinimum TLS version: 1.2 (default) or 1.3")
+	fmt.Fprintln(writer, "  -tls-ciphers LIST\tTLS 1.2 cipher suites (comma-separated Go names, default: ECDHE AES-GCM/ChaCha20)")
+	fmt.Fprintln(writer, "  -client-ca FILE\tOnly accept clients presenting a certificate issued by a CA in this PEM file (mutual TLS)")
+	fmt.Fprintln(writer, "  -http3\tExperimental: also serve HTTP/3 (QUIC, UDP on the same ports) with -tls or -cert, advertised via Alt-Svc")
+	fmt.Fprintln(writer, "  -confirm-clients\tAsk \"Allow IP? [y/N]\" on the terminal before serving a new client (answers last for the session)")
+	fmt.Fprintln(writer, "  -allow-cidr CIDRS\tOnly accept clients from these ranges, e.g. 192.168.1.0/24,fd00::/8 (default: all)")
+	fmt.Fprintln(writer, "  -confirm\tShow file name and size with a Download button before a download starts")
+	fmt.Fprintln(writer, "  -detect-changes\tFlag downloads of files modified mid-transfer (X-Content-Changed trailer, no Content-Length)")
+	fmt.Fprintln(writer, "  -precompressed\tServe FILE.gz (if present and up to date) gzip-encoded when FILE is downloaded by a gzip-capable client")
+	fmt.Fprintln(writer, "  -snapshot\tCopy each file to a temp file before serving it, for consistent downloads of live files")
+	fmt.Fprintln(writer, "  -tail\tStream /download/ files like tail -f, including data appended later (?tail=0 for a normal download)")
+	fmt.Fprintln(writer, "  -ignore-case\tMatch requested download paths case-insensitively (ambiguous matches are rejected)")
+	fmt.Fprintln(writer, "  -follow-symlinks\tServe symlinked files whose target is outside the current dir (see README security notes)")
+	fmt.Fprintln(writer, "  -as NAME\tDownload name offered for the -f file (e.g. -f report_v2_FINAL.pdf -as report.pdf)")
+	fmt.Fprintln(writer, "  -d DIR\tShare every file below DIR (recursive, relative to current dir)")
+	fmt.Fprintln(writer, "  -from-archive FILE\tShare the files inside a .zip or .tar.gz, read out of it on download instead of extracted")
+	fmt.Fprintln(writer, "  -glob PATTERNS\tWith -d: only share files whose name matches (comma-separated, e.g. *.pdf,*.jpg)")
+	fmt.Fprintln(writer, "  -exclude PATTERNS\tWith -d: omit files/folders matching by name or relative path (e.g. *.tmp,.DS_Store,node_modules)")
+	fmt.Fprintln(writer, "  -bufsize SIZE\tBuffer size for uploads/downloads, e.g. 64K, 4M (default 1M, range 4K-64M)")
+	fmt.Fprintln(writer, "  -base-path PREFIX\tServe all pages and links under PREFIX, e.g. /share (for reverse proxies)")
+	fmt.Fprintln(writer, "  -server-name NAME\tServer header of every response (default pair, empty to send none)")
+	fmt.Fprintln(writer, "  -title TEXT\tName of the share, e.g. \"Conference Handouts\", shown in browser tabs, page headings and above the QR code")
+	fmt.Fprintln(writer, "  -ports LIST\tListen on each of these ports, e.g. 8080,80,8888 (the first one is used in URLs/QR code)")
+	fmt.Fprintln(writer, "  -bind-lan\tListen only on the advertised LAN address, not on VPN/Docker/other interfaces or localhost")
+	fmt.Fprintln(writer, "  -selftest\tAfter startup, request the QR code URL from this machine and report whether it answers")
+	fmt.Fprintln(writer, "  -no-qr\tDo not print the QR code (useful for logs, CI and tmux panes)")
+	fmt.Fprintln(writer, "  -qr-svg FILE\tAlso write the QR code as scalable SVG to FILE (- = stdout, other output then goes to stderr)")
+	fmt.Fprintln(writer, "  -qr-all\tWith download files: also print a labeled QR code for the upload page")
+	fmt.Fprintln(writer, "  -qr-inline\tWith -f: put the file itself into the QR code if it fits (text, or a data: URI), readable offline")
+	fmt.Fprintln(writer, "  -status\tKeep a line below the QR code with the number of received files and bytes, updated in place")
+	fmt.Fprintln(writer, "  -print-urls\tAfter binding, print upload=URL, download=URL, ... lines to stdout (other output goes to stderr)")
+	fmt.Fprintln(writer, "  -quiet\tNo banner, QR codes or activity messages; warnings and errors are still printed")
+	fmt.Fprintln(writer, "  -ascii\tPlain ASCII output without emoji/block characters (automatic on non-UTF-8 locales)")
+	fmt.Fprintln(writer, "  -prefer IFACE\tAdvertise the address of this interface in the QR code (still binds to all)")
+	fmt.Fprintln(writer, "  -iface IFACE\tLike -prefer, but only its global IPv4/IPv6 address and an error instead of falling back to gateway discovery")
+	fmt.Fprintln(writer, "  -list-interfaces\tPrint all network interfaces and addresses and which address is advertised and why, then exit")
+	fmt.Fprintln(writer, "  -metrics\tExpose Prometheus-style transfer counters on /metrics")
+	fmt.Fprintln(writer, "  -api\tServe an OpenAPI 3 description of the HTTP API on /openapi.json (for client generators)")
+	fmt.Fprintln(writer, "  -manage\tServe /admin/uploads to list, download and delete received files (this computer only, or with -admin-token)")
+	fmt.Fprintln(writer, "  -history\tKeep an on-disk log of completed transfers and show it on /history")
+	fmt.Fprintln(writer, "  -history-file PATH\tHistory file used with -history (default: history.jsonl in the state directory)")
+	fmt.Fprintln(writer, "  -state-dir DIR\tDirectory for data kept across restarts (default: <user config dir>/pair)")
+	fmt.Fprintln(writer, "  -notes\tServe a shared in-memory notes board on /notes (cleared on exit)")
+	fmt.Fprintln(writer, "  -upload-token TOKEN\tRequire TOKEN for uploads (X-Upload-Token header, ?token= or form field); downloads stay open")
+	fmt.Fprintln(writer, "  -admin-token TOKEN\tAllow the /admin/ pages (-manage, config) from other computers with TOKEN (X-Admin-Token header or ?admin-token=)")
+	fmt.Fprintln(writer, "  -list-token TOKEN\tRequire TOKEN for the download list, /api/files and archives (X-List-Token or ?list-token=); /download/ links stay open")
+	fmt.Fprintln(writer, "  -file-mode MODE\tOctal permissions of saved uploads (default 0644)")
+	fmt.Fprintln(writer, "  -dir-mode MODE\tOctal permissions of created upload directories (default 0755)")
+	fmt.Fprintln(writer, "  -mirror DIR|URL\tCopy every saved upload to DIR, or POST it (multipart field \"files\") to URL")
+	fmt.Fprintln(writer, "  -s3-bucket NAME\tKeep files in an S3 bucket: uploads are moved there, the download list shows its files (credentials from AWS_* variables)")
+	fmt.Fprintln(writer, "  -s3-prefix PREFIX\tOnly use keys below PREFIX in the -s3-bucket")
+	fmt.Fprintln(writer, "  -s3-region REGION\tRegion of the -s3-bucket (default $AWS_REGION or us-east-1)")
+	fmt.Fprintln(writer, "  -s3-endpoint URL\tS3-compatible service to use instead of AWS, e.g. http://nas:9000 for MinIO")
+	fmt.Fprintln(writer, "  -sandbox\tRead-only: reject all uploads and never write to disk (see README for what is disabled)")
+	fmt.Fprintln(writer, "  -sniff-allow TYPES\tOnly accept uploads whose content (first 512 bytes) is one of these types, e.g. image/*,application/pdf")
+	fmt.Fprintln(writer, "  -max-file-size SIZE\tReject uploaded files larger than SIZE, e.g. 500M (other files in the batch are kept)")
+	fmt.Fprintln(writer, "  -max-total SIZE\tReject uploads (507) once all files uploaded in this session would exceed SIZE, e.g. 20G")
+	fmt.Fprintln(writer, "  -disk-rate SIZE\tWrite received files at no more than SIZE per second in total, e.g. 20M for a slow SD card")
+	fmt.Fprintln(writer, "  -max-form-size SIZE\tReject uploads (413) whose non-file form fields add up to more than SIZE (default 64K)")
+	fmt.Fprintln(writer, "  -max-form-fields N\tReject uploads (413) with more than N non-file form fields (default 32)")
+	fmt.Fprintln(writer, "  -single-upload\tAccept exactly one file per upload (the page's file picker allows only one, more are rejected with 400)")
+	fmt.Fprintln(writer, "  -upload-redirect URL\tSend the browser to URL after a successful upload (e.g. a thank-you page)")
+	fmt.Fprintln(writer, "  -no-js\tServe a plain HTML upload form that works with JavaScript disabled (no progress bar)")
+	fmt.Fprintln(writer, "  -on-conflict MODE\tUpload to an existing name with different content: error (409, default), rename or overwrite")
+	fmt.Fprintln(writer, "  -upload-cmd CMD\tStream each uploaded file into the stdin of CMD (run by the shell, $PAIR_FILENAME set) instead of saving it")
+	fmt.Fprintln(writer, "  -transform-cmd NAME=CMD\tServe /download/FILE?transform=NAME as the output of CMD run with FILE on stdin and in $PAIR_FILE (repeatable)")
+	fmt.Fprintln(writer, "  -inbox\tAsk for the sender's name on the upload page and save their files into a folder of that name")
+	fmt.Fprintln(writer, "  -dedupe\tDelete a new upload if a file with the same content (SHA-256) is already in the upload directory")
+	fmt.Fprintln(writer, "  -unzip\tExtract uploaded .zip archives into the upload directory (the archive is kept)")
+	fmt.Fprintln(writer, "  -unzip-dir DIR\tWith -unzip: extract into this subfolder of the upload directory instead")
+	fmt.Fprintln(writer, "  -strip-metadata\tRemove EXIF/GPS metadata from uploaded JPEG/PNG images (lossless)")
+	fmt.Fprintln(writer, "  -upload-idle-timeout DUR\tAbort uploads that receive no data for this long, e.g. 30s (default 0 = never)")
+	fmt.Fprintln(writer, "  -upload-window SPEC\tOnly accept uploads for a duration from launch (2h) or between START/END (18:00/20:00, either side optional)")
+	fmt.Fprintln(writer, "  -fsync\tFlush every saved upload to disk before reporting success, so a crash can't lose it")
+	fmt.Fprintln(writer, "  -delete-after-download\tDelete a shared file from disk once it has been downloaded in full (for one-shot secrets)")
+	fmt.Fprintln(writer, "  -max-downloads N\tStop serving all files (410 Gone) after N completed downloads in total")
+	fmt.Fprintln(writer, "  -max-downloads-exit\tWith -max-downloads: shut down once the limit is reached")
+	fmt.Fprintln(writer, "  -archive FORMAT\tArchive behind the Download All button: zip (default, /download-zip) or tar.gz (/download-tar)")
+	fmt.Fprintln(writer, "  -zip-pass PASS\tPassword-protect the /download-zip archive")
+	fmt.Fprintln(writer, "  -icons\tShow a file type icon (image, video, archive, document, code...) next to each name on the download list")
+	fmt.Fprintln(writer, "  -per-page N\tShow the download list in pages of N files, ?page= and ?per= pick another (default 100, 0 = one page)")
+	fmt.Fprintln(writer, "  -zip-warn SIZE\tAsk for confirmation before /download-zip when the files exceed SIZE (default 1G, 0 = never)")
+	fmt.Fprintln(writer, "  -zip-enc NAME\tEncryption used with -zip-pass: aes256 (default), aes128, zipcrypto (weak, legacy tools only)")
 	fmt.Fprintln(writer, "")
 	fmt.Fprintln(writer, "Access:")
 	fmt.Fprintln(writer, "  Upload Page: http://localhost:8080")
 	fmt.Fprintln(writer, "  Download List: http://localhost:8080/downloads (shows all downloadable files)")
 	fmt.Fprintln(writer, "  Direct Download: http://localhost:8080/download/[filename]")
+	fmt.Fprintln(writer, "  Download All (ZIP): http://localhost:8080/download-zip")
+	fmt.Fprintln(writer, "  Chunk Manifest: http://localhost:8080/chunks/[filename]")
+	fmt.Fprintln(writer, "  Code Entry: http://localhost:8080/code (type the code printed at startup)")
+	fmt.Fprintln(writer, "  Raw Upload: curl -T FILE http://localhost:8080/put/FILE")
+	fmt.Fprintln(writer, "  Resumable Upload: http://localhost:8080/resume?name=[filename] (GET offset, POST with Upload-Offset/Upload-Length)")
+	fmt.Fprintln(writer, "  WebSocket Upload: ws://localhost:8080/ws (file header, binary chunks, done marker)")
 	writer.Flush()
 }
 
 func main() {
 	// Parse command line flags
 	flag.BoolVar(&showHelp, "h", false, "Show help information")
+	flag.BoolVar(&showVersion, "version", false, "Print version, commit and Go version, then exit")
 	flag.StringVar(&allowSingleFilePath, "f", "", "Single file to allow download (relative to current dir)")
 	var multiFilesStr string
 	flag.StringVar(&multiFilesStr, "x", "", "Multiple files to allow download (comma-separated, relative to current dir)")
+	flag.StringVar(&sharedDir, "d", "", "Directory whose files are all allowed to download (recursive, relative to current dir)")
+	flag.StringVar(&sourceArchive, "from-archive", "", "Share the entries of this .zip or .tar.gz without extracting it")
+	var globStr, excludeStr string
+	flag.StringVar(&globStr, "glob", "", "With -d: only share files whose name matches these patterns (comma-separated, e.g. *.pdf,*.jpg)")
+	flag.StringVar(&excludeStr, "exclude", "", "With -d: omit files and folders matching these patterns by name or relative path (comma-separated)")
+	var bufSizeStr, maxFileSizeStr, maxTotalStr, zipWarnStr, messageArg, sniffAllowStr, logMaxSizeStr, diskRateStr, maxFormSizeStr string
+	flag.StringVar(&bufSizeStr, "bufsize", "1M", "Buffer size for uploads/downloads (e.g. 64K, 4M)")
+	flag.StringVar(&sniffAllowStr, "sniff-allow", "", "Only accept uploads whose content is one of these media types (e.g. image/*,application/pdf)")
+	flag.StringVar(&maxFileSizeStr, "max-file-size", "", "Largest accepted size of a single uploaded file (e.g. 500M, 2G)")
+	flag.StringVar(&maxTotalStr, "max-total", "", "Cap on the total size of all uploads in this session (e.g. 20G)")
+	flag.StringVar(&diskRateStr, "disk-rate", "", "Cap on the bytes per second uploads write to disk (e.g. 20M)")
+	flag.StringVar(&maxFormSizeStr, "max-form-size", "64K", "Cap on the size of all non-file fields of an upload form (0 = Go's 10M)")
+	flag.IntVar(&maxFormFields, "max-form-fields", 32, "Cap on the number of non-file fields of an upload form (0 = unlimited)")
+	flag.BoolVar(&disableQR, "no-qr", false, "Do not print the QR code (URLs are still shown)")
+	flag.StringVar(&qrSVGPath, "qr-svg", "", "Also write the QR code as SVG to this file (- for stdout)")
+	flag.BoolVar(&allQRCodes, "qr-all", false, "Also print a labeled QR code for the upload page when download files are configured")
+	flag.BoolVar(&qrInline, "qr-inline", false, "Put the -f file into the QR code instead of its URL when it fits")
+	flag.BoolVar(&statusLine, "status", false, "Show a live count of received files and bytes below the QR code")
+	flag.BoolVar(&printURLs, "print-urls", false, "Print machine-readable type=URL lines to stdout after binding (other output goes to stderr)")
+	flag.BoolVar(&quietMode, "quiet", false, "Suppress the banner, QR codes and activity messages (errors are still shown)")
+	flag.StringVar(&preferredIface, "prefer", "", "Network interface whose address is advertised in the QR code (server still binds to all)")
+	flag.StringVar(&requiredIface, "iface", "", "Network interface whose global address must be advertised (no fallback)")
+	flag.BoolVar(&listIfaces, "list-interfaces", false, "Print the network interfaces and which address would be advertised, then exit")
+	flag.BoolVar(&metricsEnabled, "metrics", false, "Expose Prometheus-style metrics on /metrics")
+	flag.BoolVar(&apiSpecEnabled, "api", false, "Serve an OpenAPI description of the HTTP API on /openapi.json")
+	flag.BoolVar(&notesEnabled, "notes", false, "Serve an in-memory notes board on /notes")
+	flag.BoolVar(&sandboxMode, "sandbox", false, "Read-only mode: reject uploads and never write to disk")
+	flag.StringVar(&stateDir, "state-dir", "", "Directory for data kept across restarts (default: pair in the user config dir)")
+	flag.BoolVar(&manageEnabled, "manage", false, "Serve /admin/uploads to list, download and delete received files")
+	flag.BoolVar(&historyEnabled, "history", false, "Record completed transfers on disk and show them on /history")
+	flag.StringVar(&historyPath, "history-file", "", "Transfer history file used with -history (JSON lines)")
+	flag.BoolVar(&deleteAfterDownload, "delete-after-download", false, "Delete a shared file from disk after its first complete download")
+	flag.Int64Var(&maxDownloads, "max-downloads", 0, "Stop serving all files after this many completed downloads (0 = unlimited)")
+	flag.BoolVar(&exitAfterDownloads, "max-downloads-exit", false, "With -max-downloads: shut down once the limit is reached")
+	flag.StringVar(&archiveFormat, "archive", "zip", "Archive offered by the Download All button: zip or tar.gz")
+	flag.StringVar(&zipPassword, "zip-pass", "", "Password-protect the /download-zip archive")
+	flag.BoolVar(&fileIcons, "icons", false, "Show file type icons on the download list")
+	flag.IntVar(&listPageSize, "per-page", 100, "Files per download list page (0 = all on one page)")
+	flag.StringVar(&zipWarnStr, "zip-warn", "1G", "Confirm /download-zip when the files add up to more than this (0 = never)")
+	flag.StringVar(&zipEncryptionName, "zip-enc", "aes256", "ZIP encryption scheme used with -zip-pass (aes256, aes128, zipcrypto)")
+	flag.StringVar(&singleFileAlias, "as", "", "Download name offered for the -f file (on-disk name is unchanged)")
+	flag.DurationVar(&uploadIdleTimeout, "upload-idle-timeout", 0, "Abort uploads that receive no data for this long (e.g. 30s, 0 = never)")
+	var uploadWindowStr string
+	flag.StringVar(&uploadWindowStr, "upload-window", "", "Only accept uploads for a duration from launch (e.g. 2h) or between START/END (e.g. 18:00/20:00)")
+	flag.BoolVar(&fsyncUploads, "fsync", false, "Flush every saved upload to disk before reporting success")
+	flag.BoolVar(&asciiOutput, "ascii", false, "Plain ASCII terminal output (no emoji or block characters)")
+	flag.BoolVar(&verbose, "v", false, "Verbose output (access log and download progress on the terminal)")
+	flag.StringVar(&logFile, "log-file", "", "Write log output to this file instead of the terminal")
+	flag.StringVar(&logMaxSizeStr, "log-max-size", "10M", "Rotate the -log-file when it would grow beyond this size (0 = never)")
+	flag.StringVar(&uploadToken, "upload-token", "", "Token required to upload files (downloads stay open)")
+	flag.StringVar(&adminToken, "admin-token", "", "Token for the /admin/ endpoints from other computers (this computer only without it)")
+	flag.StringVar(&listToken, "list-token", "", "Token required to see the download list (direct download links stay open)")
+	var fileModeStr, dirModeStr string
+	flag.StringVar(&fileModeStr, "file-mode", "0644", "Octal permissions of saved uploads")
+	flag.StringVar(&dirModeStr, "dir-mode", "0755", "Octal permissions of created upload directories")
+	flag.StringVar(&mirrorTarget, "mirror", "", "Copy every saved upload to this directory, or POST it to this URL")
+	flag.StringVar(&s3Bucket, "s3-bucket", "", "Move uploads into this S3 bucket and share its files for download")
+	flag.StringVar(&s3Prefix, "s3-prefix", "", "Key prefix inside the -s3-bucket (e.g. pair/)")
+	flag.StringVar(&s3Region, "s3-region", cmp.Or(os.Getenv("AWS_REGION"), "us-east-1"), "Region of the -s3-bucket")
+	flag.StringVar(&s3Endpoint, "s3-endpoint", "", "URL of an S3-compatible service (e.g. http://nas:9000 for MinIO), default AWS")
+	flag.BoolVar(&singleUpload, "single-upload", false, "Accept only one file per upload request")
+	flag.StringVar(&uploadRedirect, "upload-redirect", "", "URL the browser is sent to after a successful upload")
+	flag.BoolVar(&noJSForm, "no-js", false, "Serve an upload form that works without JavaScript")
+	flag.StringVar(&conflictMode, "on-conflict", "error", "Uploads to an existing name with different content: error, rename or overwrite")
+	flag.StringVar(&uploadCommand, "upload-cmd", "", "Shell command each uploaded file is streamed into instead of being saved")
+	flag.Func("transform-cmd", "NAME=CMD: serve /download/FILE?transform=NAME as the output of CMD with FILE on stdin (repeatable)", addTransform)
+	flag.BoolVar(&inboxMode, "inbox", false, "Save uploads into a folder per sender name (asked on the upload page)")
+	flag.BoolVar(&dedupeEnabled, "dedupe", false, "Delete new uploads whose content already exists in the upload directory")
+	flag.BoolVar(&unzipUploads, "unzip", false, "Extract uploaded .zip archives into the upload directory")
+	flag.StringVar(&unzipDir, "unzip-dir", "", "With -unzip: subfolder of the upload directory to extract into")
+	flag.BoolVar(&stripMetadata, "strip-metadata", false, "Remove EXIF/GPS metadata from uploaded JPEG/PNG images")
+	flag.StringVar(&messageArg, "message", "", "Instructions shown above the upload form: text, or a .html/.md/.txt file")
+	flag.StringVar(&defaultPage, "default", "upload", "Page served on /: upload, downloads or code")
+	flag.StringVar(&basePath, "base-path", "", "URL prefix to serve under behind a reverse proxy (e.g. /share)")
+	flag.StringVar(&siteTitle, "title", "", "Name of the share shown in browser tabs, page headings and above the QR code")
+	flag.StringVar(&serverName, "server-name", "pair", "Server response header (empty to send none)")
+	var portsStr string
+	flag.StringVar(&portsStr, "ports", "8080", "Comma-separated TCP ports to listen on (the first one is advertised)")
+	flag.BoolVar(&bindLAN, "bind-lan", false, "Listen only on the advertised LAN address instead of all interfaces")
+	flag.BoolVar(&selfTest, "selftest", false, "Check that the advertised URL answers after startup")
+	flag.BoolVar(&tlsEnabled, "tls", false, "Serve HTTPS with a self-signed certificate")
+	flag.StringVar(&tlsCertFile, "cert", "", "PEM certificate file for HTTPS (implies -tls, requires -key)")
+	flag.StringVar(&tlsKeyFile, "key", "", "PEM private key file for -cert")
+	flag.StringVar(&tlsClientCAFile, "client-ca", "", "Require TLS client certificates issued by the CAs in this PEM file")
+	flag.StringVar(&tlsMinVersionName, "tls-min", "1.2", "Minimum TLS version (1.2 or 1.3)")
+	flag.StringVar(&tlsCipherNames, "tls-ciphers", "", "Comma-separated TLS 1.2 cipher suites (default: modern AEAD suites)")
+	flag.BoolVar(&http3Enabled, "http3", false, "Experimental: also serve HTTP/3 over QUIC (requires -tls or -cert)")
+	flag.BoolVar(&confirmClients, "confirm-clients", false, "Ask on the terminal before serving each new client IP")
+	var allowCIDRStr string
+	flag.StringVar(&allowCIDRStr, "allow-cidr", "", "Only accept clients from these ranges (comma-separated CIDRs, IPv4/IPv6)")
+	flag.BoolVar(&confirmDownloads, "confirm", false, "Show a confirmation page (file name and size) before each download")
+	flag.BoolVar(&detectChanges, "detect-changes", false, "Report files modified during a download in an X-Content-Changed trailer")
+	flag.BoolVar(&precompressed, "precompressed", false, "Serve an up-to-date FILE.gz sibling gzip-encoded to clients that accept it")
+	flag.BoolVar(&snapshotDownloads, "snapshot", false, "Serve a temporary copy of each file so live files download consistently")
+	flag.BoolVar(&tailMode, "tail", false, "Stream /download/ files like tail -f until the client disconnects")
+	flag.BoolVar(&ignoreCase, "ignore-case", false, "Match requested download paths case-insensitively")
+	flag.BoolVar(&followSymlinks, "follow-symlinks", false, "Serve symlinked files even if their target is outside the current directory")
 	flag.Parse()
 
 	// Show help if -h is specified
@@ -857,112 +2944,928 @@ func main() {
 		printHelp()
 		return
 	}
+	if showVersion {
+		fmt.Println(versionString())
+		return
+	}
 
-	// Parse -x parameter (split comma-separated paths, support ANY number of files)
-	if multiFilesStr != "" {
-		// Split by comma, trim whitespace, remove empty entries
-		paths := strings.Split(multiFilesStr, ",")
-		for _, p := range paths {
-			cleanPath := strings.TrimSpace(p)
-			if cleanPath != "" {
-				allowMultiFilePaths = append(allowMultiFilePaths, cleanPath)
-			}
+	if requiredIface != "" && preferredIface != "" {
+		fmt.Println("Error: -iface and -prefer cannot be used together (-iface never falls back)")
+		os.Exit(1)
+	}
+	if listIfaces {
+		if err := listInterfaces(os.Stdout); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
 		}
+		return
+	}
+	if requiredIface != "" {
+		if _, err := getLocalIPForInterface(requiredIface, true); err != nil {
+			fmt.Printf("Error: -iface: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
-		// Remove duplicate paths (optional but useful)
-		uniquePaths := make(map[string]bool)
-		var uniqueList []string
-		for _, p := range allowMultiFilePaths {
-			if !uniquePaths[p] {
-				uniquePaths[p] = true
-				uniqueList = append(uniqueList, p)
-			}
+	// With -qr-svg -, stdout carries only the SVG; everything else is printed to stderr
+	svgOut := os.Stdout
+	if qrSVGPath == "-" {
+		os.Stdout = os.Stderr
+	}
+
+	// Same for -print-urls, whose lines are the only thing on stdout
+	urlOut := os.Stdout
+	if printURLs {
+		if qrSVGPath == "-" {
+			fmt.Println("Error: -print-urls and -qr-svg - both need stdout, write the SVG to a file instead")
+			os.Exit(1)
 		}
-		allowMultiFilePaths = uniqueList
+		os.Stdout = os.Stderr
+	}
 
-		// Show number of files configured from -x
-		fmt.Printf("- Configured %d files for download via -x parameter\n", len(allowMultiFilePaths))
+	// Fall back to ASCII output automatically on non-UTF-8 terminals
+	if !localeIsUTF8() {
+		asciiOutput = true
 	}
 
-	// Validate parameters (only one of -f or -x can be used)
-	if allowSingleFilePath != "" && len(allowMultiFilePaths) > 0 {
-		fmt.Println("Error: Only one of -f (single file) or -x (multiple files) can be used")
+	siteTitle = strings.TrimSpace(siteTitle)
+	if strings.ContainsAny(siteTitle, "\r\n") {
+		fmt.Println("Error: -title must be a single line")
 		os.Exit(1)
 	}
 
-	// Get current working directory (absolute path)
-	var err error
-	currentWorkDir, err = os.Getwd()
-	if err != nil {
-		fmt.Printf("Failed to get current working directory: %v\n", err)
+	if listPageSize < 0 || listPageSize > maxPerPage {
+		fmt.Printf("Error: -per-page must be from 0 to %d\n", maxPerPage)
 		os.Exit(1)
 	}
-	currentWorkDir = filepath.Clean(currentWorkDir) // Ensure clean absolute path
 
-	// Register routes (no conflict)
-	http.HandleFunc("/", uploadFormHandler)             // Root path: upload page
-	http.HandleFunc("/upload", uploadHandler)           // Upload API
-	http.HandleFunc("/downloads", downloadsListHandler) // Download list page (simplified)
-	http.HandleFunc("/download/", downloadHandler)      // Download API (fixed prefix)
+	// Parse -ports parameter
+	ports, err := parsePorts(portsStr)
+	if err != nil {
+		fmt.Printf("Error: invalid -ports value: %v\n", err)
+		os.Exit(1)
+	}
+	listenPorts = ports
 
-	// Call the modified localIPString, receive IP and error return values
-	localIP, err := localIPString()
+	// Parse -bufsize parameter and validate its range
+	bufSize, err := parseSize(bufSizeStr)
 	if err != nil {
-		log.Fatalf("Failed to get local IP address: %v", err)
+		fmt.Printf("Error: invalid -bufsize value: %v\n", err)
+		os.Exit(1)
 	}
-	fmt.Printf("Local IP address: %s\n", localIP)
+	if bufSize < minBufSize || bufSize > maxBufSize {
+		fmt.Printf("Error: -bufsize must be between %s and %s\n", formatFileSize(minBufSize), formatFileSize(maxBufSize))
+		os.Exit(1)
+	}
+	transferBufSize = int(bufSize)
 
-	// Server startup messages
-	fmt.Printf("Server started, current working directory: %s\n", currentWorkDir)
-	fmt.Printf("- Upload Page: http://%s:8080\n", localIP)
+	// Normalize -base-path parameter (routes are matched without it, links are generated with it)
+	if basePath, err = normalizeBasePath(basePath); err != nil {
+		fmt.Printf("Error: -base-path: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Parse -sniff-allow parameter
+	if sniffAllowStr != "" {
+		if sniffAllowTypes, err = parseSniffTypes(sniffAllowStr); err != nil {
+			fmt.Printf("Error: -sniff-allow: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Parse -max-file-size parameter
+	if maxFileSizeStr != "" {
+		if maxFileSize, err = parseSize(maxFileSizeStr); err != nil || maxFileSize <= 0 {
+			fmt.Printf("Error: invalid -max-file-size value %q\n", maxFileSizeStr)
+			os.Exit(1)
+		}
+	}
+
+	// Parse -max-total parameter
+	if maxTotalStr != "" {
+		if maxTotalUpload, err = parseSize(maxTotalStr); err != nil || maxTotalUpload <= 0 {
+			fmt.Printf("Error: invalid -max-total value %q\n", maxTotalStr)
+			os.Exit(1)
+		}
+	}
+	if diskRateStr != "" {
+		if diskRate, err = parseSize(diskRateStr); err != nil || diskRate <= 0 {
+			fmt.Printf("Error: invalid -disk-rate value %q\n", diskRateStr)
+			os.Exit(1)
+		}
+	}
+	if maxFormSize, err = parseSize(maxFormSizeStr); err != nil || maxFormSize < 0 {
+		fmt.Printf("Error: invalid -max-form-size value %q\n", maxFormSizeStr)
+		os.Exit(1)
+	}
+	if maxFormFields < 0 {
+		fmt.Printf("Error: invalid -max-form-fields value %d\n", maxFormFields)
+		os.Exit(1)
+	}
+
+	// Validate -max-downloads parameters
+	if maxDownloads < 0 {
+		fmt.Printf("Error: invalid -max-downloads value %d\n", maxDownloads)
+		os.Exit(1)
+	}
+	if exitAfterDownloads && maxDownloads == 0 {
+		fmt.Println("Error: -max-downloads-exit can only be used together with -max-downloads")
+		os.Exit(1)
+	}
+
+	// Load -message (a file is read once at startup)
+	if messageArg != "" {
+		if messageHTML, err = loadMessage(messageArg); err != nil {
+			fmt.Printf("Error: -message: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Parse -zip-warn parameter
+	if zipWarnSize, err = parseSize(zipWarnStr); err != nil || zipWarnSize < 0 {
+		fmt.Printf("Error: invalid -zip-warn value %q\n", zipWarnStr)
+		os.Exit(1)
+	}
+
+	// Parse -log-max-size parameter
+	if logMaxSize, err = parseSize(logMaxSizeStr); err != nil || logMaxSize < 0 {
+		fmt.Printf("Error: invalid -log-max-size value %q\n", logMaxSizeStr)
+		os.Exit(1)
+	}
+
+	// Parse -upload-window parameter
+	if uploadWindowStr != "" {
+		if uploadWindowStart, uploadWindowEnd, err = parseUploadWindow(uploadWindowStr, time.Now()); err != nil {
+			fmt.Printf("Error: invalid -upload-window value: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Parse -allow-cidr parameter
+	if allowCIDRStr != "" {
+		if allowedCIDRs, err = parseCIDRList(allowCIDRStr); err != nil {
+			fmt.Printf("Error: -allow-cidr: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// -confirm-clients reads the answers from the terminal
+	if confirmClients && !stdinIsTerminal() {
+		fmt.Println("Error: -confirm-clients needs an interactive terminal on stdin to ask for permission")
+		os.Exit(1)
+	}
+
+	// The admin token opens more than the tokens handed to guests
+	if adminToken != "" && (adminToken == uploadToken || adminToken == listToken) {
+		fmt.Println("Error: -admin-token must differ from -upload-token and -list-token, which are given to guests")
+		os.Exit(1)
+	}
+
+	// -status keeps redrawing the last line of the terminal
+	if statusLine {
+		if quietMode {
+			fmt.Println("Error: -status cannot be used with -quiet")
+			os.Exit(1)
+		}
+		if confirmClients {
+			fmt.Println("Error: -status cannot be used with -confirm-clients (its prompts need the last line of the terminal)")
+			os.Exit(1)
+		}
+		if !stdoutIsTerminal() {
+			log.Printf("Warning: -status needs a terminal, the output is not one so no status line is shown")
+			statusLine = false
+		}
+	}
+
+	// Parse -file-mode / -dir-mode parameters
+	if uploadFileMode, err = parseFileMode(fileModeStr); err != nil {
+		fmt.Printf("Error: -file-mode: %v\n", err)
+		os.Exit(1)
+	}
+	if uploadDirMode, err = parseFileMode(dirModeStr); err != nil {
+		fmt.Printf("Error: -dir-mode: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Validate TLS parameters (-cert implies -tls)
+	if (tlsCertFile == "") != (tlsKeyFile == "") {
+		fmt.Println("Error: -cert and -key must be used together")
+		os.Exit(1)
+	}
+	if tlsCertFile != "" {
+		tlsEnabled = true
+	}
+	if _, ok := tlsVersions[tlsMinVersionName]; !ok {
+		fmt.Printf("Error: unknown -tls-min value %q (use 1.2 or 1.3)\n", tlsMinVersionName)
+		os.Exit(1)
+	}
+	if http3Enabled && !tlsEnabled {
+		fmt.Println("Error: -http3 runs over QUIC, which is always encrypted: use it together with -tls or -cert")
+		os.Exit(1)
+	}
+	if tlsCipherNames != "" {
+		if !tlsEnabled {
+			fmt.Println("Error: -tls-ciphers can only be used together with -tls or -cert")
+			os.Exit(1)
+		}
+		if _, err := parseCipherSuites(tlsCipherNames); err != nil {
+			fmt.Printf("Error: -tls-ciphers: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if tlsClientCAFile != "" {
+		if !tlsEnabled {
+			fmt.Println("Error: -client-ca can only be used together with -tls or -cert")
+			os.Exit(1)
+		}
+		if selfTest {
+			fmt.Println("Error: -selftest has no client certificate and cannot be used with -client-ca")
+			os.Exit(1)
+		}
+		if _, err := loadClientCAs(tlsClientCAFile); err != nil {
+			fmt.Printf("Error: -client-ca: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Validate -upload-redirect parameter (absolute http(s) URL or a path on this server)
+	if uploadRedirect != "" {
+		target, err := url.Parse(uploadRedirect)
+		if err != nil || (target.Scheme != "" && target.Scheme != "http" && target.Scheme != "https") {
+			fmt.Printf("Error: -upload-redirect must be an http(s) URL or a path, got %q\n", uploadRedirect)
+			os.Exit(1)
+		}
+	}
+
+	// Validate -zip-enc parameter
+	if _, ok := zipEncryptionMethods[zipEncryptionName]; !ok {
+		fmt.Printf("Error: unknown -zip-enc value %q (use aes256, aes128 or zipcrypto)\n", zipEncryptionName)
+		os.Exit(1)
+	}
+
+	// Validate -archive parameter (tar has no encryption, so -zip-pass needs ZIP)
+	if !slices.Contains(archiveFormats, archiveFormat) {
+		fmt.Printf("Error: unknown -archive value %q (use %s)\n", archiveFormat, strings.Join(archiveFormats, ", "))
+		os.Exit(1)
+	}
+	if archiveFormat == "tar.gz" && zipPassword != "" {
+		fmt.Println("Error: -zip-pass needs -archive zip, tar.gz archives cannot be encrypted")
+		os.Exit(1)
+	}
+
+	// Parse -x parameter (split comma-separated paths, support ANY number of files)
+	if multiFilesStr != "" {
+		// Split by comma, trim whitespace, remove empty entries
+		paths := strings.Split(multiFilesStr, ",")
+		for _, p := range paths {
+			cleanPath := strings.TrimSpace(p)
+			if cleanPath == "" {
+				continue
+			}
+			// alias=path also serves the file as /download/alias (an existing file whose name
+			// contains "=" is still taken literally)
+			if alias, target, found := strings.Cut(cleanPath, "="); found {
+				if _, err := os.Stat(cleanPath); err != nil {
+					alias, target = strings.TrimSpace(alias), strings.TrimSpace(target)
+					if _, err := sanitizeFileName(alias); err != nil || target == "" {
+						fmt.Printf("Error: -x entry %s: use alias=path with a plain alias name\n", cleanPath)
+						os.Exit(1)
+					}
+					if other, taken := downloadAliases[alias]; taken && other != target {
+						fmt.Printf("Error: -x alias %s is used for both %s and %s\n", alias, other, target)
+						os.Exit(1)
+					}
+					downloadAliases[alias] = target
+					cleanPath = target
+				}
+			}
+			allowMultiFilePaths = append(allowMultiFilePaths, cleanPath)
+		}
+
+		// Remove duplicate paths (optional but useful)
+		uniquePaths := make(map[string]bool)
+		var uniqueList []string
+		for _, p := range allowMultiFilePaths {
+			if !uniquePaths[p] {
+				uniquePaths[p] = true
+				uniqueList = append(uniqueList, p)
+			}
+		}
+		allowMultiFilePaths = uniqueList
+
+		// -ignore-case can't tell apart files that differ only by case
+		if ignoreCase {
+			seen := make(map[string]string)
+			for _, p := range allowMultiFilePaths {
+				key := strings.ToLower(filepath.Clean(p))
+				if other, ok := seen[key]; ok {
+					fmt.Printf("Error: -x entries %s and %s differ only by case, which is ambiguous with -ignore-case\n", other, p)
+					os.Exit(1)
+				}
+				seen[key] = p
+			}
+		}
+
+		// Reject entries that point outside the current directory now rather than at download time
+		for _, p := range allowMultiFilePaths {
+			if err := checkSharePath(p); err != nil {
+				fmt.Printf("Error: -x entry %s: %v\n", p, err)
+				os.Exit(1)
+			}
+		}
+
+		// An alias must not hide another allowed file of the same name
+		for alias, target := range downloadAliases {
+			for _, p := range allowMultiFilePaths {
+				if filepath.Clean(p) == alias && p != target {
+					fmt.Printf("Error: -x alias %s is also the path of another entry\n", alias)
+					os.Exit(1)
+				}
+			}
+		}
+
+		// Show number of files configured from -x
+		if !quietMode {
+			fmt.Printf("- Configured %d files for download via -x parameter\n", len(allowMultiFilePaths))
+		}
+	}
+
+	// Parse -glob / -exclude parameters (comma-separated patterns)
+	if globPatterns, err = parsePatternList(globStr); err != nil {
+		fmt.Printf("Error: -glob: %v\n", err)
+		os.Exit(1)
+	}
+	if excludePatterns, err = parsePatternList(excludeStr); err != nil {
+		fmt.Printf("Error: -exclude: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Validate -f and -d paths (same rules as -x entries)
+	if allowSingleFilePath != "" {
+		if err := checkSharePath(allowSingleFilePath); err != nil {
+			fmt.Printf("Error: -f %s: %v\n", allowSingleFilePath, err)
+			os.Exit(1)
+		}
+	}
+	if sharedDir != "" {
+		if err := checkSharePath(sharedDir); err != nil {
+			fmt.Printf("Error: -d %s: %v\n", sharedDir, err)
+			os.Exit(1)
+		}
+	}
+
+	// Validate parameters (only one of -f, -x or -d can be used)
+	sharingModes := 0
+	for _, used := range []bool{allowSingleFilePath != "", len(allowMultiFilePaths) > 0, sharedDir != ""} {
+		if used {
+			sharingModes++
+		}
+	}
+	if sharingModes > 1 {
+		fmt.Println("Error: Only one of -f (single file), -x (multiple files) or -d (directory) can be used")
+		os.Exit(1)
+	}
+	if sourceArchive != "" {
+		if conflicts := sourceArchiveConflicts(); len(conflicts) > 0 {
+			fmt.Printf("Error: -from-archive shares the entries of %s and cannot be used with %s\n", sourceArchive, strings.Join(conflicts, ", "))
+			os.Exit(1)
+		}
+	}
+	if len(globPatterns) > 0 && sharedDir == "" {
+		fmt.Println("Error: -glob can only be used together with -d")
+		os.Exit(1)
+	}
+	if len(excludePatterns) > 0 && sharedDir == "" {
+		fmt.Println("Error: -exclude can only be used together with -d")
+		os.Exit(1)
+	}
+	if singleFileAlias != "" && allowSingleFilePath == "" {
+		fmt.Println("Error: -as can only be used together with -f")
+		os.Exit(1)
+	}
+	if qrInline && allowSingleFilePath == "" {
+		fmt.Println("Error: -qr-inline can only be used together with -f")
+		os.Exit(1)
+	}
+	if !slices.Contains(defaultPages, defaultPage) {
+		fmt.Printf("Error: unknown -default value %q (use %s)\n", defaultPage, strings.Join(defaultPages, ", "))
+		os.Exit(1)
+	}
+	if !slices.Contains(conflictModes, conflictMode) {
+		fmt.Printf("Error: unknown -on-conflict value %q (use %s)\n", conflictMode, strings.Join(conflictModes, ", "))
+		os.Exit(1)
+	}
+	if defaultPage == "downloads" && !hasDownloadList() {
+		fmt.Println("Error: -default downloads requires a download list (-x or -d)")
+		os.Exit(1)
+	}
+	if sandboxMode {
+		if conflicts := sandboxConflicts(); len(conflicts) > 0 {
+			fmt.Printf("Error: -sandbox never writes to disk and cannot be used with %s\n", strings.Join(conflicts, ", "))
+			os.Exit(1)
+		}
+		if allowSingleFilePath == "" && !hasDownloadList() {
+			fmt.Println("Error: -sandbox disables uploads, so it needs files to share (-f, -x or -d)")
+			os.Exit(1)
+		}
+		if defaultPage == "upload" && hasDownloadList() {
+			defaultPage = "downloads" // The upload page would only show an error
+		}
+	}
+	if s3Bucket != "" {
+		if conflicts := storageConflicts(); len(conflicts) > 0 {
+			fmt.Printf("Error: -s3-bucket shares the files of the bucket and cannot be used with %s\n", strings.Join(conflicts, ", "))
+			os.Exit(1)
+		}
+	} else if s3Prefix != "" || s3Endpoint != "" {
+		fmt.Println("Error: -s3-prefix and -s3-endpoint can only be used together with -s3-bucket")
+		os.Exit(1)
+	}
+	if inboxMode && uploadCommand != "" {
+		fmt.Println("Error: -inbox saves uploads into folders and cannot be used with -upload-cmd")
+		os.Exit(1)
+	}
+	if unzipDir != "" {
+		if !unzipUploads {
+			fmt.Println("Error: -unzip-dir can only be used together with -unzip")
+			os.Exit(1)
+		}
+		if !filepath.IsLocal(unzipDir) {
+			fmt.Printf("Error: -unzip-dir %s must be a relative path inside the upload directory\n", unzipDir)
+			os.Exit(1)
+		}
+	}
+
+	// Send log output to the -log-file from here on; the banner and progress lines stay on the terminal
+	if logFile != "" {
+		output, err := openRotatingLog(logFile, logMaxSize)
+		if err != nil {
+			fmt.Printf("Error: cannot open -log-file: %v\n", err)
+			os.Exit(1)
+		}
+		log.SetOutput(output)
+	} else if statusLine {
+		log.SetOutput(statusAwareWriter{os.Stderr}) // Log lines must not run into the status line
+	}
+
+	// Get current working directory (absolute path)
+	currentWorkDir, err = os.Getwd()
+	if err != nil {
+		fmt.Printf("Failed to get current working directory: %v\n", err)
+		os.Exit(1)
+	}
+	currentWorkDir = filepath.Clean(currentWorkDir) // Ensure clean absolute path
+	realWorkDir, err = filepath.EvalSymlinks(currentWorkDir)
+	if err != nil {
+		fmt.Printf("Failed to resolve current working directory: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Received files stay in the upload directory unless -s3-bucket moves them on; the bucket is
+	// listed once now, so wrong credentials or names show up before anyone uploads
+	storage = localStorage{dir: currentWorkDir}
+	if s3Bucket != "" {
+		bucket, err := newS3Storage(s3Bucket, s3Prefix, s3Region, s3Endpoint)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if _, err := bucket.List(); err != nil {
+			fmt.Printf("Error: cannot access %s: %v\n", bucket.Location(""), err)
+			os.Exit(1)
+		}
+		storage = bucket
+	}
+
+	// The -d directory must exist and be inside the current working directory
+	if sharedDir != "" {
+		absDir := filepath.Clean(filepath.Join(currentWorkDir, sharedDir))
+		if !isWithinDir(currentWorkDir, absDir) {
+			fmt.Printf("Error: -d directory %s must be within the current directory\n", sharedDir)
+			os.Exit(1)
+		}
+		if stat, err := os.Stat(absDir); err != nil || !stat.IsDir() {
+			fmt.Printf("Error: -d %s is not a directory\n", sharedDir)
+			os.Exit(1)
+		}
+	}
+
+	// Index the -from-archive entries (the archive itself may be anywhere, it is never served whole)
+	if sourceArchive != "" {
+		if err := openSourceArchive(workDirPath(sourceArchive)); err != nil {
+			fmt.Printf("Error: -from-archive: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Validate -mirror target (URLs are checked on use, directories are created now)
+	if mirrorTarget != "" {
+		if isMirrorURL(mirrorTarget) {
+			if _, err := url.Parse(mirrorTarget); err != nil {
+				fmt.Printf("Error: invalid -mirror URL: %v\n", err)
+				os.Exit(1)
+			}
+		} else {
+			mirrorTarget = workDirPath(mirrorTarget)
+			if err := os.MkdirAll(mirrorTarget, uploadDirMode); err != nil {
+				fmt.Printf("Error: failed to create -mirror directory: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// Generate the numeric code for the scan-free /code page
+	accessCode, err = generateAccessCode(accessCodeDigits)
+	if err != nil {
+		fmt.Printf("Failed to generate access code: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Generate the code recipients compare to make sure they reached this computer
+	sessionCode, err = generateSessionCode()
+	if err != nil {
+		fmt.Printf("Failed to generate session code: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Load the persistent state (a missing default directory only disables persistence)
+	if stateDir == "" {
+		if stateDir, err = defaultStateDir(); err != nil {
+			log.Printf("Warning: cannot determine the state directory, nothing will persist across restarts: %v", err)
+		}
+	} else {
+		stateDir = workDirPath(stateDir)
+	}
+	if stateDir != "" {
+		if err := loadState(); err != nil {
+			fmt.Printf("Error: failed to load state from %s: %v\n", stateDir, err)
+			os.Exit(1)
+		}
+	}
+	saveStateOnSignal()
+
+	// Load the transfer history from previous runs
+	if historyEnabled {
+		if historyPath == "" {
+			if stateDir == "" {
+				fmt.Println("Error: cannot determine default -history-file, use -history-file or -state-dir")
+				os.Exit(1)
+			}
+			historyPath = filepath.Join(stateDir, historyFileName)
+		} else {
+			historyPath = workDirPath(historyPath)
+		}
+		if err := loadHistory(historyPath); err != nil {
+			fmt.Printf("Error: failed to load history: %v\n", err)
+			os.Exit(1)
+		}
+	} else if historyPath != "" {
+		fmt.Println("Error: -history-file can only be used together with -history")
+		os.Exit(1)
+	}
+
+	// Remove abandoned resumable uploads left over from previous runs (never in -sandbox)
+	if !sandboxMode {
+		cleanupStaleParts(currentWorkDir)
+	}
+
+	// Register routes (no conflict)
+	registerRoutes(http.DefaultServeMux)
+
+	// Call the modified localIPString, receive IP and error return values
+	localIP, err := localIPString()
+	if err != nil {
+		log.Fatalf("Failed to get local IP address: %v", err)
+	}
+
+	// With -quiet, all remaining normal output is discarded (log warnings still go to stderr,
+	// later fatal errors are printed to stderr explicitly)
+	if quietMode {
+		devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			fmt.Printf("Error: -quiet: %v\n", err)
+			os.Exit(1)
+		}
+		os.Stdout = devNull
+	}
+	fmt.Printf("Local IP address: %s\n", localIP)
+
+	// Load or generate the HTTPS certificate
+	var tlsConfig *tls.Config
+	scheme := "http"
+	if tlsEnabled {
+		tlsConfig, err = buildTLSConfig(localIP)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to set up TLS: %v\n", err)
+			os.Exit(1)
+		}
+		scheme = "https"
+	}
+	baseURL := scheme + "://" + urlHost(localIP) + ":" + listenPorts[0] + basePath
+
+	// Server startup messages
+	fmt.Printf("Server started, current working directory: %s\n", currentWorkDir)
+	if sandboxMode {
+		fmt.Println("- Sandbox: read-only, uploads are disabled and nothing is written to disk")
+	} else {
+		fmt.Printf("- Upload Page: %s%s\n", baseURL, uploadPageLink())
+	}
+	if uploadToken != "" && !sandboxMode {
+		fmt.Println("  Uploads require the token (included in the link above, downloads stay open)")
+	}
+	if listToken != "" && hasDownloadList() {
+		fmt.Println("- The download list and archives require the list token (included in the links below, direct download links stay open)")
+	}
 
 	// Show allowed files info
 	if allowSingleFilePath != "" {
 		allowedAbsPath := filepath.Clean(filepath.Join(currentWorkDir, allowSingleFilePath))
 		fmt.Printf("- Allowed download file: %s (absolute: %s)\n", allowSingleFilePath, allowedAbsPath)
-		fmt.Printf("  Direct download URL: http://%s:8080/download/%s\n", localIP, allowSingleFilePath)
+		fmt.Printf("  Direct download URL: %s/download/%s\n", baseURL, allowSingleFilePath)
+		if singleFileAlias != "" {
+			fmt.Printf("  Offered to recipients as: %s\n", sanitizeDownloadName(singleFileAlias, filepath.Base(allowedAbsPath)))
+		}
 	} else if len(allowMultiFilePaths) > 0 {
-		fmt.Printf("- Download List Page: http://%s:8080/downloads (shows all configured files)\n", localIP)
+		fmt.Printf("- Download List Page: %s%s (shows all configured files)\n", baseURL, withListToken("/downloads"))
+		archiveRoute, archiveLabel := archiveLink()
+		fmt.Printf("- Download All as %s: %s%s\n", archiveLabel, baseURL, withListToken(archiveRoute))
+		if zipPassword != "" {
+			fmt.Printf("  ZIP is password-protected (%s encryption)\n", zipEncryptionName)
+		}
 		fmt.Printf("- Allowed download files (total: %d):\n", len(allowMultiFilePaths))
 		for i, p := range allowMultiFilePaths {
 			absPath := filepath.Clean(filepath.Join(currentWorkDir, p))
 			fmt.Printf("  %d. %s (absolute: %s)\n", i+1, p, absPath)
-			fmt.Printf("     Direct download URL: http://%s:8080/download/%s\n", localIP, p)
+			fmt.Printf("     Direct download URL: %s/download/%s\n", baseURL, p)
+			if alias := downloadAlias(p); alias != "" {
+				fmt.Printf("     Alias URL: %s/download/%s\n", baseURL, url.PathEscape(alias))
+			}
+		}
+	} else if sharedDir != "" {
+		fmt.Printf("- Download List Page: %s%s (shows all shared files)\n", baseURL, withListToken("/downloads"))
+		archiveRoute, archiveLabel := archiveLink()
+		fmt.Printf("- Download All as %s: %s%s\n", archiveLabel, baseURL, withListToken(archiveRoute))
+		if zipPassword != "" {
+			fmt.Printf("  ZIP is password-protected (%s encryption)\n", zipEncryptionName)
+		}
+		sharedFiles := getDownloadableFiles()
+		fmt.Printf("- Shared directory: %s (%d files, %s", sharedDir, len(sharedFiles), formatFileSize(totalFileSize(sharedFiles)))
+		if len(globPatterns) > 0 {
+			fmt.Printf(" matching %s", strings.Join(globPatterns, ", "))
+		}
+		if len(excludePatterns) > 0 {
+			fmt.Printf(", excluding %s", strings.Join(excludePatterns, ", "))
+		}
+		fmt.Println(")")
+	} else if sourceArchive != "" {
+		entries := getDownloadableFiles()
+		fmt.Printf("- Download List Page: %s%s (shows the files in %s)\n", baseURL, withListToken("/downloads"), sourceArchive)
+		fmt.Printf("- Shared archive: %s (%d files, %s, read out of the archive on download)\n", sourceArchive, len(entries), formatFileSize(totalFileSize(entries)))
+	} else if remoteStorage() {
+		fmt.Printf("- Download List Page: %s%s (shows the files in %s)\n", baseURL, withListToken("/downloads"), storage.Location(""))
+	} else {
+		fmt.Println("- No download files configured (use -f for single file, -x for multiple files or -d for a directory)")
+	}
+	fmt.Printf("- No camera? Open %s/code and enter code %s\n", baseURL, accessCode)
+	fmt.Printf("- Session code: %s (shown at the bottom of every page, recipients should check it matches)\n", sessionCode)
+	if allowCIDRStr != "" {
+		fmt.Printf("- Only clients from %s are allowed\n", allowCIDRStr)
+	}
+	if confirmClients {
+		fmt.Println("- New clients wait until you allow them here (y/N)")
+	}
+	if bindLAN {
+		fmt.Printf("- Listening only on %s (other interfaces and localhost cannot connect)\n", urlHost(localIP))
+	}
+	if len(listenPorts) > 1 {
+		fmt.Printf("- Also listening on port %s (same pages, if port %s is blocked replace it in the URL)\n", strings.Join(listenPorts[1:], ", "), listenPorts[0])
+	}
+	if inboxMode && !sandboxMode {
+		fmt.Printf("- Inbox: uploads are saved into a folder per sender name (%s without a name)\n", inboxDefaultFolder)
+	}
+	if mirrorTarget != "" {
+		fmt.Printf("- Uploads are mirrored to: %s\n", mirrorTarget)
+	}
+	if logFile != "" {
+		if logMaxSize > 0 {
+			fmt.Printf("- Log: %s (rotated to %s.1 at %s)\n", logFile, logFile, formatFileSize(logMaxSize))
+		} else {
+			fmt.Printf("- Log: %s\n", logFile)
+		}
+	}
+	if remoteStorage() {
+		fmt.Printf("- Storage: %s (uploads are moved there once received, the download list shows its files)\n", storage.Location(""))
+	}
+	if uploadWindowStr != "" && !sandboxMode {
+		fmt.Printf("- Uploads are accepted %s\n", uploadWindowDescription())
+	}
+	if deleteAfterDownload {
+		fmt.Println("- Files are DELETED from disk after their first complete download")
+	}
+	if maxDownloads > 0 {
+		fmt.Printf("- Files stop being served after %d downloads", maxDownloads)
+		if exitAfterDownloads {
+			fmt.Print(", then pair exits")
 		}
+		fmt.Println()
+	}
+	if maxTotalUpload > 0 && !sandboxMode {
+		fmt.Printf("- Uploads stop once %s have been received in total\n", formatFileSize(maxTotalUpload))
+	}
+	if diskRate > 0 && !sandboxMode {
+		fmt.Printf("- Uploads are written to disk at up to %s/s\n", formatFileSize(diskRate))
+	}
+	if len(sniffAllowTypes) > 0 {
+		fmt.Printf("- Only uploads detected as %s are accepted\n", strings.Join(sniffAllowTypes, ", "))
+	}
+	if uploadCommand != "" {
+		fmt.Printf("- Uploads are piped into: %s (not saved)\n", uploadCommand)
+	}
+	if len(transformCommands) > 0 {
+		fmt.Printf("- Transforms: %s (add ?transform=NAME to a download link)\n", strings.Join(transformNames(), ", "))
+	}
+	if metricsEnabled {
+		fmt.Printf("- Metrics: %s/metrics\n", baseURL)
+	}
+	if apiSpecEnabled {
+		fmt.Printf("- OpenAPI description: %s/openapi.json\n", baseURL)
+	}
+	if notesEnabled {
+		fmt.Printf("- Notes Board: %s/notes\n", baseURL)
+	}
+	if tlsEnabled {
+		if tlsCertFile != "" {
+			fmt.Printf("- HTTPS with certificate %s (TLS %s+)\n", tlsCertFile, tlsMinVersionName)
+		} else {
+			fmt.Printf("- HTTPS with a self-signed certificate (TLS %s+), browsers will show a warning\n", tlsMinVersionName)
+			fmt.Printf("  Fingerprint (SHA-256): %s", certificateFingerprint(tlsConfig.Certificates[0]))
+			if reusedCertificate {
+				fmt.Print(" (same certificate as last time)")
+			}
+			fmt.Println()
+		}
+		if tlsClientCAFile != "" {
+			fmt.Printf("- Only clients with a certificate from %s can connect (others fail the TLS handshake)\n", tlsClientCAFile)
+		}
+	}
+	if http3Enabled {
+		fmt.Println("- HTTP/3 (experimental) on the same UDP ports, browsers switch over after the first page")
+	}
+	if historyEnabled {
+		fmt.Printf("- Transfer History: %s/history (saved to %s)\n", baseURL, historyPath)
+	}
+	if manageEnabled {
+		if adminToken != "" {
+			fmt.Printf("- Manage Uploads: %s%s\n", baseURL, withAdminToken(adminUploadsPath))
+		} else {
+			localURL := scheme + "://localhost:" + listenPorts[0] + basePath
+			if bindLAN {
+				localURL = baseURL // Localhost cannot connect, the LAN address still counts as this computer
+			}
+			fmt.Printf("- Manage Uploads: %s%s (only from this computer, or with -admin-token)\n", localURL, adminUploadsPath)
+		}
+	}
+
+	// The URL recipients should open: encoded in the QR code and checked by -selftest
+	var shareURL, sharePrompt string
+	if allowSingleFilePath != "" {
+		sharePrompt = "Scan below qrcode to download file: " + allowSingleFilePath
+		shareURL = baseURL + "/download/" + allowSingleFilePath
+	} else if hasDownloadList() {
+		sharePrompt = "Scan below qrcode to access downloadable files list."
+		shareURL = baseURL + withListToken("/downloads")
 	} else {
-		fmt.Println("- No download files configured (use -f for single file or -x for multiple files)")
+		sharePrompt = "Scan below qrcode to upload files."
+		shareURL = baseURL + uploadPageLink()
+	}
+
+	// With -qr-inline, a small -f file goes into the QR code itself (the banner says which it is)
+	if qrInline && ((!disableQR && !quietMode) || qrSVGPath != "") {
+		prepareInlineQR(filepath.Join(currentWorkDir, allowSingleFilePath))
+	}
+
+	// Long URLs (deep paths, tokens) make dense codes, better to say so before printing one
+	if (!disableQR && !quietMode) || qrSVGPath != "" {
+		if qrInlineContent != "" {
+			warnDenseInlineQR(qrConfig().Level)
+		} else {
+			warnLongQRURL(shareURL, qrConfig().Level)
+		}
+	}
+
+	// The QR code scrolls off in long sessions, so it can be printed again without a restart
+	if !quietMode && reprintOnSignal(func() { printShareQRCodes(sharePrompt, shareURL, baseURL) }) {
+		fmt.Printf("- QR code gone from the terminal? Run: kill -USR1 %d\n", os.Getpid())
+	}
+	if qrSVGPath != "" {
+		if err := writeQRSVG(qrSVGPath, qrTarget(shareURL), svgOut); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to write -qr-svg: %v\n", err)
+			os.Exit(1)
+		}
+		if qrSVGPath != "-" {
+			fmt.Printf("- QR code saved as SVG: %s\n", qrSVGPath)
+		}
+	}
+
+	// From here on the -status line stays below everything else that is printed
+	if statusLine {
+		if err := startStatusLine(); err != nil {
+			fmt.Printf("Error: -status: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Start HTTP server (metrics need the connection hook and error-counting middleware,
+	// every request gets an X-Request-ID)
+	listenHost := "" // All interfaces
+	if bindLAN {
+		listenHost = localIP // Zone IDs of link-local addresses are kept by JoinHostPort
+	}
+	advertisedURL, listenAddress = baseURL, listenHost
+	server := &http.Server{Addr: net.JoinHostPort(listenHost, listenPorts[0]), Handler: http.DefaultServeMux, TLSConfig: tlsConfig}
+	if basePath != "" {
+		server.Handler = basePathHandler(server.Handler)
+	}
+	if tlsConfig != nil && tlsConfig.MinVersion < tls.VersionTLS13 && !http2Capable(tlsConfig.CipherSuites) {
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){} // HTTP/1.1 only
+	}
+	if metricsEnabled {
+		server.Handler = metricsMiddleware(server.Handler)
+		server.ConnState = trackConnState
+	}
+	if confirmClients {
+		server.Handler = clientPromptMiddleware(server.Handler)
+		startClientPrompts()
+	}
+	if len(allowedCIDRs) > 0 {
+		server.Handler = cidrMiddleware(server.Handler)
+	}
+	server.Handler = requestIDMiddleware(serverHeaderMiddleware(server.Handler))
+	if http3Enabled {
+		server.Handler = altSvcMiddleware(server.Handler)
+	}
+
+	// Bind before printing the QR code, so -selftest can reach the server right away.
+	// Every -ports listener is served by the same server, so Shutdown stops them all.
+	var listeners []net.Listener
+	for _, port := range listenPorts {
+		listener, err := net.Listen("tcp", net.JoinHostPort(listenHost, port))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to start server on port %s: %v\n", port, err)
+			os.Exit(1)
+		}
+		listeners = append(listeners, listener)
+	}
+	var h3Server *http3.Server
+	if http3Enabled {
+		h3Server = startHTTP3(listenHost, server.Handler, tlsConfig)
+	}
+	if printURLs {
+		printShareURLs(urlOut, baseURL)
 	}
 
 	// Execute QR code generation logic asynchronously in a goroutine to avoid blocking HTTP server startup
+	// (skipped entirely with -no-qr, the URLs above are enough), then run -selftest
 	go func() {
-		config := qrterminal.Config{
-			Level:          qrterminal.M,
-			Writer:         os.Stdout,
-			HalfBlocks:     true,
-			BlackChar:      BLACK_BLACK,
-			WhiteBlackChar: WHITE_BLACK,
-			WhiteChar:      WHITE_WHITE,
-			BlackWhiteChar: BLACK_WHITE,
-			QuietZone:      1,
-		}
-
-		var qrURL string
-		if allowSingleFilePath != "" {
-			fmt.Printf("\n📱️Scan below qrcode to download file: %s\n", allowSingleFilePath)
-			qrURL = "http://" + localIP + ":8080/download/" + allowSingleFilePath
-		} else if len(allowMultiFilePaths) > 0 {
-			fmt.Printf("\n📱️Scan below qrcode to access downloadable files list.\n")
-			qrURL = "http://" + localIP + ":8080/downloads"
-		} else {
-			fmt.Printf("\n📱️Scan below qrcode to upload files.\n")
-			qrURL = "http://" + localIP + ":8080"
+		if !disableQR && !quietMode {
+			printShareQRCodes(sharePrompt, shareURL, baseURL)
+		}
+		if selfTest {
+			runSelfTest(shareURL)
 		}
-		qrterminal.GenerateWithConfig(qrURL, config)
 	}()
 
-	// Start HTTP server
-	err = http.ListenAndServe(":8080", nil)
+	// With -max-downloads-exit, finish the running requests and exit once the share has closed
+	shutdownDone := make(chan struct{})
+	if exitAfterDownloads {
+		go func() {
+			<-shareClosed
+			stopHTTP3(h3Server)
+			server.Shutdown(context.Background())
+			close(shutdownDone)
+		}()
+	}
+
+	serve := func(listener net.Listener) error {
+		if tlsEnabled {
+			return server.ServeTLS(listener, "", "") // Certificate comes from TLSConfig
+		}
+		return server.Serve(listener)
+	}
+	for _, listener := range listeners[1:] {
+		go func() {
+			if err := serve(listener); err != nil && err != http.ErrServerClosed {
+				fmt.Fprintf(os.Stderr, "Failed to serve on %s: %v\n", listener.Addr(), err)
+			}
+		}()
+	}
+	err = serve(listeners[0])
+	if err == http.ErrServerClosed {
+		<-shutdownDone // Serve returns as soon as Shutdown starts, not when it is done
+		saveStateOrWarn()
+		return
+	}
 	if err != nil {
-		fmt.Printf("Failed to start server: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Failed to start server: %v\n", err)
 	}
 }
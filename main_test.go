@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestShare sets the globals main derives from the flags for a share of a fresh temp
+// directory with the flag defaults, and returns that directory. Tests adjust the flags
+// they exercise before calling newTestMux.
+func newTestShare(t *testing.T) string {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	currentWorkDir, realWorkDir = dir, dir
+	storage = localStorage{dir: dir}
+	transferBufSize = 32 * 1024
+	uploadFileMode, uploadDirMode = 0644, 0755
+	conflictMode = "error"
+	archiveFormat = "zip"
+	listPageSize = 100
+	maxFormFields = 32
+	sessionCode, accessCode = "ABC-DEF", "123456"
+	return dir
+}
+
+// newTestMux returns the routes enabled by the current globals
+func newTestMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	registerRoutes(mux)
+	return mux
+}
+
+// writeTestFile creates name in dir with data
+func writeTestFile(t *testing.T, dir, name string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestURLHost(t *testing.T) {
+	for _, test := range []struct {
+		ip, want string
+	}{
+		{"192.168.1.20", "192.168.1.20"},
+		{"2001:db8::1", "[2001:db8::1]"},
+		{"fe80::1%eth0", "[fe80::1%25eth0]"},
+		{"fe80::1%12", "[fe80::1%2512]"}, // Windows zones are interface numbers
+	} {
+		got := urlHost(test.ip)
+		if got != test.want {
+			t.Errorf("urlHost(%q) = %q, want %q", test.ip, got, test.want)
+			continue
+		}
+		// The URL must parse back to the address, zone included
+		parsed, err := url.Parse("http://" + got + ":8080/upload")
+		if err != nil {
+			t.Errorf("URL with %s does not parse: %v", got, err)
+		} else if parsed.Hostname() != test.ip {
+			t.Errorf("URL with %s has host %q, want %q", got, parsed.Hostname(), test.ip)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"512", 512, true},
+		{"64k", 64 << 10, true},
+		{"4MB", 4 << 20, true},
+		{"1G", 1 << 30, true},
+		{"8589934591G", 8589934591 << 30, true}, // The largest count of G that fits
+		{"8589934592G", 0, false},
+		{"9999999999G", 0, false},
+		{"9223372036854775807", math.MaxInt64, true},
+		{"9223372036854775807K", 0, false},
+		{"-1M", 0, false},
+		{"", 0, false},
+	} {
+		got, err := parseSize(test.in)
+		if (err == nil) != test.ok || got != test.want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d, ok %v", test.in, got, err, test.want, test.ok)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Upload management page (via -manage): /admin/uploads lists the files in the upload directory
+// with download and delete buttons. Deleting is more than uploading, so it is an /admin/ page:
+// served to this computer, or to anyone with -admin-token.
+
+// ManagedFile is one entry of the /admin/uploads page
+type ManagedFile struct {
+	Name        string
+	Size        int64
+	ModTime     time.Time
+	DownloadURL string
+}
+
+// isLocalClient reports whether the request comes from this machine (loopback or one of its addresses)
+func isLocalClient(r *http.Request) bool {
+	host, _, _ := strings.Cut(clientIP(r), "%") // Zone of a link-local IPv6 address
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameOrigin rejects form posts from other sites (a page elsewhere must not delete files
+// through the operator's browser); requests without Origin or Sec-Fetch-Site pass
+func sameOrigin(r *http.Request) bool {
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	return err == nil && parsed.Host == r.Host
+}
+
+// managedFilePath validates a file name from the page: a plain name of a regular file (not a
+// symlink or folder) directly in the upload directory
+func managedFilePath(name string) (string, error) {
+	name, err := sanitizeFileName(name)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(currentWorkDir, name)
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", name)
+	}
+	return path, nil
+}
+
+// managedFiles lists the regular files in the upload directory, newest first.
+// In-progress uploads (.pair-part files) are left out.
+func managedFiles() ([]ManagedFile, error) {
+	entries, err := os.ReadDir(currentWorkDir)
+	if err != nil {
+		return nil, err
+	}
+	var files []ManagedFile
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasSuffix(entry.Name(), partSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		query := url.Values{"file": {entry.Name()}}
+		if adminToken != "" {
+			query.Set(adminTokenParam, adminToken) // The token travels along in links, like on the upload page
+		}
+		files = append(files, ManagedFile{
+			Name:        entry.Name(),
+			Size:        info.Size(),
+			ModTime:     info.ModTime(),
+			DownloadURL: routePath(adminUploadsPath) + "?" + query.Encode(),
+		})
+	}
+	slices.SortFunc(files, func(a, b ManagedFile) int { return b.ModTime.Compare(a.ModTime) })
+	return files, nil
+}
+
+// manageTemplate renders the list; html/template escapes the file names
+var manageTemplate = template.Must(template.New("manage").Funcs(template.FuncMap{
+	"sessionFooter": sessionFooter,
+	"pageTitle":     pageTitle,
+	"when":          func(t time.Time) string { return t.Format(historyTimeFormat) },
+	"size":          formatFileSize,
+}).Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{pageTitle "Received Files"}}</title>
+    <style>
+        * {
+            margin: 0;
+            padding: 0;
+            box-sizing: border-box;
+        }
+
+        body {
+            max-width: 800px;
+            margin: 0 auto;
+            padding: 20px 15px;
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Arial, sans-serif;
+            line-height: 1.5;
+        }
+
+        h1 {
+            font-size: 1.8rem;
+            color: #333;
+            text-align: center;
+            margin-bottom: 20px;
+        }
+
+        table {
+            width: 100%;
+            border-collapse: collapse;
+        }
+
+        td {
+            padding: 8px 6px;
+            border-bottom: 1px solid #eee;
+            word-break: break-all;
+        }
+
+        .meta {
+            color: #999;
+            font-size: 0.8rem;
+            white-space: nowrap;
+        }
+
+        .actions {
+            white-space: nowrap;
+            text-align: right;
+        }
+
+        .actions form {
+            display: inline;
+        }
+
+        .btn {
+            padding: 5px 12px;
+            border: none;
+            border-radius: 4px;
+            color: white;
+            font-size: 0.9rem;
+            text-decoration: none;
+            cursor: pointer;
+            background-color: #4285f4;
+        }
+
+        .btn-delete {
+            background-color: #d93025;
+        }
+
+        .empty-message {
+            text-align: center;
+            color: #666;
+            margin: 20px 0;
+        }
+
+        .back-link {
+            display: inline-block;
+            margin-top: 20px;
+            color: #4285f4;
+            text-decoration: none;
+        }
+    </style>
+</head>
+<body>
+    <h1>Received Files</h1>
+    {{if .Files}}
+    <table>
+        {{range .Files}}
+        <tr>
+            <td>{{.Name}}</td>
+            <td class="meta">{{size .Size}}<br>{{when .ModTime}}</td>
+            <td class="actions">
+                <a href="{{.DownloadURL}}" class="btn">Download</a>
+                <form method="post" action="{{$.ManagePath}}" onsubmit="return confirm({{printf "Delete %s?" .Name}})">
+                    <input type="hidden" name="file" value="{{.Name}}">
+                    {{if $.Token}}<input type="hidden" name="admin-token" value="{{$.Token}}">{{end}}
+                    <button type="submit" class="btn btn-delete">Delete</button>
+                </form>
+            </td>
+        </tr>
+        {{end}}
+    </table>
+    {{else}}
+    <div class="empty-message">No files in {{.Dir}}</div>
+    {{end}}
+    <a href="{{.UploadPage}}" class="back-link">← Back to Upload</a>
+    {{sessionFooter}}
+</body>
+</html>
+`))
+
+// manageHandler lists the upload directory (GET), sends one file (GET ?file=) or deletes one (POST).
+// It is served behind adminOnly.
+func manageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Only GET and POST methods are supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if !sameOrigin(r) {
+			http.Error(w, "Cross-site requests are not allowed", http.StatusForbidden)
+			return
+		}
+		path, err := managedFilePath(r.PostFormValue("file"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Cannot delete file: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := os.Remove(path); err != nil {
+			serverError(w, "Failed to delete file", err)
+			return
+		}
+		fmt.Printf("Deleted %s (via %s, %s)\n", path, adminUploadsPath, clientIP(r))
+		http.Redirect(w, r, withAdminToken(routePath(adminUploadsPath)), http.StatusSeeOther)
+		return
+	}
+
+	if name := r.URL.Query().Get("file"); name != "" {
+		serveManagedFile(w, name)
+		return
+	}
+
+	files, err := managedFiles()
+	if err != nil {
+		serverError(w, "Failed to read upload directory", err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	manageTemplate.Execute(w, struct {
+		Files      []ManagedFile
+		Dir        string
+		ManagePath string
+		Token      string
+		UploadPage string
+	}{files, currentWorkDir, routePath(adminUploadsPath), adminToken, routePath(uploadPageLink())})
+}
+
+// serveManagedFile sends one file of the upload directory as an attachment
+func serveManagedFile(w http.ResponseWriter, name string) {
+	path, err := managedFilePath(name)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Cannot download file: %v", err), http.StatusNotFound)
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		serverError(w, "Failed to open file", err)
+		return
+	}
+	defer file.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", contentDisposition(filepath.Base(path)))
+	var body io.Reader = file
+	if info, err := file.Stat(); err == nil {
+		// Never send more than the declared length, even if the file grows meanwhile
+		w.Header().Set("Content-Length", fmt.Sprint(info.Size()))
+		body = io.LimitReader(file, info.Size())
+	}
+	if _, err := io.CopyBuffer(w, body, make([]byte, transferBufSize)); err != nil {
+		fmt.Printf("Failed to write download response: %v\n", err)
+	}
+}
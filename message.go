@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// loadMessage turns the -message value into HTML for the upload page. An existing .html/.htm
+// file is the operator's own markup and used as is, a .md/.markdown file is rendered as
+// Markdown, any other file or a literal value is shown as escaped plain text.
+func loadMessage(value string) (string, error) {
+	info, err := os.Stat(value)
+	if err != nil || !info.Mode().IsRegular() {
+		return plainTextHTML(value), nil
+	}
+	content, err := os.ReadFile(value)
+	if err != nil {
+		return "", err
+	}
+	switch strings.ToLower(filepath.Ext(value)) {
+	case ".html", ".htm":
+		return string(content), nil
+	case ".md", ".markdown":
+		return markdownHTML(string(content)), nil
+	default:
+		return plainTextHTML(string(content)), nil
+	}
+}
+
+// plainTextHTML escapes text, keeping blank lines as paragraph breaks and newlines as line breaks
+func plainTextHTML(text string) string {
+	var out strings.Builder
+	for _, para := range strings.Split(strings.ReplaceAll(strings.TrimSpace(text), "\r\n", "\n"), "\n\n") {
+		if para = strings.TrimSpace(para); para != "" {
+			fmt.Fprintf(&out, "<p>%s</p>\n", strings.ReplaceAll(html.EscapeString(para), "\n", "<br>"))
+		}
+	}
+	return out.String()
+}
+
+var (
+	markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	markdownBullet  = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+	markdownNumber  = regexp.MustCompile(`^\d+[.)]\s+(.*)$`)
+	markdownLink    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	markdownCode    = regexp.MustCompile("`([^`]+)`")
+	markdownBold    = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	markdownItalic  = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+)
+
+// markdownHTML renders the Markdown subset instructions need: headings, paragraphs, bullet
+// and numbered lists, bold, italic, code and links. All text is escaped first, and links
+// only keep http(s), mailto and relative targets, so the result is safe to embed.
+func markdownHTML(source string) string {
+	var out strings.Builder
+	var para []string
+	list := "" // "ul" or "ol" while inside a list
+
+	flushPara := func() {
+		if len(para) > 0 {
+			fmt.Fprintf(&out, "<p>%s</p>\n", markdownInline(strings.Join(para, " ")))
+			para = nil
+		}
+	}
+	closeList := func() {
+		if list != "" {
+			fmt.Fprintf(&out, "</%s>\n", list)
+			list = ""
+		}
+	}
+	listItem := func(kind, text string) {
+		flushPara()
+		if list != kind {
+			closeList()
+			fmt.Fprintf(&out, "<%s>\n", kind)
+			list = kind
+		}
+		fmt.Fprintf(&out, "<li>%s</li>\n", markdownInline(text))
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if m := markdownHeading.FindStringSubmatch(line); m != nil {
+			flushPara()
+			closeList()
+			level := min(len(m[1])+1, 6) // The page title is already the <h1>
+			fmt.Fprintf(&out, "<h%d>%s</h%d>\n", level, markdownInline(m[2]), level)
+		} else if m := markdownBullet.FindStringSubmatch(line); m != nil {
+			listItem("ul", m[1])
+		} else if m := markdownNumber.FindStringSubmatch(line); m != nil {
+			listItem("ol", m[1])
+		} else if line == "" {
+			flushPara()
+			closeList()
+		} else {
+			closeList()
+			para = append(para, line)
+		}
+	}
+	flushPara()
+	closeList()
+	return out.String()
+}
+
+// markdownInline escapes one block of text and applies the inline Markdown markup
+func markdownInline(text string) string {
+	text = html.EscapeString(text)
+	text = markdownCode.ReplaceAllString(text, "<code>$1</code>")
+	text = markdownLink.ReplaceAllStringFunc(text, func(match string) string {
+		m := markdownLink.FindStringSubmatch(match)
+		if !safeMessageLink(html.UnescapeString(m[2])) {
+			return m[1]
+		}
+		return fmt.Sprintf(`<a href="%s">%s</a>`, m[2], m[1])
+	})
+	text = markdownBold.ReplaceAllString(text, "<strong>$1$2</strong>")
+	text = markdownItalic.ReplaceAllString(text, "<em>$1$2</em>")
+	return text
+}
+
+// safeMessageLink reports whether a Markdown link target may become an href (no javascript: etc.)
+func safeMessageLink(target string) bool {
+	lower := strings.ToLower(target)
+	if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "mailto:") {
+		return true
+	}
+	return !strings.Contains(lower, ":") // Relative links like /downloads or photos.html
+}
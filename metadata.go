@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxStripSize skips metadata stripping for unusually large images (they are read into memory)
+const maxStripSize = 256 * 1024 * 1024
+
+// JPEG markers
+const (
+	jpegSOI  = 0xD8 // Start of image
+	jpegSOS  = 0xDA // Start of scan (entropy-coded data follows)
+	jpegAPP1 = 0xE1 // EXIF / XMP
+	jpegAPPD = 0xED // Photoshop IRB / IPTC
+	jpegCOM  = 0xFE // Comment
+)
+
+// pngSignature starts every PNG file
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadataChunks are dropped when stripping PNG metadata
+var pngMetadataChunks = map[string]bool{"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true}
+
+// stripImageMetadata removes EXIF/GPS and similar metadata from a saved JPEG or PNG in place.
+// Image data is copied byte for byte (no re-encoding). Returns false if nothing was removed.
+func stripImageMetadata(path string) (bool, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
+		return false, nil
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if stat.Size() > maxStripSize {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	// Trust the content, not the extension
+	var stripped []byte
+	switch {
+	case len(data) > 2 && data[0] == 0xFF && data[1] == jpegSOI:
+		stripped, err = stripJPEGMetadata(data)
+	case bytes.HasPrefix(data, pngSignature):
+		stripped, err = stripPNGMetadata(data)
+	default:
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if len(stripped) == len(data) {
+		// Nothing to remove
+		return false, nil
+	}
+
+	// Write to a temporary file first so a failure never leaves a truncated image behind
+	tmpPath := path + ".strip"
+	if err := os.WriteFile(tmpPath, stripped, stat.Mode().Perm()); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return false, err
+	}
+	return true, nil
+}
+
+// stripJPEGMetadata drops APP1 (EXIF/XMP), APP13 (IPTC) and comment segments.
+// JFIF (APP0), ICC profiles (APP2) and Adobe (APP14) segments are kept because they affect rendering.
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	pos := 2
+
+	for {
+		if pos+4 > len(data) || data[pos] != 0xFF {
+			return nil, fmt.Errorf("malformed JPEG segment at offset %d", pos)
+		}
+		marker := data[pos+1]
+
+		// Padding 0xFF bytes between segments
+		if marker == 0xFF {
+			pos++
+			continue
+		}
+
+		// Everything from the start of scan on is image data
+		if marker == jpegSOS {
+			return append(out, data[pos:]...), nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, fmt.Errorf("malformed JPEG segment length at offset %d", pos)
+		}
+		if marker != jpegAPP1 && marker != jpegAPPD && marker != jpegCOM {
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+}
+
+// stripPNGMetadata drops text, EXIF and timestamp chunks, keeping every other chunk intact
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	pos := len(pngSignature)
+
+	for pos < len(data) {
+		if pos+12 > len(data) {
+			return nil, fmt.Errorf("malformed PNG chunk at offset %d", pos)
+		}
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		chunkType := string(data[pos+4 : pos+8])
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, fmt.Errorf("malformed PNG chunk length at offset %d", pos)
+		}
+		if crc32.ChecksumIEEE(data[pos+4:end-4]) != binary.BigEndian.Uint32(data[end-4:end]) {
+			return nil, fmt.Errorf("bad CRC in PNG chunk %s", chunkType)
+		}
+		if !pngMetadataChunks[chunkType] {
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+		if chunkType == "IEND" {
+			break
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// Transfer counters exposed on /metrics (via -metrics) in Prometheus text format
+var (
+	metricUploads           atomic.Int64 // Successfully saved uploaded files
+	metricDownloads         atomic.Int64 // Completed downloads
+	metricBytesUploaded     atomic.Int64 // Bytes written to disk by uploads
+	metricBytesDownloaded   atomic.Int64 // Bytes sent to clients by downloads
+	metricActiveConnections atomic.Int64 // Currently open client connections
+	metricErrors            atomic.Int64 // Responses with status >= 400
+)
+
+// statusRecorder captures the response status code while keeping http.Flusher available
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *statusRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Hijack hands the connection over for /ws, whose library needs http.Hijacker directly
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(rec.ResponseWriter).Hijack()
+}
+
+// metricsMiddleware counts error responses for every request
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status >= 400 {
+			metricErrors.Add(1)
+		}
+	})
+}
+
+// trackConnState keeps the active connection gauge up to date (used as http.Server.ConnState)
+func trackConnState(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		metricActiveConnections.Add(1)
+	case http.StateHijacked, http.StateClosed:
+		metricActiveConnections.Add(-1)
+	}
+}
+
+// metricsHandler writes all counters in Prometheus text exposition format
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	metrics := []struct {
+		name, kind, help string
+		value            int64
+	}{
+		{"pair_uploads_total", "counter", "Number of successfully uploaded files.", metricUploads.Load()},
+		{"pair_downloads_total", "counter", "Number of completed file downloads.", metricDownloads.Load()},
+		{"pair_uploaded_bytes_total", "counter", "Bytes received by uploads.", metricBytesUploaded.Load()},
+		{"pair_downloaded_bytes_total", "counter", "Bytes sent by downloads.", metricBytesDownloaded.Load()},
+		{"pair_active_connections", "gauge", "Number of open client connections.", metricActiveConnections.Load()},
+		{"pair_errors_total", "counter", "Number of responses with an error status (>= 400).", metricErrors.Load()},
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, m := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", m.name, m.help, m.name, m.kind, m.name, m.value)
+	}
+}
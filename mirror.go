@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// mirrorClient forwards uploads to a remote -mirror URL
+var mirrorClient = &http.Client{Timeout: 30 * time.Minute}
+
+// isMirrorURL reports whether the -mirror target is a URL rather than a directory
+func isMirrorURL(target string) bool {
+	return strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://")
+}
+
+// mirrorUpload copies a saved upload to the -mirror target in the background.
+// Failures are only logged: the upload itself has already succeeded.
+func mirrorUpload(savePath string) {
+	if mirrorTarget == "" {
+		return
+	}
+
+	go func() {
+		var err error
+		if isMirrorURL(mirrorTarget) {
+			err = mirrorToURL(savePath, mirrorTarget)
+		} else {
+			err = mirrorToDir(savePath, mirrorTarget)
+		}
+		if err != nil {
+			log.Printf("Warning: failed to mirror %s to %s: %v", filepath.Base(savePath), mirrorTarget, err)
+		} else if verbose {
+			log.Printf("Mirrored %s to %s", filepath.Base(savePath), mirrorTarget)
+		}
+	}()
+}
+
+// mirrorToDir streams the file into the mirror directory (never overwriting existing files)
+func mirrorToDir(savePath, dir string) error {
+	src, err := os.Open(savePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dstPath := filepath.Join(dir, filepath.Base(savePath))
+	dst, err := os.OpenFile(dstPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, uploadFileMode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.CopyBuffer(dst, src, make([]byte, transferBufSize)); err != nil {
+		dst.Close()
+		os.Remove(dstPath)
+		return err
+	}
+	return dst.Close()
+}
+
+// mirrorToURL POSTs the file as multipart/form-data (field "files", like /upload),
+// streaming it through a pipe so the file is never held in memory
+func mirrorToURL(savePath, target string) error {
+	src, err := os.Open(savePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	pipeReader, pipeWriter := io.Pipe()
+	form := multipart.NewWriter(pipeWriter)
+	go func() {
+		part, err := form.CreateFormFile("files", filepath.Base(savePath))
+		if err == nil {
+			_, err = io.CopyBuffer(part, src, make([]byte, transferBufSize))
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		pipeWriter.CloseWithError(err)
+	}()
+
+	resp, err := mirrorClient.Post(target, form.FormDataContentType(), pipeReader)
+	if err != nil {
+		pipeReader.CloseWithError(err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("remote returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
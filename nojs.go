@@ -0,0 +1,220 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// noJSUploadTemplate is the upload page with -no-js: a plain multipart form the browser submits
+// itself, for devices that have JavaScript disabled. No progress bar and no paste support.
+var noJSUploadTemplate = template.Must(template.New("nojs-upload").Funcs(template.FuncMap{
+	"sessionFooter": sessionFooter,
+	"pageTitle":     pageTitle,
+	"pageHeading":   pageHeading,
+}).Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{pageTitle "Upload files"}}</title>
+    <style>
+        * {
+            margin: 0;
+            padding: 0;
+            box-sizing: border-box;
+        }
+
+        body {
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px 15px;
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Arial, sans-serif;
+            line-height: 1.5;
+        }
+
+        .message {
+            margin-bottom: 20px;
+            color: #333;
+        }
+
+        .message p, .message ul, .message ol {
+            margin-bottom: 10px;
+        }
+
+        .message ul, .message ol {
+            padding-left: 20px;
+        }
+
+        .upload-box {
+            padding: 25px 15px;
+            border: 2px dashed #ccc;
+            border-radius: 8px;
+            text-align: center;
+        }
+
+        h1 {
+            font-size: 1.8rem;
+            margin-bottom: 20px;
+            color: #333;
+        }
+
+        input[type=file] {
+            margin: 20px 0;
+            padding: 10px;
+            width: 100%;
+            font-size: 1rem;
+        }
+
+        input[type=text], input[type=password] {
+            margin-bottom: 20px;
+            padding: 10px;
+            width: 100%;
+            font-size: 1rem;
+            border: 1px solid #ccc;
+            border-radius: 4px;
+        }
+
+        button {
+            padding: 12px 30px;
+            background-color: #4285f4;
+            color: white;
+            border: none;
+            border-radius: 4px;
+            font-size: 1rem;
+            margin-bottom: 20px;
+            width: 100%;
+            max-width: 300px;
+        }
+
+        .hint {
+            color: #999;
+            font-size: 0.8rem;
+            margin-bottom: 20px;
+        }
+
+        .download-link {
+            color: #4285f4;
+            font-size: 0.9rem;
+            margin-top: 20px;
+            display: block;
+            text-decoration: none;
+        }
+    </style>
+</head>
+<body>
+    {{if .Message}}<div class="message">{{.Message}}</div>{{end}}
+    <div class="upload-box">
+        <h1>{{pageHeading "Upload files"}}</h1>
+        <form method="post" enctype="multipart/form-data" action="{{.Action}}">
+            {{if .Token}}<input type="hidden" name="token" value="{{.Token}}">{{end}}
+            {{if .TokenField}}<input type="password" name="token" placeholder="Upload token" autocomplete="off">{{end}}
+            {{if .Inbox}}<input type="text" name="inbox" placeholder="Your name" maxlength="64" autocomplete="name">{{end}}
+            <input type="file" name="files" {{if .Multiple}}multiple {{end}}required>
+            <br>
+            <button type="submit">Upload</button>
+            <div class="hint">There is no progress bar: keep this page open until the result appears</div>
+        </form>
+        <a href="{{.Downloads}}" class="download-link">📌 Go to Download List Page</a>
+        {{if .Notes}}<a href="{{.Notes}}" class="download-link">📝 Notes Board</a>{{end}}
+    </div>
+    {{sessionFooter}}
+</body>
+</html>
+`))
+
+// noJSResultTemplate shows the outcome of a -no-js form upload
+var noJSResultTemplate = template.Must(template.New("nojs-result").Funcs(template.FuncMap{
+	"sessionFooter": sessionFooter,
+	"pageTitle":     pageTitle,
+}).Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{pageTitle "Upload complete"}}</title>
+    <style>
+        body {
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px 15px;
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Arial, sans-serif;
+            line-height: 1.5;
+        }
+
+        .success {
+            padding: 15px;
+            border-radius: 4px;
+            color: #28a745;
+            border: 1px solid #28a745;
+            background-color: #f8fff9;
+            word-break: break-word;
+        }
+
+        a {
+            display: inline-block;
+            margin-top: 20px;
+            padding: 10px 20px;
+            color: #4285f4;
+            border: 1px solid #4285f4;
+            border-radius: 4px;
+            text-decoration: none;
+        }
+    </style>
+</head>
+<body>
+    <div class="success">{{.Message}}</div>
+    <a href="{{.Back}}">Back to Upload page</a>
+    {{sessionFooter}}
+</body>
+</html>
+`))
+
+// serveNoJSUploadForm writes the -no-js upload page. The token and inbox fields come before the
+// files, so they also reach -upload-cmd, which reads the form as it streams in.
+func serveNoJSUploadForm(w http.ResponseWriter, r *http.Request) {
+	data := struct {
+		Message           template.HTML
+		Action, Token     string
+		TokenField, Inbox bool
+		Multiple          bool
+		Downloads, Notes  string
+	}{
+		Message:   template.HTML(messageHTML),
+		Action:    routePath("/upload"),
+		Inbox:     inboxMode,
+		Multiple:  !singleUpload,
+		Downloads: routePath("/downloads"),
+	}
+	// Like the regular page: the token is only filled in for visitors who came with ?token=
+	if uploadToken != "" {
+		if validUploadToken(r.URL.Query().Get("token")) {
+			data.Token = uploadToken
+		} else {
+			data.TokenField = true
+		}
+	}
+	if notesEnabled {
+		data.Notes = routePath("/notes")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	noJSUploadTemplate.Execute(w, data)
+}
+
+// noJSResultWanted reports whether an upload came from the -no-js form, i.e. a browser that
+// submitted it natively and would otherwise be left with a bare text response
+func noJSResultWanted(r *http.Request) bool {
+	return noJSForm && r.Header.Get("X-Requested-With") == "" && strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// serveNoJSResult shows the upload message as a page with a link back to the form
+func serveNoJSResult(w http.ResponseWriter, msg string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	noJSResultTemplate.Execute(w, struct {
+		Message, Back string
+	}{msg, routePath(uploadPagePath())})
+}
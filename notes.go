@@ -0,0 +1,167 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Notes board limits (via -notes)
+const (
+	maxNotes       = 50  // Oldest notes are dropped beyond this count
+	maxNoteLength  = 500 // Characters per note
+	noteTimeFormat = "15:04:05"
+)
+
+// Note is one message on the in-memory notes board
+type Note struct {
+	Text string
+	Time time.Time
+	From string // Client IP
+}
+
+// Notes are kept in memory only, newest first, so the board is empty again after a restart
+var (
+	notes   []Note
+	notesMu sync.Mutex
+)
+
+// notesTemplate renders the board; html/template escapes all user content
+var notesTemplate = template.Must(template.New("notes").Funcs(template.FuncMap{
+	"sessionFooter": sessionFooter,
+	"pageTitle":     pageTitle,
+	"clock":         func(t time.Time) string { return t.Format(noteTimeFormat) },
+}).Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{pageTitle "Notes"}}</title>
+    <style>
+        * {
+            margin: 0;
+            padding: 0;
+            box-sizing: border-box;
+        }
+
+        body {
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px 15px;
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Arial, sans-serif;
+            line-height: 1.5;
+        }
+
+        h1 {
+            font-size: 1.8rem;
+            color: #333;
+            text-align: center;
+            margin-bottom: 20px;
+        }
+
+        textarea {
+            width: 100%;
+            padding: 10px;
+            font-size: 1rem;
+            border: 1px solid #ccc;
+            border-radius: 4px;
+            resize: vertical;
+        }
+
+        button {
+            margin: 10px 0 20px;
+            padding: 10px 30px;
+            background-color: #4285f4;
+            color: white;
+            border: none;
+            border-radius: 4px;
+            cursor: pointer;
+            font-size: 1rem;
+        }
+
+        .note {
+            padding: 10px 0;
+            border-bottom: 1px solid #eee;
+            white-space: pre-wrap;
+            word-wrap: break-word;
+        }
+
+        .note-meta {
+            color: #999;
+            font-size: 0.8rem;
+        }
+
+        .empty-message {
+            text-align: center;
+            color: #666;
+            margin: 20px 0;
+        }
+
+        .back-link {
+            display: inline-block;
+            margin-top: 20px;
+            color: #4285f4;
+            text-decoration: none;
+        }
+    </style>
+</head>
+<body>
+    <h1>Notes</h1>
+    <form method="post" action="{{.NotesPath}}">
+        <textarea name="message" rows="3" maxlength="{{.MaxLength}}" placeholder="Leave a short note..." required></textarea>
+        <button type="submit">Post</button>
+    </form>
+    {{range .Notes}}
+    <div class="note">{{.Text}}<div class="note-meta">{{clock .Time}} &middot; {{.From}}</div></div>
+    {{else}}
+    <div class="empty-message">No notes yet</div>
+    {{end}}
+    <a href="{{.UploadPage}}" class="back-link">← Back to Upload</a>
+    {{sessionFooter}}
+</body>
+</html>
+`))
+
+// notesHandler shows the board (GET) or adds a note (POST)
+func notesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		notesMu.Lock()
+		snapshot := append([]Note(nil), notes...)
+		notesMu.Unlock()
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		notesTemplate.Execute(w, struct {
+			Notes      []Note
+			MaxLength  int
+			UploadPage string
+			NotesPath  string
+		}{snapshot, maxNoteLength, routePath(uploadPagePath()), routePath("/notes")})
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, 4*maxNoteLength+1024)
+		text := strings.TrimSpace(r.FormValue("message"))
+		if text == "" {
+			http.Error(w, "Note must not be empty", http.StatusBadRequest)
+			return
+		}
+		if utf8.RuneCountInString(text) > maxNoteLength {
+			http.Error(w, "Note is too long", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		notesMu.Lock()
+		notes = append([]Note{{Text: text, Time: time.Now(), From: clientIP(r)}}, notes...)
+		if len(notes) > maxNotes {
+			notes = notes[:maxNotes]
+		}
+		notesMu.Unlock()
+
+		http.Redirect(w, r, routePath("/notes"), http.StatusSeeOther)
+	default:
+		http.Error(w, "Only GET and POST methods are supported", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import "net/http"
+
+// openAPISpec describes the HTTP API for client generators and API explorers (served on
+// /openapi.json with -api). It is static, so keep it in sync when a handler's parameters or
+// answers change. The relative server URL makes it work under -base-path as well.
+const openAPISpec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "pair",
+    "description": "Transfer files between devices on the local network. Errors are plain text.",
+    "version": "1"
+  },
+  "servers": [{"url": "."}],
+  "components": {
+    "securitySchemes": {
+      "uploadToken": {"type": "apiKey", "in": "header", "name": "X-Upload-Token", "description": "Only with -upload-token; also accepted as ?token="},
+      "listToken": {"type": "apiKey", "in": "header", "name": "X-List-Token", "description": "Only with -list-token; also accepted as ?list-token="},
+      "adminToken": {"type": "apiKey", "in": "header", "name": "X-Admin-Token", "description": "Only with -admin-token; also accepted as ?admin-token="}
+    },
+    "parameters": {
+      "inbox": {"name": "inbox", "in": "query", "description": "Sender name with -inbox (the upload goes into a folder of that name)", "schema": {"type": "string"}},
+      "path": {"name": "path", "in": "path", "required": true, "description": "Path of a shared file relative to the working directory, or its -x alias", "schema": {"type": "string"}},
+      "from": {"name": "from", "in": "query", "description": "Number of the first file to include (see /api/files)", "schema": {"type": "integer", "minimum": 1}},
+      "to": {"name": "to", "in": "query", "description": "Number of the last file to include", "schema": {"type": "integer", "minimum": 1}},
+      "go": {"name": "go", "in": "query", "description": "Set to 1 to skip the confirmation page shown above -zip-warn", "schema": {"type": "string", "enum": ["1"]}}
+    },
+    "schemas": {
+      "FileEntry": {
+        "type": "object",
+        "required": ["number", "name", "path", "size", "kind", "exists"],
+        "properties": {
+          "number": {"type": "integer", "description": "Position in the list, as used by from/to"},
+          "name": {"type": "string"},
+          "path": {"type": "string", "description": "Relative to the working directory"},
+          "size": {"type": "integer", "format": "int64"},
+          "kind": {"type": "string", "enum": ["image", "video", "audio", "archive", "document", "code", "file"]},
+          "mod_time": {"type": "string", "format": "date-time"},
+          "exists": {"type": "boolean"},
+          "download": {"type": "string", "description": "URL path of the file"}
+        }
+      },
+      "ChunkManifest": {
+        "type": "object",
+        "properties": {
+          "version": {"type": "integer"},
+          "path": {"type": "string"},
+          "download": {"type": "string", "description": "URL path to fetch chunks from with Range requests"},
+          "size": {"type": "integer", "format": "int64"},
+          "chunk_size": {"type": "integer", "format": "int64"},
+          "sha256": {"type": "string"},
+          "chunks": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "index": {"type": "integer"},
+                "offset": {"type": "integer", "format": "int64"},
+                "length": {"type": "integer", "format": "int64"},
+                "sha256": {"type": "string"}
+              }
+            }
+          }
+        }
+      }
+    },
+    "responses": {
+      "text": {"description": "Result message", "content": {"text/plain": {"schema": {"type": "string"}}}},
+      "error": {"description": "Error message", "content": {"text/plain": {"schema": {"type": "string"}}}},
+      "file": {"description": "File content", "content": {"application/octet-stream": {"schema": {"type": "string", "format": "binary"}}}}
+    }
+  },
+  "paths": {
+    "/upload": {
+      "post": {
+        "summary": "Upload one or more files",
+        "security": [{}, {"uploadToken": []}],
+        "parameters": [
+          {"name": "Idempotency-Key", "in": "header", "description": "Retries with the same key get the first answer instead of saving again", "schema": {"type": "string"}}
+        ],
+        "requestBody": {
+          "required": true,
+          "content": {
+            "multipart/form-data": {
+              "schema": {
+                "type": "object",
+                "required": ["files"],
+                "properties": {
+                  "files": {"type": "array", "items": {"type": "string", "format": "binary"}},
+                  "token": {"type": "string", "description": "Upload token, must come before the files"},
+                  "inbox": {"type": "string", "description": "Sender name with -inbox"}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"$ref": "#/components/responses/text"},
+          "400": {"$ref": "#/components/responses/error"},
+          "401": {"$ref": "#/components/responses/error"},
+          "403": {"$ref": "#/components/responses/error"},
+          "409": {"$ref": "#/components/responses/error"},
+          "413": {"$ref": "#/components/responses/error"},
+          "415": {"$ref": "#/components/responses/error"},
+          "507": {"$ref": "#/components/responses/error"}
+        }
+      }
+    },
+    "/put/{name}": {
+      "put": {
+        "summary": "Upload a single file as the raw request body",
+        "security": [{}, {"uploadToken": []}],
+        "parameters": [
+          {"name": "name", "in": "path", "required": true, "description": "File name without path separators", "schema": {"type": "string"}},
+          {"$ref": "#/components/parameters/inbox"}
+        ],
+        "requestBody": {"required": true, "content": {"application/octet-stream": {"schema": {"type": "string", "format": "binary"}}}},
+        "responses": {
+          "201": {"$ref": "#/components/responses/text"},
+          "400": {"$ref": "#/components/responses/error"},
+          "401": {"$ref": "#/components/responses/error"},
+          "403": {"$ref": "#/components/responses/error"},
+          "409": {"$ref": "#/components/responses/error"},
+          "413": {"$ref": "#/components/responses/error"},
+          "507": {"$ref": "#/components/responses/error"}
+        }
+      }
+    },
+    "/resume": {
+      "parameters": [
+        {"name": "name", "in": "query", "required": true, "description": "File name without path separators", "schema": {"type": "string"}},
+        {"$ref": "#/components/parameters/inbox"}
+      ],
+      "get": {
+        "summary": "Get the number of bytes already received for a resumable upload",
+        "security": [{}, {"uploadToken": []}],
+        "responses": {
+          "200": {
+            "description": "Current offset",
+            "headers": {"Upload-Offset": {"schema": {"type": "integer", "format": "int64"}}},
+            "content": {"text/plain": {"schema": {"type": "string"}}}
+          },
+          "401": {"$ref": "#/components/responses/error"}
+        }
+      },
+      "post": {
+        "summary": "Append the request body to a resumable upload",
+        "security": [{}, {"uploadToken": []}],
+        "parameters": [
+          {"name": "Upload-Offset", "in": "header", "required": true, "description": "Must equal the current offset", "schema": {"type": "integer", "format": "int64"}},
+          {"name": "Upload-Length", "in": "header", "required": true, "description": "Total size of the file", "schema": {"type": "integer", "format": "int64"}}
+        ],
+        "requestBody": {"required": true, "content": {"application/octet-stream": {"schema": {"type": "string", "format": "binary"}}}},
+        "responses": {
+          "200": {
+            "description": "New offset; the file is complete once it equals Upload-Length",
+            "headers": {"Upload-Offset": {"schema": {"type": "integer", "format": "int64"}}},
+            "content": {"text/plain": {"schema": {"type": "string"}}}
+          },
+          "400": {"$ref": "#/components/responses/error"},
+          "401": {"$ref": "#/components/responses/error"},
+          "409": {"description": "Offset mismatch, the current offset is in Upload-Offset", "headers": {"Upload-Offset": {"schema": {"type": "integer", "format": "int64"}}}},
+          "413": {"$ref": "#/components/responses/error"}
+        }
+      }
+    },
+    "/download/{path}": {
+      "get": {
+        "summary": "Download a shared file",
+        "parameters": [
+          {"$ref": "#/components/parameters/path"},
+          {"name": "name", "in": "query", "description": "File name to save as instead of the original one", "schema": {"type": "string"}},
+          {"name": "transform", "in": "query", "description": "Send the output of this -transform-cmd instead of the file (no Range support)", "schema": {"type": "string"}},
+          {"name": "Range", "in": "header", "description": "Byte range(s), answered with 206", "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/file"},
+          "206": {"$ref": "#/components/responses/file"},
+          "403": {"$ref": "#/components/responses/error"},
+          "404": {"$ref": "#/components/responses/error"},
+          "410": {"$ref": "#/components/responses/error"},
+          "416": {"$ref": "#/components/responses/error"},
+          "502": {"$ref": "#/components/responses/error"}
+        }
+      }
+    },
+    "/chunks/{path}": {
+      "get": {
+        "summary": "Get the SHA-256 of each 4 MiB chunk of a shared file, to re-fetch only damaged chunks",
+        "parameters": [{"$ref": "#/components/parameters/path"}],
+        "responses": {
+          "200": {"description": "Chunk manifest", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ChunkManifest"}}}},
+          "403": {"$ref": "#/components/responses/error"},
+          "404": {"$ref": "#/components/responses/error"},
+          "410": {"$ref": "#/components/responses/error"}
+        }
+      }
+    },
+    "/api/files": {
+      "get": {
+        "summary": "List the shared files, in download list order",
+        "security": [{}, {"listToken": []}],
+        "responses": {
+          "200": {"description": "Shared files", "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/FileEntry"}}}}},
+          "401": {"$ref": "#/components/responses/error"}
+        }
+      }
+    },
+    "/download-zip": {
+      "get": {
+        "summary": "Download all shared files (or the from/to range) as one ZIP archive",
+        "security": [{}, {"listToken": []}],
+        "parameters": [
+          {"$ref": "#/components/parameters/from"},
+          {"$ref": "#/components/parameters/to"},
+          {"$ref": "#/components/parameters/go"}
+        ],
+        "responses": {
+          "200": {"description": "ZIP archive, or an HTML confirmation page above -zip-warn", "content": {"application/zip": {"schema": {"type": "string", "format": "binary"}}}},
+          "400": {"$ref": "#/components/responses/error"},
+          "401": {"$ref": "#/components/responses/error"},
+          "404": {"$ref": "#/components/responses/error"},
+          "410": {"$ref": "#/components/responses/error"},
+          "501": {"$ref": "#/components/responses/error"}
+        }
+      }
+    },
+    "/download-tar": {
+      "get": {
+        "summary": "Download all shared files (or the from/to range) as one .tar.gz archive",
+        "security": [{}, {"listToken": []}],
+        "parameters": [
+          {"$ref": "#/components/parameters/from"},
+          {"$ref": "#/components/parameters/to"},
+          {"$ref": "#/components/parameters/go"}
+        ],
+        "responses": {
+          "200": {"description": "gzip-compressed tar archive, or an HTML confirmation page above -zip-warn", "content": {"application/gzip": {"schema": {"type": "string", "format": "binary"}}}},
+          "400": {"$ref": "#/components/responses/error"},
+          "401": {"$ref": "#/components/responses/error"},
+          "403": {"$ref": "#/components/responses/error"},
+          "404": {"$ref": "#/components/responses/error"},
+          "410": {"$ref": "#/components/responses/error"},
+          "501": {"$ref": "#/components/responses/error"}
+        }
+      }
+    },
+    "/admin/config": {
+      "get": {
+        "summary": "Get the effective configuration (secrets redacted)",
+        "description": "Needs the admin token if one is set, otherwise only answered to the computer pair runs on. Rate-limited per client; /api/config redirects here.",
+        "security": [{}, {"adminToken": []}],
+        "responses": {
+          "200": {"description": "Configuration", "content": {"application/json": {"schema": {"type": "object"}}}},
+          "401": {"$ref": "#/components/responses/error"},
+          "403": {"$ref": "#/components/responses/error"},
+          "429": {"$ref": "#/components/responses/error"}
+        }
+      }
+    }
+  }
+}
+`
+
+// openAPIHandler serves openAPISpec
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Only GET method is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*") // Online API explorers fetch it from their own origin
+	w.Write([]byte(openAPISpec))
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// errPipeTooLarge marks a piped upload that went over -max-file-size
+var errPipeTooLarge = errors.New("larger than the per-file limit")
+
+// shellCommand runs command through the platform shell, so -upload-cmd and -transform-cmd may
+// use quoting and pipes. Cancelling ctx kills the process.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// runUploadCommand starts a new -upload-cmd process for one file and copies src into its stdin.
+// The file name is passed in $PAIR_FILENAME; output goes to the terminal. It returns the bytes
+// copied and the command's exit code (-1 if it could not be started or was killed).
+func runUploadCommand(src io.Reader, fileName string, buf []byte) (int64, int, error) {
+	// A blocked content type (via -sniff-allow) never reaches the command
+	src, err := sniffUpload(src, fileName)
+	if err != nil {
+		return 0, -1, err
+	}
+
+	cmd := shellCommand(context.Background(), uploadCommand)
+	cmd.Dir = currentWorkDir
+	cmd.Env = append(os.Environ(), "PAIR_FILENAME="+fileName)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	startInProcessGroup(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return 0, -1, err
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, -1, err
+	}
+
+	if maxFileSize > 0 {
+		src = io.LimitReader(src, maxFileSize+1) // One byte more than allowed reveals an oversized file
+	}
+	written, copyErr := io.CopyBuffer(stdin, src, buf)
+	metricBytesUploaded.Add(written)
+	if copyErr == nil && maxFileSize > 0 && written > maxFileSize {
+		copyErr = errPipeTooLarge
+	}
+	if copyErr != nil && !errors.Is(copyErr, os.ErrClosed) && !isBrokenPipe(copyErr) {
+		// The command must not mistake a truncated upload for a complete one
+		killUploadCommand(cmd)
+		cmd.Wait()
+		return written, -1, copyErr
+	}
+	stdin.Close()
+
+	// A command that exits early (e.g. "head") breaks the pipe; its exit status is what counts
+	waitErr := cmd.Wait()
+	var exitErr *exec.ExitError
+	if waitErr != nil && !errors.As(waitErr, &exitErr) {
+		return written, -1, waitErr
+	}
+	return written, cmd.ProcessState.ExitCode(), nil
+}
+
+// isBrokenPipe reports whether a write failed because the command closed its stdin
+func isBrokenPipe(err error) bool {
+	return strings.Contains(err.Error(), "broken pipe") || strings.Contains(err.Error(), "pipe is being closed")
+}
+
+// pipeUploads handles a multipart upload under -upload-cmd: every "files" part is streamed
+// straight from the request into its own command, without temp files or a copy on disk.
+// A "token" form field must therefore come before the files.
+func pipeUploads(w http.ResponseWriter, r *http.Request) {
+	watchUploadIdle(w, r)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to parse form: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	authorized := uploadAuthorized(r)
+	var results []string
+	succeeded := 0
+	buf := make([]byte, transferBufSize)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if isTimeout(err) {
+				http.Error(w, fmt.Sprintf("Upload aborted: no data received for %s", uploadIdleTimeout), http.StatusRequestTimeout)
+				return
+			}
+			http.Error(w, fmt.Sprintf("Failed to parse form: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		switch {
+		case part.FormName() == "token" && part.FileName() == "":
+			value, _ := io.ReadAll(io.LimitReader(part, 1024))
+			authorized = authorized || validUploadToken(string(value))
+		case part.FormName() == "files" && part.FileName() != "":
+			if !authorized {
+				http.Error(w, "Upload token required", http.StatusUnauthorized)
+				return
+			}
+			// Files are streamed as they arrive, so with -single-upload a second one can only
+			// be refused once the first has already gone into the command
+			if singleUpload && len(results) > 0 {
+				http.Error(w, fmt.Sprintf("Only one file per upload is accepted, only the first was piped: %s", results[0]), http.StatusBadRequest)
+				return
+			}
+			fileName, err := sanitizeFileName(part.FileName())
+			if err != nil {
+				results = append(results, fmt.Sprintf("%s (invalid name: %v)", part.FileName(), err))
+				break
+			}
+			written, exitCode, err := runUploadCommand(part, fileName, buf)
+			var sniffErr *sniffError
+			switch {
+			case errors.As(err, &sniffErr):
+				results = append(results, fmt.Sprintf("%s (rejected, %s)", fileName, sniffErr.contentType))
+			case errors.Is(err, errPipeTooLarge):
+				results = append(results, fmt.Sprintf("%s (rejected, larger than %s)", fileName, formatFileSize(maxFileSize)))
+			case isTimeout(err):
+				http.Error(w, fmt.Sprintf("Upload aborted: no data received for %s", uploadIdleTimeout), http.StatusRequestTimeout)
+				return
+			case err != nil:
+				fmt.Printf("Upload command failed for %s: %v\n", fileName, err)
+				results = append(results, fmt.Sprintf("%s (failed: %v)", fileName, err))
+			default:
+				results = append(results, fmt.Sprintf("%s (exit %d)", fileName, exitCode))
+				if exitCode == 0 {
+					succeeded++
+					metricUploads.Add(1)
+					recordTransfer(r, "upload", fileName, written)
+				}
+			}
+		}
+		part.Close()
+	}
+
+	if len(results) == 0 {
+		http.Error(w, "No files were uploaded", http.StatusBadRequest)
+		return
+	}
+	msg := fmt.Sprintf("Piped %d of %d files into %q: %s", succeeded, len(results), uploadCommand, strings.Join(results, ", "))
+	if succeeded == 0 {
+		http.Error(w, msg, http.StatusBadGateway)
+		return
+	}
+	writeUploadSuccess(w, r, msg)
+}
+
+// pipePut streams a PUT /put/ body into -upload-cmd instead of saving it
+func pipePut(w http.ResponseWriter, r *http.Request, fileName string) {
+	watchUploadIdle(w, r)
+	written, exitCode, err := runUploadCommand(r.Body, fileName, make([]byte, transferBufSize))
+	var sniffErr *sniffError
+	switch {
+	case errors.As(err, &sniffErr):
+		http.Error(w, sniffErr.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, errPipeTooLarge):
+		http.Error(w, fmt.Sprintf("File %s is larger than the %s limit", fileName, formatFileSize(maxFileSize)), http.StatusRequestEntityTooLarge)
+	case isTimeout(err):
+		http.Error(w, fmt.Sprintf("Upload aborted: no data received for %s", uploadIdleTimeout), http.StatusRequestTimeout)
+	case err != nil:
+		serverError(w, fmt.Sprintf("Upload command failed for %s", fileName), err)
+	case exitCode != 0:
+		http.Error(w, fmt.Sprintf("Piped %s (%d bytes) into %q: exit %d", fileName, written, uploadCommand, exitCode), http.StatusBadGateway)
+	default:
+		metricUploads.Add(1)
+		recordTransfer(r, "upload", fileName, written)
+		fmt.Fprintf(w, "Piped %s (%d bytes) into %q: exit 0\n", fileName, written, uploadCommand)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// startInProcessGroup puts the command into its own process group, so killUploadCommand
+// also reaches the processes the shell spawned (e.g. tar in "gunzip | tar xf -")
+func startInProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killUploadCommand kills the whole process group of a started command
+func killUploadCommand(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package main
+
+import "os/exec"
+
+// startInProcessGroup is a no-op on Windows, where cmd /C children are not grouped
+func startInProcessGroup(cmd *exec.Cmd) {}
+
+// killUploadCommand kills the shell; processes it spawned may keep running
+func killUploadCommand(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// acceptsGzip reports whether the Accept-Encoding header allows gzip (and does not refuse it with q=0)
+func acceptsGzip(r *http.Request) bool {
+	for _, entry := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(entry), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, err := strconv.ParseFloat(value, 64)
+			return err == nil && q > 0
+		}
+		return true
+	}
+	return false
+}
+
+// openPrecompressed opens the sibling FILE.gz of an allowed file for -precompressed, or
+// returns nil if it should not be used: the client does not accept gzip, the request is a
+// Range request (ranges and chunk hashes refer to the plain file), or the variant is
+// missing, not a regular file, older than the plain file or resolves outside the working
+// directory. The returned path is the resolved variant.
+func openPrecompressed(r *http.Request, cleanTargetPath string, plainInfo os.FileInfo) (*os.File, os.FileInfo, string) {
+	if !precompressed || r.Header.Get("Range") != "" || !acceptsGzip(r) {
+		return nil, nil, ""
+	}
+	variantPath, err := resolveServedPath(cleanTargetPath + ".gz")
+	if err != nil {
+		return nil, nil, ""
+	}
+	file, err := os.Open(variantPath)
+	if err != nil {
+		return nil, nil, ""
+	}
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() || info.ModTime().Before(plainInfo.ModTime()) {
+		file.Close()
+		return nil, nil, ""
+	}
+	return file, info, variantPath
+}
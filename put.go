@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// putHandler saves the raw request body of PUT /put/FILE (e.g. curl -T file URL/put/file)
+// without any multipart parsing. An existing name is skipped if the content is identical and
+// otherwise handled per -on-conflict.
+func putHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Only PUT method is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if uploadsDisabled(w) {
+		return
+	}
+	if !uploadAuthorized(r) {
+		http.Error(w, "Upload token required", http.StatusUnauthorized)
+		return
+	}
+
+	decodedName, err := url.PathUnescape(strings.TrimPrefix(r.URL.Path, "/put/"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to decode file name: %v", err), http.StatusBadRequest)
+		return
+	}
+	fileName, err := sanitizeFileName(decodedName)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid file name: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if maxFileSize > 0 && r.ContentLength > maxFileSize {
+		http.Error(w, fmt.Sprintf("File %s is larger than the %s limit", fileName, formatFileSize(maxFileSize)), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if uploadCommand != "" {
+		pipePut(w, r, fileName)
+		return
+	}
+
+	// Reserve the body against -max-total; a body of unknown length is counted once received
+	reserved := max(r.ContentLength, 0)
+	if !reserveUploadSpace(reserved) {
+		uploadSpaceExceeded(w)
+		return
+	}
+	kept := false
+	defer func() {
+		if !kept {
+			releaseUploadSpace(reserved)
+		}
+	}()
+
+	// A blocked content type (via -sniff-allow) is rejected before the file is created
+	watchUploadIdle(w, r)
+	body, err := sniffUpload(r.Body, fileName)
+	if err != nil {
+		uploadSniffFailed(w, err)
+		return
+	}
+
+	saveDir, err := uploadDir(r.URL.Query().Get(inboxField))
+	if err != nil {
+		serverError(w, "Failed to create the upload folder", err)
+		return
+	}
+	target, dstFile, err := createUploadTarget(filepath.Join(saveDir, fileName), r.ContentLength)
+	if errors.Is(err, errUploadConflict) {
+		http.Error(w, fmt.Sprintf("File %s %v", fileName, err), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		serverError(w, fmt.Sprintf("Failed to create file %s", fileName), err)
+		return
+	}
+
+	if maxFileSize > 0 {
+		body = io.LimitReader(body, maxFileSize+1) // One byte more than allowed reveals an oversized body
+	}
+	if remaining := remainingUploadSpace(); r.ContentLength < 0 && remaining >= 0 {
+		body = io.LimitReader(body, remaining+1) // Stop reading once the body can't fit anyway
+	}
+	written, err := io.CopyBuffer(target.writer(dstFile), body, make([]byte, transferBufSize))
+	metricBytesUploaded.Add(written)
+	if err == nil && r.ContentLength < 0 {
+		if !reserveUploadSpace(written) {
+			removePartial(dstFile, target.writePath)
+			uploadSpaceExceeded(w)
+			return
+		}
+		reserved = written
+	}
+	if err == nil && maxFileSize > 0 && written > maxFileSize {
+		removePartial(dstFile, target.writePath)
+		http.Error(w, fmt.Sprintf("File %s is larger than the %s limit", fileName, formatFileSize(maxFileSize)), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		removePartial(dstFile, target.writePath)
+		if isTimeout(err) {
+			http.Error(w, fmt.Sprintf("Upload aborted: no data received for %s", uploadIdleTimeout), http.StatusRequestTimeout)
+			return
+		}
+		serverError(w, fmt.Sprintf("Failed to write file %s", fileName), err)
+		return
+	}
+	if err := closeSaved(dstFile); err != nil {
+		os.Remove(target.writePath)
+		serverError(w, fmt.Sprintf("Failed to write file %s", fileName), err)
+		return
+	}
+	savePath, err := target.finish()
+	if errors.Is(err, errUploadConflict) {
+		http.Error(w, fmt.Sprintf("File %s %v", fileName, err), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		serverError(w, fmt.Sprintf("Failed to save file %s", fileName), err)
+		return
+	}
+	if savePath == "" {
+		fmt.Fprintf(w, "%s is already present with identical content, skipped\n", fileName)
+		return
+	}
+	saved := finishUpload(r, savePath, written)
+	if saved.duplicateOf != "" {
+		fmt.Fprintln(w, saved.message(saveDir, written))
+		return
+	}
+	kept = true
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintln(w, saved.message(saveDir, written))
+}
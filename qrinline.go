@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"github.com/mdp/qrterminal/v3"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"rsc.io/qr"
+	"unicode/utf8"
+)
+
+// Inline QR codes (via -qr-inline): a -f file small enough to fit is put into the QR code
+// itself instead of its download URL, so a phone can read it without reaching this computer.
+// Text goes in as is (scanner apps show it, ready to copy); anything else as a data: URI.
+// Larger files keep the URL. The content is read once at startup, so a reprinted code shows
+// the file as it was then.
+
+var (
+	qrInlineContent string // What the share QR code holds instead of the URL, "" if the URL is used
+	qrInlineMode    string // "text" or "data: URI", for the title and the banner
+)
+
+// prepareInlineQR decides at startup whether the -f file at path goes into the QR code and
+// prints which mode was chosen
+func prepareInlineQR(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Warning: -qr-inline: %v, the QR code holds the download URL", err)
+		return
+	}
+	if len(data) == 0 {
+		fmt.Println("- QR code mode: download URL (the file is empty)")
+		return
+	}
+
+	content, mode := string(data), "text"
+	if !isPlainText(data) {
+		content, mode = dataURI(filepath.Base(path), data), "data: URI"
+	}
+	if _, err := qr.Encode(content, qrConfig().Level); err != nil {
+		fmt.Printf("- QR code mode: download URL (%s is %s, too much for a QR code)\n", filepath.Base(path), formatFileSize(int64(len(data))))
+		return
+	}
+	qrInlineContent, qrInlineMode = content, mode
+	fmt.Printf("- QR code mode: the file itself (%s, %d characters), no network needed to read it\n", mode, len(content))
+}
+
+// isPlainText reports whether data is UTF-8 text without control characters other than
+// tabs and line breaks
+func isPlainText(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	return !bytes.ContainsFunc(data, func(r rune) bool {
+		return r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0x7f
+	})
+}
+
+// dataURI encodes data as a base64 data: URI with its media type
+func dataURI(name string, data []byte) string {
+	mediaType := mime.TypeByExtension(filepath.Ext(name))
+	if mediaType == "" {
+		mediaType = http.DetectContentType(data)
+	}
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// qrTarget returns what the share QR code encodes: the inline content, or shareURL
+func qrTarget(shareURL string) string {
+	if qrInlineContent != "" {
+		return qrInlineContent
+	}
+	return shareURL
+}
+
+// printInlineQR prints the share QR code with the file inside; the URL below it still works
+// for phones that can reach this computer
+func printInlineQR(name, shareURL string, config qrterminal.Config) {
+	fmt.Printf("\n%s%s\n", glyph("📱️", "> "), qrPrompt(fmt.Sprintf("Scan below qrcode to read %s, it is inside the code (%s, works offline).", name, qrInlineMode)))
+	qrterminal.GenerateWithConfig(qrInlineContent, config)
+	fmt.Printf("Not a link: this QR code holds the file itself. Download URL: %s\n", shareURL)
+}
+
+// warnDenseInlineQR warns when the inline content will produce a hard to scan QR code
+func warnDenseInlineQR(level qr.Level) {
+	limit, ok := qrComfortableLengths[level]
+	if !ok || len(qrInlineContent) <= limit {
+		return
+	}
+	log.Printf("Warning: the QR code holds %d characters (over %d), it may be too dense to scan; "+
+		"start pair without -qr-inline to put the short download URL in it instead", len(qrInlineContent), limit)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"rsc.io/qr"
+)
+
+const qrSVGQuietZone = 4 // Modules of white border the QR spec asks for (the terminal QR uses less)
+
+// qrSVG renders text as a QR code in SVG markup, one user unit per module. Black modules are
+// merged into horizontal runs of a single path, so the file stays small and scales crisply.
+func qrSVG(text string) ([]byte, error) {
+	code, err := qr.Encode(text, qr.M)
+	if err != nil {
+		return nil, err
+	}
+
+	side := code.Size + 2*qrSVGQuietZone
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" shape-rendering="crispEdges">`+"\n", side, side, side*8, side*8)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/>`+"\n", side, side)
+	buf.WriteString(`<path fill="#000" d="`)
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if !code.Black(x, y) {
+				continue
+			}
+			run := 1
+			for code.Black(x+run, y) {
+				run++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", x+qrSVGQuietZone, y+qrSVGQuietZone, run, run)
+			x += run - 1
+		}
+	}
+	buf.WriteString(`"/>` + "\n</svg>\n")
+	return buf.Bytes(), nil
+}
+
+// writeQRSVG writes the SVG QR code for target to path, or to w for "-"
+func writeQRSVG(path, target string, w io.Writer) error {
+	svg, err := qrSVG(target)
+	if err != nil {
+		return err
+	}
+	if path == "-" {
+		_, err = w.Write(svg)
+		return err
+	}
+	return os.WriteFile(path, svg, 0o644)
+}
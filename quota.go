@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// Session-wide upload cap (via -max-total): the bytes saved by all uploads since pair started
+// may not exceed maxTotalUpload. Uploads reserve their size before writing and give back what
+// they did not keep, so concurrent uploads can never go over the cap together.
+var uploadedTotal atomic.Int64 // Bytes saved or reserved by uploads in this session
+
+// reserveUploadSpace adds size to the session total and returns true, or returns false if that
+// would exceed -max-total (always true without it)
+func reserveUploadSpace(size int64) bool {
+	if maxTotalUpload <= 0 {
+		return true
+	}
+	for {
+		current := uploadedTotal.Load()
+		if current+size > maxTotalUpload {
+			return false
+		}
+		if uploadedTotal.CompareAndSwap(current, current+size) {
+			return true
+		}
+	}
+}
+
+// releaseUploadSpace gives back reserved bytes that were not saved after all
+func releaseUploadSpace(size int64) {
+	if maxTotalUpload > 0 && size > 0 {
+		uploadedTotal.Add(-size)
+	}
+}
+
+// remainingUploadSpace returns how many more bytes may be uploaded in this session (-1 = no cap)
+func remainingUploadSpace() int64 {
+	if maxTotalUpload <= 0 {
+		return -1
+	}
+	return max(maxTotalUpload-uploadedTotal.Load(), 0)
+}
+
+// uploadSpaceExceeded answers 507 Insufficient Storage for an upload that would exceed -max-total
+func uploadSpaceExceeded(w http.ResponseWriter) {
+	http.Error(w, errUploadSpace().Error(), http.StatusInsufficientStorage)
+}
+
+// errUploadSpace describes an upload refused by -max-total, with the space that is left
+func errUploadSpace() error {
+	return fmt.Errorf("Upload rejected: it would exceed the %s total upload limit of this session (%s left)",
+		formatFileSize(maxTotalUpload), formatFileSize(remainingUploadSpace()))
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestConcurrentRequests sends uploads, downloads and page requests from many clients at once.
+// It finds nothing on its own; run it with go test -race, which reports any state that
+// handlers share without the lock or atomic it needs. Requests are passed to the mux
+// directly: over a real connection net/http's pooled buffers order the handlers for the
+// race detector and hide most races. Only /ws, which needs the connection, goes over one.
+func TestConcurrentRequests(t *testing.T) {
+	dir := newTestShare(t)
+	sharedDir = "."
+	conflictMode = "rename"
+	notesEnabled, metricsEnabled, manageEnabled, dedupeEnabled = true, true, true, true
+	historyEnabled, historyPath = true, filepath.Join(t.TempDir(), historyFileName)
+	maxDownloads, diskRate = 1<<20, 1<<30 // Never reached, but counted and throttled
+	t.Cleanup(func() {
+		sharedDir, conflictMode = "", "error"
+		notesEnabled, metricsEnabled, manageEnabled, dedupeEnabled, historyEnabled = false, false, false, false, false
+		maxDownloads, diskRate = 0, 0
+	})
+	if err := loadHistory(historyPath); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "shared.txt", bytes.Repeat([]byte("pair "), 10000))
+	mux := newTestMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// Every request of a round runs in its own goroutine, so any two of them may overlap
+	const clients, rounds = 8, 3
+	for round := range rounds {
+		var wg sync.WaitGroup
+		for client := range clients {
+			name := fmt.Sprintf("file-%d-%d.txt", client, round)
+			content := fmt.Sprintf("upload %d of client %d", round, client) // Prefixed per route, -dedupe would remove copies
+			upload := multipartUpload("same.txt", "form "+content)
+			upload.Header.Set(idempotencyHeader, fmt.Sprint("key-", client%2)) // Shared by the clients, so some are replays
+			for _, request := range []*http.Request{
+				upload,
+				newRequest(http.MethodPut, "/put/"+name, "put "+content, nil),
+				newRequest(http.MethodPost, "/resume?name=resumed-"+name, "resume "+content, map[string]string{
+					uploadOffsetHd: "0",
+					uploadLengthHd: fmt.Sprint(len("resume " + content)),
+				}),
+				newRequest(http.MethodPost, "/notes", url.Values{"message": {content}}.Encode(), map[string]string{
+					"Content-Type": "application/x-www-form-urlencoded",
+				}),
+				newRequest(http.MethodGet, "/download/shared.txt", "", nil),
+				newRequest(http.MethodGet, "/download/shared.txt", "", map[string]string{"Range": "bytes=100-199"}),
+				newRequest(http.MethodGet, "/chunks/shared.txt", "", nil),
+				newRequest(http.MethodGet, "/downloads", "", nil),
+				newRequest(http.MethodGet, "/api/files", "", nil),
+				newRequest(http.MethodGet, "/download-zip", "", nil),
+				newRequest(http.MethodGet, "/code?c=000000", "", nil),
+				newRequest(http.MethodGet, "/notes", "", nil),
+				newRequest(http.MethodGet, "/history", "", nil),
+				newRequest(http.MethodGet, "/metrics", "", nil),
+				newRequest(http.MethodGet, adminUploadsPath, "", nil),
+				newRequest(http.MethodGet, adminConfigPath, "", nil),
+			} {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					response := httptest.NewRecorder()
+					mux.ServeHTTP(response, request)
+					if response.Code >= 500 {
+						t.Errorf("%s %s: %d %s", request.Method, request.URL.Path, response.Code, response.Body)
+					}
+				}()
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := wsUpload(server.URL, "ws-"+name, "ws "+content); err != nil {
+					t.Errorf("/ws: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	for client := range clients {
+		for round := range rounds {
+			name := fmt.Sprintf("file-%d-%d.txt", client, round)
+			for _, saved := range []string{name, "resumed-" + name, "ws-" + name} {
+				if _, err := os.Stat(filepath.Join(dir, saved)); err != nil {
+					t.Errorf("%s was not saved: %v", saved, err)
+				}
+			}
+		}
+	}
+}
+
+// newRequest builds a request from a local client with body and headers
+func newRequest(method, target, body string, headers map[string]string) *http.Request {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	request.RemoteAddr = "127.0.0.1:50000" // The /admin/ endpoints are open to this computer
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	return request
+}
+
+// multipartUpload builds an upload form post of one file
+func multipartUpload(name, content string) *http.Request {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("files", name)
+	io.WriteString(part, content)
+	form.Close()
+	return newRequest(http.MethodPost, "/upload", body.String(), map[string]string{"Content-Type": form.FormDataContentType()})
+}
+
+// wsUpload sends one file over /ws and waits for the server's answer
+func wsUpload(serverURL, name, content string) error {
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(serverURL, "http")+"/ws", nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.WriteJSON(wsHeader{Name: name, Size: int64(len(content))}); err != nil {
+		return err
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, []byte(content)); err != nil {
+		return err
+	}
+	if err := conn.WriteJSON(wsHeader{Done: true}); err != nil {
+		return err
+	}
+	for {
+		var reply wsReply
+		if err := conn.ReadJSON(&reply); err != nil {
+			return err
+		}
+		if reply.Error != "" {
+			return errors.New(reply.Error)
+		}
+		if reply.Message != "" {
+			return nil // The answer for the file, the other replies acknowledge data
+		}
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// reprintOnSignal calls reprint whenever pair receives SIGUSR1 (kill -USR1 <pid>), e.g. to show
+// the QR code again after it scrolled off the terminal. It reports whether that is supported.
+func reprintOnSignal(reprint func()) bool {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	go func() {
+		for range signals {
+			reprint()
+		}
+	}()
+	return true
+}
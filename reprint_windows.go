@@ -0,0 +1,8 @@
+//go:build windows
+
+package main
+
+// reprintOnSignal is a no-op on Windows, which has no SIGUSR1
+func reprintOnSignal(reprint func()) bool {
+	return false
+}
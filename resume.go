@@ -0,0 +1,300 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resumable upload protocol (single raw file per request):
+//
+//	GET  /resume?name=FILE  -> current offset of FILE.pair-part (Upload-Offset header + body)
+//	POST /resume?name=FILE  -> append request body to FILE.pair-part
+//	     Upload-Offset: N   (required, must equal the current .pair-part size)
+//	     Upload-Length: T   (required, total size of the file)
+//
+// When the .pair-part file reaches Upload-Length bytes it is renamed to FILE, or settled by
+// -on-conflict if FILE exists (see conflict.go). The suffix is pair's own, so the startup
+// cleanup never touches partial files of browsers or other tools.
+const (
+	partSuffix     = ".pair-part"   // Suffix of in-progress resumable uploads and conflict temp files
+	stalePartAge   = 24 * time.Hour // .pair-part files older than this are removed at startup
+	uploadOffsetHd = "Upload-Offset"
+	uploadLengthHd = "Upload-Length"
+)
+
+// activeResumes tracks .pair-part files currently being appended to (prevents concurrent writers)
+var (
+	activeResumes   = make(map[string]bool)
+	activeResumesMu sync.Mutex
+)
+
+// sanitizeFileName validates a client-supplied file name and returns its base name
+func sanitizeFileName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("empty file name")
+	}
+	if strings.ContainsAny(name, `/\`) || strings.ContainsRune(name, 0) {
+		return "", fmt.Errorf("file name %q must not contain path separators", name)
+	}
+	if name == "." || name == ".." {
+		return "", fmt.Errorf("invalid file name %q", name)
+	}
+	return name, nil
+}
+
+// resumeHandler reports (GET) or extends (POST) a resumable upload
+func resumeHandler(w http.ResponseWriter, r *http.Request) {
+	if uploadsDisabled(w) {
+		return
+	}
+	if !uploadAuthorized(r) {
+		http.Error(w, "Upload token required", http.StatusUnauthorized)
+		return
+	}
+	// Chunks arrive in separate requests, possibly after a restart, so they can't feed one command
+	if uploadCommand != "" {
+		http.Error(w, "Resumable uploads are not available with -upload-cmd, use /upload or /put/", http.StatusNotImplemented)
+		return
+	}
+
+	fileName, err := sanitizeFileName(r.URL.Query().Get("name"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid file name: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	saveDir, err := uploadDir(r.URL.Query().Get(inboxField))
+	if err != nil {
+		serverError(w, "Failed to create the upload folder", err)
+		return
+	}
+	savePath := filepath.Join(saveDir, fileName)
+	partPath := savePath + partSuffix
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		offset, err := partSize(partPath)
+		if err != nil {
+			serverError(w, "Failed to stat partial file", err)
+			return
+		}
+		w.Header().Set(uploadOffsetHd, strconv.FormatInt(offset, 10))
+		fmt.Fprintf(w, "%d", offset)
+	case http.MethodPost:
+		watchUploadIdle(w, r)
+		appendResume(w, r, fileName, savePath, partPath)
+	default:
+		http.Error(w, "Only GET and POST methods are supported", http.StatusMethodNotAllowed)
+	}
+}
+
+// appendResume appends the request body to the .pair-part file and finalizes it when complete
+func appendResume(w http.ResponseWriter, r *http.Request, fileName, savePath, partPath string) {
+	offset, err := strconv.ParseInt(r.Header.Get(uploadOffsetHd), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, fmt.Sprintf("Missing or invalid %s header", uploadOffsetHd), http.StatusBadRequest)
+		return
+	}
+	total, err := strconv.ParseInt(r.Header.Get(uploadLengthHd), 10, 64)
+	if err != nil || total < offset {
+		http.Error(w, fmt.Sprintf("Missing or invalid %s header", uploadLengthHd), http.StatusBadRequest)
+		return
+	}
+	if maxFileSize > 0 && total > maxFileSize {
+		http.Error(w, fmt.Sprintf("File %s is larger than the %s limit", fileName, formatFileSize(maxFileSize)), http.StatusRequestEntityTooLarge)
+		return
+	}
+	// A taken name is settled when the file is complete; with -on-conflict error a different
+	// size can be refused before any data is received (same rule as createUploadTarget)
+	if existing, err := os.Stat(savePath); err == nil && conflictMode == "error" && (!existing.Mode().IsRegular() || existing.Size() != total) {
+		http.Error(w, fmt.Sprintf("File %s %v", fileName, errUploadConflict), http.StatusConflict)
+		return
+	}
+
+	// Allow only one writer per partial file
+	activeResumesMu.Lock()
+	if activeResumes[partPath] {
+		activeResumesMu.Unlock()
+		http.Error(w, fmt.Sprintf("Upload of %s is already in progress", fileName), http.StatusConflict)
+		return
+	}
+	activeResumes[partPath] = true
+	activeResumesMu.Unlock()
+	defer func() {
+		activeResumesMu.Lock()
+		delete(activeResumes, partPath)
+		activeResumesMu.Unlock()
+	}()
+
+	// The client must continue exactly where the server stopped
+	current, err := partSize(partPath)
+	if err != nil {
+		serverError(w, "Failed to stat partial file", err)
+		return
+	}
+	if current != offset {
+		w.Header().Set(uploadOffsetHd, strconv.FormatInt(current, 10))
+		http.Error(w, fmt.Sprintf("Offset mismatch: server has %d bytes of %s", current, fileName), http.StatusConflict)
+		return
+	}
+
+	// The first chunk starts the file, so its content type is checked (via -sniff-allow)
+	body := io.Reader(r.Body)
+	if offset == 0 {
+		if body, err = sniffUpload(r.Body, fileName); err != nil {
+			uploadSniffFailed(w, err)
+			return
+		}
+	}
+
+	// Reserve the rest of the file against -max-total, keeping only what this chunk wrote
+	if !reserveUploadSpace(total - offset) {
+		uploadSpaceExceeded(w)
+		return
+	}
+
+	partFile, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, uploadFileMode)
+	if err != nil {
+		releaseUploadSpace(total - offset)
+		serverError(w, fmt.Sprintf("Failed to open partial file %s", fileName), err)
+		return
+	}
+
+	// Never write past the announced total size
+	written, copyErr := io.CopyBuffer(throttleDisk(partFile), io.LimitReader(body, total-offset), make([]byte, transferBufSize))
+	closeErr := closeSaved(partFile) // Also with -fsync: the offset reported next must be on disk
+	current = offset + written
+	releaseUploadSpace(total - offset - written)
+	metricBytesUploaded.Add(written)
+	w.Header().Set(uploadOffsetHd, strconv.FormatInt(current, 10))
+
+	if copyErr != nil {
+		// Keep what was received so the client can resume from the new offset
+		serverError(w, fmt.Sprintf("Upload of %s interrupted at %d bytes", fileName, current), copyErr)
+		return
+	}
+	if closeErr != nil {
+		serverError(w, fmt.Sprintf("Failed to write partial file %s", fileName), closeErr)
+		return
+	}
+
+	if current < total {
+		fmt.Fprintf(w, "Received %d of %d bytes of %s", current, total, fileName)
+		return
+	}
+
+	// Upload complete: move the partial file into place
+	savePath, err = finalizeResume(partPath, savePath, total)
+	if errors.Is(err, errUploadConflict) {
+		releaseUploadSpace(total)
+		http.Error(w, fmt.Sprintf("File %s %v", fileName, err), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		serverError(w, fmt.Sprintf("Failed to finalize file %s", fileName), err)
+		return
+	}
+	if savePath == "" {
+		releaseUploadSpace(total)
+		fmt.Fprintf(w, "%s is already present with identical content, skipped", fileName)
+		return
+	}
+	saved := finishUpload(r, savePath, total)
+	if saved.duplicateOf != "" {
+		releaseUploadSpace(total)
+	}
+	fmt.Fprint(w, saved.message(filepath.Dir(savePath), total))
+}
+
+// finalizeResume moves a complete .pair-part file to savePath and returns the path it was saved
+// as, or "" if it was identical to the file already there. A free name is taken by renaming; a
+// taken one is settled by -on-conflict like any other upload, which needs a copy of the data.
+func finalizeResume(partPath, savePath string, total int64) (string, error) {
+	target, file, err := createUploadTarget(savePath, total)
+	if err != nil {
+		return "", err
+	}
+	if target.existing == nil {
+		// The empty file holds the name until the part file replaces it
+		file.Close()
+		if err := os.Rename(partPath, savePath); err != nil {
+			os.Remove(savePath)
+			return "", err
+		}
+		return savePath, nil
+	}
+
+	part, err := os.Open(partPath)
+	if err != nil {
+		removePartial(file, target.writePath)
+		return "", err
+	}
+	_, err = io.CopyBuffer(target.writer(file), part, make([]byte, transferBufSize))
+	part.Close()
+	if err != nil {
+		removePartial(file, target.writePath)
+		return "", err
+	}
+	if err := closeSaved(file); err != nil {
+		os.Remove(target.writePath)
+		return "", err
+	}
+	os.Remove(partPath)
+	return target.finish()
+}
+
+// partSize returns the size of a partial upload, or 0 if it does not exist yet
+func partSize(partPath string) (int64, error) {
+	stat, err := os.Stat(partPath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return stat.Size(), nil
+}
+
+// cleanupStaleParts removes abandoned resumable uploads from the save directory, and with
+// -inbox from the sender folders in it, where /resume?inbox= keeps its .pair-part files
+func cleanupStaleParts(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("Warning: failed to scan %s for stale partial uploads: %v", dir, err)
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() && inboxMode && dir == currentWorkDir {
+			cleanupStaleParts(filepath.Join(dir, entry.Name()))
+			continue
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), partSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < stalePartAge {
+			continue
+		}
+		partPath := filepath.Join(dir, entry.Name())
+		if err := os.Remove(partPath); err != nil {
+			log.Printf("Warning: failed to remove stale partial upload %s: %v", partPath, err)
+			continue
+		}
+		name := entry.Name()
+		if dir != currentWorkDir {
+			name = filepath.Join(filepath.Base(dir), name)
+		}
+		fmt.Printf("- Removed stale partial upload: %s\n", name)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResumeExistingName(t *testing.T) {
+	t.Cleanup(func() { conflictMode = "error" })
+	for _, test := range []struct {
+		name, mode, upload string
+		status             int
+		body               string
+		files              map[string]string // Content of the upload directory afterwards
+	}{
+		{"identical content", "error", "old content", http.StatusOK, "already present", map[string]string{"a.txt": "old content"}},
+		{"different content", "error", "new content", http.StatusConflict, "already exists", map[string]string{"a.txt": "old content"}},
+		{"different size", "error", "longer content", http.StatusConflict, "already exists", map[string]string{"a.txt": "old content"}},
+		{"rename", "rename", "new content", http.StatusOK, "Saved a (1).txt", map[string]string{"a.txt": "old content", "a (1).txt": "new content"}},
+		{"overwrite", "overwrite", "new content", http.StatusOK, "Saved a.txt", map[string]string{"a.txt": "new content"}},
+	} {
+		dir := newTestShare(t)
+		conflictMode = test.mode
+		writeTestFile(t, dir, "a.txt", []byte("old content"))
+		mux := newTestMux()
+
+		// Two chunks, so the data goes through the .pair-part file first
+		half := len(test.upload) / 2
+		var response *httptest.ResponseRecorder
+		for _, offset := range []int{0, half} {
+			end := len(test.upload)
+			if offset == 0 {
+				end = half
+			}
+			response = httptest.NewRecorder()
+			mux.ServeHTTP(response, newRequest(http.MethodPost, "/resume?name=a.txt", test.upload[offset:end], map[string]string{
+				uploadOffsetHd: fmt.Sprint(offset),
+				uploadLengthHd: fmt.Sprint(len(test.upload)),
+			}))
+			if response.Code != http.StatusOK {
+				break
+			}
+		}
+		if response.Code != test.status || !strings.Contains(response.Body.String(), test.body) {
+			t.Errorf("%s: %d %q, want %d with %q", test.name, response.Code, response.Body, test.status, test.body)
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != len(test.files) {
+			t.Errorf("%s: %d files left, want %d", test.name, len(entries), len(test.files))
+		}
+		for name, want := range test.files {
+			if got, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(got) != want {
+				t.Errorf("%s: %s holds %q (%v), want %q", test.name, name, got, err, want)
+			}
+		}
+	}
+}
+
+func TestCleanupStaleParts(t *testing.T) {
+	dir := newTestShare(t)
+	inboxMode = true
+	t.Cleanup(func() { inboxMode = false })
+	old := time.Now().Add(-stalePartAge - time.Hour)
+	for _, name := range []string{"a.bin" + partSuffix, "alice/b.bin" + partSuffix, "alice/fresh.bin" + partSuffix, "alice/kept.bin"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		writeTestFile(t, filepath.Dir(path), filepath.Base(path), []byte("data"))
+		if name != "alice/fresh.bin"+partSuffix {
+			os.Chtimes(path, old, old)
+		}
+	}
+
+	cleanupStaleParts(dir)
+	for name, want := range map[string]bool{
+		"a.bin" + partSuffix:           false,
+		"alice/b.bin" + partSuffix:     false, // In a sender folder of -inbox
+		"alice/fresh.bin" + partSuffix: true,
+		"alice/kept.bin":               true,
+	} {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		if exists := err == nil; exists != want {
+			t.Errorf("%s exists %v, want %v", name, exists, want)
+		}
+	}
+}
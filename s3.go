@@ -0,0 +1,315 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// s3Storage keeps files in an S3 bucket (via -s3-bucket), using path-style requests signed with
+// AWS Signature Version 4, so S3-compatible services such as MinIO work through -s3-endpoint.
+// Credentials come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+type s3Storage struct {
+	endpoint     *url.URL // Scheme and host, e.g. https://s3.eu-central-1.amazonaws.com
+	bucket       string
+	prefix       string // Prepended to every name, e.g. "pair/" (empty for the whole bucket)
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	client       *http.Client
+}
+
+// emptyPayloadHash is the SHA-256 of an empty body, sent with requests that have none
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// newS3Storage sets up the -s3-* flags; endpoint may be empty for AWS itself
+func newS3Storage(bucket, prefix, region, endpoint string) (*s3Storage, error) {
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid -s3-endpoint %q (use https://host[:port])", endpoint)
+	}
+	s := &s3Storage{
+		endpoint:     &url.URL{Scheme: u.Scheme, Host: u.Host},
+		bucket:       bucket,
+		prefix:       strings.TrimPrefix(prefix, "/"),
+		region:       region,
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		client:       &http.Client{Timeout: 30 * time.Minute},
+	}
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil, errors.New("set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY for -s3-bucket")
+	}
+	if s.prefix != "" && !strings.HasSuffix(s.prefix, "/") {
+		s.prefix += "/"
+	}
+	return s, nil
+}
+
+func (s *s3Storage) Location(name string) string {
+	return "s3://" + s.bucket + "/" + s.prefix + name
+}
+
+// objectURL returns the path-style URL of an object (or of the bucket for an empty key)
+func (s *s3Storage) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	u.Path = "/" + s.bucket
+	u.RawPath = "/" + s3Escape(s.bucket, false)
+	if key != "" {
+		u.Path += "/" + key
+		u.RawPath += "/" + s3Escape(key, false)
+	}
+	return &u
+}
+
+func (s *s3Storage) Open(name string) (io.ReadCloser, error) {
+	if !validStorageName(name) {
+		return nil, fmt.Errorf("invalid file name %q", name)
+	}
+	resp, err := s.do(http.MethodGet, s.objectURL(s.prefix+name), nil, 0, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *s3Storage) Stat(name string) (StoredFile, error) {
+	if !validStorageName(name) {
+		return StoredFile{}, fmt.Errorf("invalid file name %q", name)
+	}
+	resp, err := s.do(http.MethodHead, s.objectURL(s.prefix+name), nil, 0, emptyPayloadHash)
+	if err != nil {
+		return StoredFile{}, err
+	}
+	resp.Body.Close()
+	file := StoredFile{Name: name, Size: resp.ContentLength}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		file.ModTime = modTime
+	}
+	return file, nil
+}
+
+// Create spools the data into a temp file, whose size and SHA-256 the upload request needs,
+// and sends it to the bucket on Close
+func (s *s3Storage) Create(name string) (io.WriteCloser, error) {
+	if !validStorageName(name) {
+		return nil, fmt.Errorf("invalid file name %q", name)
+	}
+	spool, err := os.CreateTemp("", "pair-s3-*")
+	if err != nil {
+		return nil, err
+	}
+	return &s3Writer{storage: s, key: s.prefix + name, spool: spool, hash: sha256.New()}, nil
+}
+
+// s3Writer is an object being written to S3 (see Create)
+type s3Writer struct {
+	storage *s3Storage
+	key     string
+	spool   *os.File
+	hash    hash.Hash
+	size    int64
+}
+
+func (sw *s3Writer) Write(b []byte) (int, error) {
+	n, err := sw.spool.Write(b)
+	sw.hash.Write(b[:n])
+	sw.size += int64(n)
+	return n, err
+}
+
+func (sw *s3Writer) Close() error {
+	defer os.Remove(sw.spool.Name())
+	defer sw.spool.Close()
+	if _, err := sw.spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	resp, err := sw.storage.do(http.MethodPut, sw.storage.objectURL(sw.key), sw.spool, sw.size, hex.EncodeToString(sw.hash.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// s3ListResult is the part of a ListObjectsV2 response that List needs
+type s3ListResult struct {
+	Contents []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func (s *s3Storage) List() ([]StoredFile, error) {
+	var files []StoredFile
+	token := ""
+	for {
+		u := s.objectURL("")
+		query := url.Values{"list-type": {"2"}, "prefix": {s.prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		u.RawQuery = s3Query(query)
+		resp, err := s.do(http.MethodGet, u, nil, 0, emptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket listing: %v", err)
+		}
+		for _, object := range result.Contents {
+			name := strings.TrimPrefix(object.Key, s.prefix)
+			if validStorageName(name) && !strings.HasSuffix(name, "/") { // "folder/" markers are no files
+				files = append(files, StoredFile{Name: name, Size: object.Size, ModTime: object.LastModified})
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return files, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// do sends a signed request and turns an S3 error response into an error (fs.ErrNotExist for 404)
+func (s *s3Storage) do(method string, u *url.URL, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	s.sign(req, payloadHash, time.Now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	code := s3ErrorCode(resp)
+	if code == "NoSuchKey" || resp.StatusCode == http.StatusNotFound && method == http.MethodHead {
+		return nil, fmt.Errorf("%s: %w", u.Path, fs.ErrNotExist)
+	}
+	return nil, fmt.Errorf("%s %s: %s %s", method, u.Path, resp.Status, code)
+}
+
+// s3ErrorCode reads the <Code> of an S3 error response body (empty for HEAD, which has none)
+func s3ErrorCode(resp *http.Response) string {
+	var result struct{ Code string }
+	xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result)
+	return result.Code
+}
+
+// sign adds the AWS Signature Version 4 headers to req. The payload hash is sent as
+// x-amz-content-sha256 and signed along with the host, any Range and all x-amz-* headers.
+func (s *s3Storage) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set("x-amz-security-token", s.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "range" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery, // Built by s3Query, so already canonical
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	for _, part := range []string{s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape percent-encodes everything but the unreserved characters, as Signature Version 4
+// requires; slashes are kept in paths and encoded in query values
+func s3Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && !encodeSlash {
+			b.WriteByte(c)
+		} else {
+			b.WriteString("%" + strings.ToUpper(strconv.FormatUint(uint64(c)|0x100, 16)[1:]))
+		}
+	}
+	return b.String()
+}
+
+// s3Query encodes a query string in canonical form: sorted by name, every part s3Escape'd
+func s3Query(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, s3Escape(name, true)+"="+s3Escape(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+)
+
+// sandboxConflicts returns the given flags that would write to disk (or run commands that
+// might), which -sandbox refuses instead of silently ignoring
+func sandboxConflicts() []string {
+	var conflicts []string
+	for name, used := range map[string]bool{
+		"-history":               historyEnabled,
+		"-delete-after-download": deleteAfterDownload,
+		"-manage":                manageEnabled,
+		"-snapshot":              snapshotDownloads,
+		"-mirror":                mirrorTarget != "",
+		"-unzip":                 unzipUploads,
+		"-upload-cmd":            uploadCommand != "",
+		"-transform-cmd":         len(transformCommands) > 0,
+		"-qr-svg":                qrSVGPath != "" && qrSVGPath != "-", // Stdout is fine
+		"-log-file":              logFile != "",
+	} {
+		if used {
+			conflicts = append(conflicts, name)
+		}
+	}
+	slices.Sort(conflicts)
+	return conflicts
+}
+
+// uploadsDisabled rejects the request with 403 in -sandbox mode or outside the -upload-window,
+// before anything is read or written
+func uploadsDisabled(w http.ResponseWriter) bool {
+	if !sandboxMode {
+		return uploadsClosed(w)
+	}
+	http.Error(w, "Uploads are disabled: this pair instance is read-only (-sandbox)", http.StatusForbidden)
+	return true
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// selfTestTimeout bounds the -selftest request
+const selfTestTimeout = 5 * time.Second
+
+// runSelfTest requests the advertised URL from this process (via -selftest) to confirm the
+// server answers on the chosen address. Any HTTP response counts as reachable; a one-byte
+// Range keeps a shared file from actually being downloaded.
+func runSelfTest(target string) {
+	client := &http.Client{
+		Timeout: selfTestTimeout,
+		// Never follow redirects (e.g. -default code) or fetch anything beyond the first response
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		Transport: &http.Transport{
+			// The -tls certificate is our own (possibly self-signed) one
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		fmt.Printf("%sSelf-test FAILED: invalid URL %s: %v\n", glyph("❌ ", "[FAIL] "), target, err)
+		return
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Printf("%sSelf-test FAILED: %s is not reachable: %v\n", glyph("❌ ", "[FAIL] "), target, err)
+		fmt.Printf("  - Check that a firewall allows incoming TCP connections on port %s\n", listenPorts[0])
+		fmt.Println("  - If the address belongs to the wrong network (VPN, Docker, VM), pick one with -prefer IFACE")
+		return
+	}
+	resp.Body.Close()
+	fmt.Printf("%sSelf-test passed: %s answered with %s\n", glyph("✅ ", "[OK] "), target, resp.Status)
+	fmt.Println("  (the request stays on this machine; a firewall can still block other devices)")
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"crypto/rand"
+	"html/template"
+	"math/big"
+)
+
+// Session code: a short random code printed at startup and shown at the bottom of every page,
+// so a recipient can check that the page on their phone comes from this computer and not from
+// another host on the LAN pretending to be it
+const (
+	sessionCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // No 0/O or 1/I to confuse
+	sessionCodeLength   = 6                                  // Shown as two groups of three
+)
+
+var sessionCode string // Generated at startup
+
+// generateSessionCode returns a random code such as K7Q-F2M
+func generateSessionCode() (string, error) {
+	code := make([]byte, 0, sessionCodeLength+1)
+	limit := big.NewInt(int64(len(sessionCodeAlphabet)))
+	for i := 0; i < sessionCodeLength; i++ {
+		if i == sessionCodeLength/2 {
+			code = append(code, '-')
+		}
+		n, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", err
+		}
+		code = append(code, sessionCodeAlphabet[n.Int64()])
+	}
+	return string(code), nil
+}
+
+// sessionFooter is the footer with the session code that every page ends with
+func sessionFooter() template.HTML {
+	return template.HTML(`<footer style="margin: 30px 0 10px; text-align: center; color: #999; font-size: 0.8rem;">Session code <strong>` +
+		template.HTMLEscapeString(sessionCode) + `</strong> &middot; check that it matches the one on the computer</footer>`)
+}
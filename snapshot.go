@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// contentChangedHeader is sent as a trailer with -detect-changes downloads
+const contentChangedHeader = "X-Content-Changed"
+
+// snapshotFile copies the first size bytes of src to a private temp file (via -snapshot),
+// so a writer appending to or rewriting the original can't change what is served.
+// The returned file is positioned at the start; release it with removeSnapshot.
+func snapshotFile(src *os.File, size int64) (*os.File, error) {
+	snapshot, err := os.CreateTemp("", "pair-snapshot-*")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyBuffer(snapshot, io.LimitReader(src, size), make([]byte, transferBufSize)); err != nil {
+		removeSnapshot(snapshot)
+		return nil, err
+	}
+	if _, err := snapshot.Seek(0, io.SeekStart); err != nil {
+		removeSnapshot(snapshot)
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// removeSnapshot closes and deletes a temp file created by snapshotFile
+func removeSnapshot(snapshot *os.File) {
+	snapshot.Close()
+	if err := os.Remove(snapshot.Name()); err != nil {
+		fmt.Printf("Failed to remove snapshot %s: %v\n", snapshot.Name(), err)
+	}
+}
+
+// fileChanged reports whether the file at path no longer has the given size and mod time
+func fileChanged(path string, size int64, modTime time.Time) bool {
+	info, err := os.Stat(path)
+	return err != nil || info.Size() != size || !info.ModTime().Equal(modTime)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// Persistent state: one JSON file in the state directory (via -state-dir, default
+// <user config dir>/pair) for data that should survive restarts. It is loaded at startup
+// and written back on shutdown, and only if something changed. Append-only logs such as
+// the transfer history live next to it as their own files.
+const (
+	stateFileName = "state.json"
+	stateVersion  = 1
+)
+
+// pairState is the content of state.json
+type pairState struct {
+	Version int    `json:"version"`
+	TLSCert string `json:"tls_cert,omitempty"` // PEM of the self-signed -tls certificate, reused while it is valid
+	TLSKey  string `json:"tls_key,omitempty"`  // PEM of its private key
+}
+
+var (
+	state      pairState
+	stateDirty bool // state differs from the file
+	stateMu    sync.Mutex
+)
+
+// defaultStateDir returns <user config dir>/pair
+func defaultStateDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pair"), nil
+}
+
+// loadState reads state.json from the state directory; a missing file is an empty state
+func loadState() error {
+	data, err := os.ReadFile(filepath.Join(stateDir, stateFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var loaded pairState
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("%s: %w", stateFileName, err)
+	}
+	stateMu.Lock()
+	state = loaded
+	stateMu.Unlock()
+	return nil
+}
+
+// updateState changes the state under the lock and marks it for saving
+func updateState(change func(*pairState)) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	change(&state)
+	stateDirty = true
+}
+
+// saveState writes the state back if it changed (never with -sandbox). The file may hold
+// private keys, so it is only readable by the owner and replaced atomically.
+func saveState() error {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	if !stateDirty || sandboxMode || stateDir == "" {
+		return nil
+	}
+
+	state.Version = stateVersion
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stateDir, 0o700); err != nil {
+		return err
+	}
+	path := filepath.Join(stateDir, stateFileName)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	stateDirty = false
+	return nil
+}
+
+// saveStateOrWarn saves the state, only reporting failures (the session itself is over)
+func saveStateOrWarn() {
+	if err := saveState(); err != nil {
+		fmt.Printf("Failed to save state to %s: %v\n", stateDir, err)
+	}
+}
+
+// saveStateOnSignal saves the state when pair is stopped with Ctrl+C or SIGTERM, then exits
+func saveStateOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		saveStateOrWarn()
+		os.Exit(0)
+	}()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Status line (via -status): one line at the bottom of the terminal with the number of received
+// files and bytes, redrawn in place as uploads arrive. The numbers are the upload counters of
+// /metrics, so both always agree. Everything else printed while it is shown (the QR code, log
+// messages, download progress) is written above it: stdout is passed through a pipe and log
+// output through statusAwareWriter, both of which clear the line first, and the next refresh
+// draws it again below.
+
+var (
+	statusMu       sync.Mutex
+	statusTerminal *os.File // The real stdout, os.Stdout is the pipe into relayStdout
+	statusShown    bool     // The line is on the terminal and the cursor is at its end
+	statusMidLine  bool     // Other output stopped before the end of a line (e.g. -v progress), wait for it
+)
+
+// stdoutIsTerminal reports whether stdout is a terminal (the line is drawn with escape codes)
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// startStatusLine takes over stdout and keeps the status line up to date below everything printed
+func startStatusLine() error {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	statusTerminal, os.Stdout = os.Stdout, writer
+	go relayStdout(reader)
+
+	go func() {
+		var files, bytes int64 = -1, -1
+		for ; ; time.Sleep(progressInterval) {
+			currentFiles, currentBytes := metricUploads.Load(), metricBytesUploaded.Load()
+			statusMu.Lock()
+			if !statusMidLine && (!statusShown || currentFiles != files || currentBytes != bytes) {
+				files, bytes = currentFiles, currentBytes
+				fmt.Fprintf(statusTerminal, "\r\033[K%sReceived: %d file%s, %s", glyph("📥 ", "- "), files, plural(files), formatFileSize(bytes))
+				statusShown = true
+			}
+			statusMu.Unlock()
+		}
+	}()
+	return nil
+}
+
+// relayStdout copies what is printed to stdout onto the terminal, above the status line
+func relayStdout(reader io.Reader) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			statusMu.Lock()
+			eraseStatus()
+			statusTerminal.Write(buf[:n])
+			statusMidLine = buf[n-1] != '\n'
+			statusMu.Unlock()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// eraseStatus clears the drawn line, leaving the cursor at the start of an empty line.
+// statusMu must be held.
+func eraseStatus() {
+	if statusShown {
+		fmt.Fprint(statusTerminal, "\r\033[K")
+		statusShown = false
+	}
+}
+
+// plural returns the "s" for n things
+func plural(n int64) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+// statusAwareWriter clears the status line before each write to w, for log output on the terminal
+type statusAwareWriter struct {
+	w io.Writer
+}
+
+func (sw statusAwareWriter) Write(b []byte) (int, error) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	eraseStatus()
+	return sw.w.Write(b)
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Storage backends: where received files end up. The default keeps them in the upload directory
+// as before; with -s3-bucket they are moved into an S3 bucket once received, and the download
+// list shows the bucket's files instead of -f/-x/-d. Uploads are still received into the upload
+// directory first (every upload check works on a local file), so the computer only holds a file
+// while it is in transit.
+
+// StoredFile describes one file in a Storage
+type StoredFile struct {
+	Name    string // Slash-separated path inside the storage
+	Size    int64
+	ModTime time.Time
+}
+
+// Storage is a place files can be written to and read back from by name
+type Storage interface {
+	Open(name string) (io.ReadCloser, error)
+	Create(name string) (io.WriteCloser, error) // The file is complete once Close returns nil
+	Stat(name string) (StoredFile, error)       // fs.ErrNotExist if there is no such file
+	List() ([]StoredFile, error)
+	Location(name string) string // Where name is kept, for messages
+}
+
+// storage is the backend set up in main: localStorage on the upload directory, or s3Storage
+var storage Storage
+
+// validStorageName reports whether name is a clean relative path that stays inside the storage
+func validStorageName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, "/") && path.Clean(name) == name && name != ".." && !strings.HasPrefix(name, "../")
+}
+
+// localStorage keeps files in a directory (the default: uploads stay where they were saved)
+type localStorage struct {
+	dir string
+}
+
+func (s localStorage) path(name string) (string, error) {
+	if !validStorageName(name) {
+		return "", fmt.Errorf("invalid file name %q", name)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(name)), nil
+}
+
+func (s localStorage) Open(name string) (io.ReadCloser, error) {
+	p, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (s localStorage) Create(name string) (io.WriteCloser, error) {
+	p, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), uploadDirMode); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(p, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, uploadFileMode)
+}
+
+func (s localStorage) Stat(name string) (StoredFile, error) {
+	p, err := s.path(name)
+	if err != nil {
+		return StoredFile{}, err
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		return StoredFile{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return StoredFile{}, fmt.Errorf("%s is not a regular file: %w", name, fs.ErrNotExist)
+	}
+	return StoredFile{Name: name, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (s localStorage) List() ([]StoredFile, error) {
+	var files []StoredFile
+	err := filepath.WalkDir(s.dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil // Removed meanwhile
+		}
+		rel, err := filepath.Rel(s.dir, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, StoredFile{Name: filepath.ToSlash(rel), Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	return files, err
+}
+
+func (s localStorage) Location(name string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(name))
+}
+
+// remoteStorage reports whether received files are moved away from the upload directory
+func remoteStorage() bool {
+	_, local := storage.(localStorage)
+	return storage != nil && !local
+}
+
+// storeUpload moves a saved upload into a remote storage backend (a no-op for the local one)
+// under its path relative to the working directory. On failure the file stays where it is,
+// so nothing is lost, and the error is only logged like -mirror failures: the upload itself
+// has succeeded.
+func storeUpload(savePath string) {
+	if !remoteStorage() {
+		return
+	}
+	rel, err := filepath.Rel(currentWorkDir, savePath)
+	if err != nil {
+		log.Printf("Warning: failed to store %s: %v", savePath, err)
+		return
+	}
+	name := filepath.ToSlash(rel)
+	if err := copyToStorage(savePath, name); err != nil {
+		log.Printf("Warning: failed to store %s in %s, it was kept on this computer: %v", savePath, storage.Location(name), err)
+		return
+	}
+	if err := os.Remove(savePath); err != nil {
+		fmt.Printf("Failed to remove %s after storing it: %v\n", savePath, err)
+	}
+	if verbose {
+		log.Printf("Stored %s in %s", rel, storage.Location(name))
+	}
+}
+
+// copyToStorage writes a local file into the storage backend as name
+func copyToStorage(localPath, name string) error {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := storage.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyBuffer(dst, src, make([]byte, transferBufSize)); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// getStoredFiles returns the files of a remote storage backend for the download list
+func getStoredFiles() []DownloadFileInfo {
+	stored, err := storage.List()
+	if err != nil {
+		log.Printf("Warning: failed to list %s: %v", storage.Location(""), err)
+		return nil
+	}
+	slices.SortFunc(stored, func(a, b StoredFile) int { return strings.Compare(a.Name, b.Name) })
+	files := make([]DownloadFileInfo, 0, len(stored))
+	for _, file := range stored {
+		files = append(files, DownloadFileInfo{
+			FileName: path.Base(file.Name),
+			RelPath:  file.Name,
+			Size:     file.Size,
+			ModTime:  file.ModTime,
+			Kind:     fileKind(file.Name),
+			Exists:   true,
+		})
+	}
+	return files
+}
+
+// serveStoredFile answers /download/ from a remote storage backend: whole files only (no
+// Range), streamed through this computer without touching its disk
+func serveStoredFile(w http.ResponseWriter, r *http.Request) {
+	name, err := url.PathUnescape(strings.TrimPrefix(r.URL.Path, "/download/"))
+	if err != nil || !validStorageName(name) {
+		http.Error(w, "Invalid file path", http.StatusBadRequest)
+		return
+	}
+	file, err := storage.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.Error(w, fmt.Sprintf("File %s does not exist", name), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to get file information: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	fileName := path.Base(name)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", contentDisposition(fileName))
+	w.Header().Set("Accept-Ranges", "none")
+	w.Header().Set("Content-Length", fmt.Sprint(file.Size))
+	if !file.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", file.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	body, err := storage.Open(name)
+	if err != nil {
+		w.Header().Del("Content-Length")
+		http.Error(w, fmt.Sprintf("Failed to open file: %v", err), http.StatusBadGateway)
+		return
+	}
+	defer body.Close()
+
+	progress := newProgressPrinter(fileName, file.Size)
+	defer progress.finish()
+	written, err := io.CopyBuffer(io.MultiWriter(w, progressWriter{progress}), io.LimitReader(body, file.Size), make([]byte, transferBufSize))
+	metricBytesDownloaded.Add(written)
+	if err != nil || written < file.Size {
+		fmt.Printf("Download of %s from %s ended after %d of %d bytes: %v\n", fileName, storage.Location(name), written, file.Size, err)
+		panic(http.ErrAbortHandler) // The client must not take a short body for the whole file
+	}
+	metricDownloads.Add(1)
+	recordTransfer(r, "download", fileName, written)
+	countDownload()
+}
+
+// progressWriter feeds written bytes to the -v progress line
+type progressWriter struct {
+	progress *progressPrinter
+}
+
+func (pw progressWriter) Write(b []byte) (int, error) {
+	pw.progress.add(int64(len(b)))
+	return len(b), nil
+}
+
+// storageConflicts returns the given flags that need the files on this computer, which a
+// remote storage backend refuses instead of silently ignoring
+func storageConflicts() []string {
+	var conflicts []string
+	for name, used := range map[string]bool{
+		"-f":                     allowSingleFilePath != "",
+		"-x":                     len(allowMultiFilePaths) > 0,
+		"-d":                     sharedDir != "",
+		"-from-archive":          sourceArchive != "",
+		"-upload-cmd":            uploadCommand != "",
+		"-transform-cmd":         len(transformCommands) > 0,
+		"-mirror":                mirrorTarget != "",
+		"-unzip":                 unzipUploads,
+		"-dedupe":                dedupeEnabled,
+		"-manage":                manageEnabled,
+		"-confirm":               confirmDownloads,
+		"-tail":                  tailMode,
+		"-snapshot":              snapshotDownloads,
+		"-precompressed":         precompressed,
+		"-detect-changes":        detectChanges,
+		"-delete-after-download": deleteAfterDownload,
+	} {
+		if used {
+			conflicts = append(conflicts, name)
+		}
+	}
+	slices.Sort(conflicts)
+	return conflicts
+}
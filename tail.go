@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// tailPollInterval is how often a followed file is checked for appended data (via -tail)
+const tailPollInterval = 500 * time.Millisecond
+
+// tailRequested reports whether a download follows the file: with -tail, every full GET of
+// /download/ does unless it asks for ?tail=0. Range and HEAD requests are served as usual.
+func tailRequested(r *http.Request) bool {
+	return tailMode && r.Method == http.MethodGet && r.Header.Get("Range") == "" && r.URL.Query().Get("tail") != "0"
+}
+
+// serveTail streams a file like tail -f: its current content, then whatever is appended, until
+// the client disconnects. It is sent as inline text so a phone browser shows it as it grows.
+// A file that shrinks (truncated) is sent again from the start, one that was replaced under
+// its name (rotated) is reopened.
+func serveTail(w http.ResponseWriter, r *http.Request, file *os.File, servePath, fileName string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no") // Keep reverse proxies from holding the stream back
+	rc := http.NewResponseController(w)
+
+	var sent, offset int64
+	defer func() {
+		// Counted once the viewer leaves, like a completed download
+		metricDownloads.Add(1)
+		recordTransfer(r, "download", fileName, sent)
+		countDownload()
+	}()
+
+	// The caller closes file; reopened files are closed here
+	current := file
+	defer func() {
+		if current != file {
+			current.Close()
+		}
+	}()
+
+	buf := make([]byte, transferBufSize)
+	ticker := time.NewTicker(tailPollInterval)
+	defer ticker.Stop()
+	for {
+		n, err := current.Read(buf)
+		if n > 0 {
+			if _, writeErr := w.Write(buf[:n]); writeErr != nil {
+				return // The viewer has gone
+			}
+			sent += int64(n)
+			offset += int64(n)
+			metricBytesDownloaded.Add(int64(n))
+			continue
+		}
+		if err != nil && err != io.EOF {
+			fmt.Printf("Failed to read followed file %s: %v\n", servePath, err)
+			return
+		}
+
+		// Caught up: send what we have, then wait for the file to grow
+		if err := rc.Flush(); err != nil {
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		if reopened := reopenRotated(current, servePath); reopened != nil {
+			if current != file {
+				current.Close()
+			}
+			current, offset = reopened, 0
+		} else if info, err := current.Stat(); err == nil && info.Size() < offset {
+			if _, err := current.Seek(0, io.SeekStart); err != nil {
+				fmt.Printf("Failed to rewind followed file %s: %v\n", servePath, err)
+				return
+			}
+			offset = 0
+		}
+	}
+}
+
+// reopenRotated opens path again if it now names a different file than the open one, or
+// returns nil (also while the new file does not exist yet)
+func reopenRotated(file *os.File, path string) *os.File {
+	current, err := file.Stat()
+	if err != nil {
+		return nil
+	}
+	latest, err := os.Stat(path)
+	if err != nil || os.SameFile(current, latest) {
+		return nil
+	}
+	reopened, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	return reopened
+}
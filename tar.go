@@ -0,0 +1,99 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tarArchiveName is the file name offered for /download-tar
+const tarArchiveName = "pair-files.tar.gz"
+
+// archiveFormats are the accepted -archive values (the format the list page's button uses)
+var archiveFormats = []string{"zip", "tar.gz"}
+
+// archiveLink returns the route of the -archive format and its label for the list page
+func archiveLink() (string, string) {
+	if archiveFormat == "tar.gz" {
+		return "/download-tar", "TAR.GZ"
+	}
+	return "/download-zip", "ZIP"
+}
+
+// downloadTarHandler streams all allowed files as one gzip-compressed tar archive. tar has no
+// encryption, so it is refused when -zip-pass protects the archive download.
+func downloadTarHandler(w http.ResponseWriter, r *http.Request) {
+	if zipPassword != "" {
+		http.Error(w, "The archive is password-protected, use /download-zip", http.StatusForbidden)
+		return
+	}
+	files, totalSize, ok := archiveFiles(w, r, tarArchiveName)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", contentDisposition(tarArchiveName))
+
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+	buf := make([]byte, transferBufSize)
+	for _, file := range files {
+		if err := addFileToTar(tarWriter, file, buf); err != nil {
+			// Headers are already sent, the truncated archive is the only signal left
+			fmt.Printf("Failed to add %s to TAR: %v\n", file.RelPath, err)
+			return
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		fmt.Printf("Failed to finish TAR: %v\n", err)
+		return
+	}
+	if err := gzipWriter.Close(); err != nil {
+		fmt.Printf("Failed to finish TAR: %v\n", err)
+		return
+	}
+	metricDownloads.Add(1)
+	recordTransfer(r, "download", tarArchiveName, totalSize)
+	countDownload()
+}
+
+// addFileToTar writes one allowed file into the archive under its relative path. The entry
+// size is fixed in the header, so exactly that many bytes are copied even if the file grows.
+func addFileToTar(tarWriter *tar.Writer, file DownloadFileInfo, buf []byte) error {
+	realPath, err := resolveServedPath(file.AbsPath)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(realPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	stat, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := tar.FileInfoHeader(stat, "")
+	if err != nil {
+		return err
+	}
+	header.Name = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(file.RelPath)), "/")
+	header.Uname, header.Gname = "", "" // Don't leak local account names
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	n, err := io.CopyBuffer(tarWriter, io.LimitReader(src, stat.Size()), buf)
+	metricBytesDownloaded.Add(n)
+	if err == nil && n < stat.Size() {
+		err = fmt.Errorf("file shrank while it was being archived")
+	}
+	return err
+}
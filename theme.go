@@ -0,0 +1,69 @@
+package main
+
+import "html/template"
+
+// Dark mode for the upload page and the download list. It is purely client-side: the theme
+// follows prefers-color-scheme until the visitor picks one with the toggle button, which is
+// remembered in localStorage. The script runs in <head>, before the page is drawn, so a dark
+// page does not flash white; without JavaScript the page stays light and the toggle is hidden.
+
+// themeHead is the dark theme's style and the script that applies it, for the page's <head>
+func themeHead() template.HTML {
+	return template.HTML(`<style>
+        :root[data-theme="dark"] { color-scheme: dark; }
+        :root[data-theme="dark"] body { background-color: #121212; color: #e0e0e0; }
+        :root[data-theme="dark"] h1, :root[data-theme="dark"] .message { color: #e0e0e0; }
+        :root[data-theme="dark"] .upload-box, :root[data-theme="dark"] .list-container,
+        :root[data-theme="dark"] .empty-message, :root[data-theme="dark"] .progress-container { border-color: #444; }
+        :root[data-theme="dark"] th { background-color: #1e1e1e; }
+        :root[data-theme="dark"] th, :root[data-theme="dark"] td { border-bottom-color: #333; }
+        :root[data-theme="dark"] .summary, :root[data-theme="dark"] .pagination,
+        :root[data-theme="dark"] .empty-message, :root[data-theme="dark"] #progressText { color: #aaa; }
+        :root[data-theme="dark"] #tokenInput, :root[data-theme="dark"] #inboxInput,
+        :root[data-theme="dark"] .search input { background-color: #1e1e1e; color: #e0e0e0; border-color: #555; }
+        :root[data-theme="dark"] .download-link, :root[data-theme="dark"] .back-link,
+        :root[data-theme="dark"] .pagination a, :root[data-theme="dark"] .summary a { color: #8ab4f8; }
+        :root[data-theme="dark"] #backBtn, :root[data-theme="dark"] .search button { background: #1e1e1e; color: #8ab4f8; border-color: #8ab4f8; }
+        :root[data-theme="dark"] .success { background-color: #0f2a16; }
+        :root[data-theme="dark"] .error { background-color: #2e1215; }
+        .theme-toggle {
+            position: fixed;
+            top: 10px;
+            right: 10px;
+            width: 36px;
+            height: 36px;
+            border: 1px solid #ccc;
+            border-radius: 50%;
+            background: transparent;
+            color: inherit;
+            font-size: 1.1rem;
+            cursor: pointer;
+        }
+        :root[data-theme="dark"] .theme-toggle { border-color: #555; }
+        :root:not([data-theme]) .theme-toggle { display: none; }
+    </style>
+    <script>
+        (function () {
+            let chosen = null;
+            try { chosen = localStorage.getItem('pair-theme'); } catch (e) {}
+            const system = window.matchMedia ? window.matchMedia('(prefers-color-scheme: dark)') : null;
+            function apply() {
+                document.documentElement.dataset.theme = chosen || (system && system.matches ? 'dark' : 'light');
+            }
+            apply();
+            if (system && system.addEventListener) {
+                system.addEventListener('change', apply);
+            }
+            window.toggleTheme = function () {
+                chosen = document.documentElement.dataset.theme === 'dark' ? 'light' : 'dark';
+                try { localStorage.setItem('pair-theme', chosen); } catch (e) {}
+                apply();
+            };
+        })();
+    </script>`)
+}
+
+// themeToggle is the button that switches between the light and the dark theme
+func themeToggle() template.HTML {
+	return template.HTML(`<button type="button" class="theme-toggle" onclick="toggleTheme()" title="Dark mode on/off" aria-label="Dark mode on/off">&#9680;</button>`)
+}
@@ -0,0 +1,28 @@
+package main
+
+// pageTitle is the browser tab title of a page: the page's own name, followed by the -title
+// of the share if one is set. Callers escape it for their context.
+func pageTitle(page string) string {
+	if siteTitle == "" {
+		return page
+	}
+	return page + " - " + siteTitle
+}
+
+// pageHeading is the main heading of the upload page and the download list: the -title if
+// set, otherwise the page's own name
+func pageHeading(page string) string {
+	if siteTitle == "" {
+		return page
+	}
+	return siteTitle
+}
+
+// qrPrompt puts the -title in front of the instruction printed above a QR code, so a photo of
+// the screen shows which share it is
+func qrPrompt(prompt string) string {
+	if siteTitle == "" {
+		return prompt
+	}
+	return siteTitle + ": " + prompt
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// selfSignedValidity is how long the generated -tls certificate is valid
+const selfSignedValidity = 30 * 24 * time.Hour
+
+// reusedCertificate is set when the self-signed certificate came from the state file
+var reusedCertificate bool
+
+// tlsVersions are the accepted -tls-min values
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// modernCipherSuites is the default TLS 1.2 cipher list: forward-secret AEAD suites only.
+// TLS 1.3 suites are always enabled and not configurable in Go.
+var modernCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// parseCipherSuites maps comma-separated suite names (as listed by Go's crypto/tls) to IDs.
+// Suites Go considers insecure are rejected.
+func parseCipherSuites(names string) ([]uint16, error) {
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	var ids []uint16
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no cipher suites given")
+	}
+	return ids, nil
+}
+
+// http2Capable reports whether the cipher list satisfies HTTP/2's required suite
+// (net/http refuses to start otherwise, so HTTP/2 is turned off for such lists)
+func http2Capable(suites []uint16) bool {
+	for _, id := range suites {
+		if id == tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 || id == tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+			return true
+		}
+	}
+	return false
+}
+
+// buildTLSConfig returns the server TLS settings: -cert/-key if given, or a freshly generated
+// self-signed certificate for the advertised address otherwise
+func buildTLSConfig(localIP string) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:   tlsVersions[tlsMinVersionName],
+		CipherSuites: modernCipherSuites,
+	}
+	if tlsCipherNames != "" {
+		suites, err := parseCipherSuites(tlsCipherNames)
+		if err != nil {
+			return nil, err
+		}
+		config.CipherSuites = suites
+	}
+
+	var cert tls.Certificate
+	var err error
+	if tlsCertFile != "" {
+		cert, err = tls.LoadX509KeyPair(tlsCertFile, tlsKeyFile)
+	} else {
+		cert, err = persistedSelfSignedCertificate(localIP)
+	}
+	if err != nil {
+		return nil, err
+	}
+	config.Certificates = []tls.Certificate{cert}
+
+	// With -client-ca the handshake fails unless the client presents a certificate issued by it
+	if tlsClientCAFile != "" {
+		pool, err := loadClientCAs(tlsClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.ClientCAs = pool
+	}
+	return config, nil
+}
+
+// loadClientCAs reads the PEM certificates of a -client-ca file
+func loadClientCAs(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// selfSignedCertificate generates an in-memory ECDSA certificate for localIP and localhost
+func selfSignedCertificate(localIP string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "pair"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(selfSignedValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if ip := net.ParseIP(strings.SplitN(localIP, "%", 2)[0]); ip != nil { // Zone IDs are not part of the address
+		template.IPAddresses = append(template.IPAddresses, ip)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// persistedSelfSignedCertificate reuses the self-signed certificate from the state file while
+// it still covers localIP and has a day left, so its fingerprint stays the same across
+// restarts; otherwise it generates a new one and stores it
+func persistedSelfSignedCertificate(localIP string) (tls.Certificate, error) {
+	stateMu.Lock()
+	certPEM, keyPEM := state.TLSCert, state.TLSKey
+	stateMu.Unlock()
+	if certPEM != "" {
+		if cert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM)); err == nil && cert.Leaf != nil &&
+			time.Until(cert.Leaf.NotAfter) > 24*time.Hour &&
+			cert.Leaf.VerifyHostname(strings.SplitN(localIP, "%", 2)[0]) == nil {
+			reusedCertificate = true
+			return cert, nil
+		}
+	}
+
+	cert, err := selfSignedCertificate(localIP)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	updateState(func(s *pairState) {
+		s.TLSCert = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}))
+		s.TLSKey = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+	})
+	return cert, nil
+}
+
+// certificateFingerprint returns the SHA-256 fingerprint of the leaf certificate, as browsers show it
+func certificateFingerprint(cert tls.Certificate) string {
+	sum := sha256.Sum256(cert.Certificate[0])
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Transforms (via -transform-cmd NAME=CMD, repeatable): /download/FILE?transform=NAME streams
+// FILE through CMD instead of sending it as is, e.g. to serve thumbnails or previews without
+// storing them. The command gets the file on stdin and its path in $PAIR_FILE (for tools that
+// need to seek), and its stdout is the response. It only runs on files the request could
+// download anyway, and is killed when the client goes away.
+const transformParam = "transform"
+
+// transformCommands maps each -transform-cmd name to its command
+var transformCommands map[string]string
+
+// transformNamePattern is what a transform name may look like (it appears in URLs)
+var transformNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// transformSlots limits how many transform commands run at once, so a page full of thumbnails
+// does not start a process per image at the same time
+var transformSlots = make(chan struct{}, runtime.NumCPU())
+
+// addTransform parses one -transform-cmd value
+func addTransform(value string) error {
+	name, command, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(command) == "" {
+		return fmt.Errorf("%q is not NAME=COMMAND", value)
+	}
+	if !transformNamePattern.MatchString(name) {
+		return fmt.Errorf("transform name %q may only contain letters, digits, - and _", name)
+	}
+	if _, exists := transformCommands[name]; exists {
+		return fmt.Errorf("transform %q is defined twice", name)
+	}
+	if transformCommands == nil {
+		transformCommands = make(map[string]string)
+	}
+	transformCommands[name] = command
+	return nil
+}
+
+// transformNames returns the configured transform names, sorted
+func transformNames() []string {
+	names := make([]string, 0, len(transformCommands))
+	for name := range transformCommands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// serveTransformed answers a ?transform= request with the output of the transform command.
+// The content type is detected from the first bytes of the output. A command that fails
+// before writing anything gets a 502; once output has been sent, a failure aborts the response.
+func serveTransformed(w http.ResponseWriter, r *http.Request, file *os.File, servePath, fileName string) {
+	name := r.URL.Query().Get(transformParam)
+	command, ok := transformCommands[name]
+	if len(transformCommands) == 0 {
+		http.Error(w, "No transforms are available (start pair with -transform-cmd)", http.StatusBadRequest)
+		return
+	}
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown transform %q (available: %s)", name, strings.Join(transformNames(), ", ")), http.StatusBadRequest)
+		return
+	}
+
+	select {
+	case transformSlots <- struct{}{}:
+		defer func() { <-transformSlots }()
+	case <-r.Context().Done():
+		return
+	}
+
+	// The command (and whatever it started) is killed once the client has gone
+	cmd := shellCommand(r.Context(), command)
+	cmd.Dir = currentWorkDir
+	cmd.Env = append(os.Environ(), "PAIR_FILE="+servePath, "PAIR_FILENAME="+fileName, "PAIR_TRANSFORM="+name)
+	cmd.Stdin = file
+	cmd.Stderr = os.Stderr
+	startInProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		killUploadCommand(cmd)
+		return nil
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		serverError(w, "Failed to start transform", err)
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		serverError(w, "Failed to start transform", err)
+		return
+	}
+
+	output := bufio.NewReaderSize(stdout, 512)
+	head, _ := output.Peek(512)
+	if len(head) == 0 {
+		io.Copy(io.Discard, output)
+		err := cmd.Wait()
+		if err == nil {
+			err = fmt.Errorf("no output")
+		}
+		http.Error(w, fmt.Sprintf("Transform %s of %s failed: %v", name, fileName, err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", http.DetectContentType(head))
+	w.Header().Set("Cache-Control", "no-cache")
+	written, copyErr := io.CopyBuffer(w, output, make([]byte, transferBufSize))
+	waitErr := cmd.Wait()
+	metricBytesDownloaded.Add(written)
+	if copyErr != nil || waitErr != nil {
+		log.Printf("Warning: transform %s of %s ended after %d bytes: %v", name, fileName, written, cmp.Or(copyErr, waitErr))
+		panic(http.ErrAbortHandler) // A cut-off image or video must not look complete
+	}
+	if verbose {
+		log.Printf("Transformed %s with %s (%s)", fileName, name, formatFileSize(written))
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// isZipUpload reports whether an uploaded file should be extracted (via -unzip)
+func isZipUpload(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".zip")
+}
+
+// unzipUpload extracts a saved .zip upload (via -unzip) into the upload directory, or the
+// -unzip-dir subfolder, and returns a note for the upload response ("" if not extracted).
+// A bad archive is reported in the note; the uploaded archive itself is always kept.
+func unzipUpload(savePath string) string {
+	if !unzipUploads || !isZipUpload(savePath) {
+		return ""
+	}
+
+	destDir := filepath.Dir(savePath)
+	if unzipDir != "" {
+		destDir = filepath.Join(destDir, unzipDir)
+	}
+	name := filepath.Base(savePath)
+	count, err := extractZip(savePath, destDir)
+	if err != nil {
+		fmt.Printf("Failed to extract %s: %v\n", savePath, err)
+		return fmt.Sprintf("failed to extract %s (%d files written): %v", name, count, err)
+	}
+	return fmt.Sprintf("extracted %d files from %s", count, name)
+}
+
+// zipEntryPath maps an archive entry name to a path under destDir, rejecting absolute names
+// and any ".." component so a crafted archive cannot write outside destDir (Zip Slip)
+func zipEntryPath(destDir, name string) (string, error) {
+	slashed := strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(slashed, "/") || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("absolute entry name %q", name)
+	}
+	for _, part := range strings.Split(slashed, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("entry name %q contains \"..\"", name)
+		}
+	}
+	target := filepath.Join(destDir, filepath.FromSlash(slashed))
+	if !isWithinDir(destDir, target) {
+		return "", fmt.Errorf("entry name %q escapes the target directory", name)
+	}
+	return target, nil
+}
+
+// extractZip unpacks the archive into destDir and returns the number of files written.
+// Every entry name is checked before anything is written; symlinks and other special
+// entries are skipped, and existing files are never overwritten. Extracted files count like
+// uploads: each is held to -max-file-size and reserved against -max-total, and extraction
+// stops at the first one over a limit, so a small zip bomb cannot fill the disk.
+func extractZip(archivePath, destDir string) (int, error) {
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return 0, err
+	}
+	defer archive.Close()
+
+	targets := make([]string, len(archive.File))
+	for i, entry := range archive.File {
+		if targets[i], err = zipEntryPath(destDir, entry.Name); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := os.MkdirAll(destDir, uploadDirMode); err != nil {
+		return 0, err
+	}
+	extracted := 0
+	buf := make([]byte, transferBufSize)
+	for i, entry := range archive.File {
+		mode := entry.Mode()
+		if mode.IsDir() {
+			if err := os.MkdirAll(targets[i], uploadDirMode); err != nil {
+				return extracted, err
+			}
+			continue
+		}
+		if !mode.IsRegular() {
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(targets[i]), uploadDirMode); err != nil {
+			return extracted, err
+		}
+		if err := extractZipEntry(entry, targets[i], buf); err != nil {
+			return extracted, fmt.Errorf("%s: %w", entry.Name, err)
+		}
+		extracted++
+	}
+	return extracted, nil
+}
+
+// extractZipEntry writes one archive entry to a new file at target. The size the archive
+// declares is checked and reserved first, and no more than that is ever written.
+func extractZipEntry(entry *zip.File, target string, buf []byte) error {
+	size := int64(entry.UncompressedSize64)
+	if size < 0 || (maxFileSize > 0 && size > maxFileSize) {
+		return fmt.Errorf("larger than the %s limit", formatFileSize(maxFileSize))
+	}
+	if !reserveUploadSpace(size) {
+		return fmt.Errorf("would exceed the %s total upload limit of this session (%s left)",
+			formatFileSize(maxTotalUpload), formatFileSize(remainingUploadSpace()))
+	}
+	written := int64(0)
+	defer func() { releaseUploadSpace(size - written) }()
+
+	src, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, uploadFileMode)
+	if err != nil {
+		return err
+	}
+	n, err := io.CopyBuffer(dst, io.LimitReader(src, size+1), buf) // One byte more reveals a wrong declared size
+	if err == nil && n > size {
+		err = fmt.Errorf("more data than the declared %d bytes", size)
+	}
+	if err != nil {
+		removePartial(dst, target)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(target)
+		return err
+	}
+	written = n
+	return nil
+}
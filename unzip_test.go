@@ -0,0 +1,57 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnzipLimits(t *testing.T) {
+	t.Cleanup(func() {
+		unzipUploads, maxFileSize, maxTotalUpload = false, 0, 0
+		uploadedTotal.Store(0)
+	})
+	for _, test := range []struct {
+		name                  string
+		maxFileSize, maxTotal int64
+		note                  string // Part of unzipUpload's note
+		extracted             []string
+	}{
+		{"no limits", 0, 0, "extracted 2 files", []string{"small.txt", "large.bin"}},
+		{"file size", 5000, 0, "larger than the", []string{"small.txt"}},
+		{"session total", 0, 5000, "total upload limit", []string{"small.txt"}},
+	} {
+		dir := newTestShare(t)
+		unzipUploads, maxFileSize, maxTotalUpload = true, test.maxFileSize, test.maxTotal
+		uploadedTotal.Store(0)
+
+		// A small archive that expands to much more, like a zip bomb
+		var archive bytes.Buffer
+		writer := zip.NewWriter(&archive)
+		for _, file := range []struct {
+			name string
+			size int
+		}{{"small.txt", 100}, {"large.bin", 1 << 20}} {
+			entry, _ := writer.Create(file.name)
+			entry.Write(make([]byte, file.size))
+		}
+		writer.Close()
+		writeTestFile(t, dir, "upload.zip", archive.Bytes())
+
+		if note := unzipUpload(filepath.Join(dir, "upload.zip")); !strings.Contains(note, test.note) {
+			t.Errorf("%s: note %q, want %q in it", test.name, note, test.note)
+		}
+		for _, name := range []string{"small.txt", "large.bin"} {
+			_, err := os.Stat(filepath.Join(dir, name))
+			if want := strings.Contains(strings.Join(test.extracted, " "), name); (err == nil) != want {
+				t.Errorf("%s: %s extracted %v, want %v", test.name, name, err == nil, want)
+			}
+		}
+		if test.maxTotal > 0 && uploadedTotal.Load() > test.maxTotal {
+			t.Errorf("%s: %d bytes counted, over the %d limit", test.name, uploadedTotal.Load(), test.maxTotal)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Upload progress stream: a client sending "Accept: application/x-ndjson" to /upload gets
+// newline-delimited JSON records while the request is processed instead of a single response.
+// The last record (type "done") carries the status and message the plain response would have.
+const (
+	uploadProgressType     = "application/x-ndjson"
+	uploadProgressInterval = 250 * time.Millisecond // Minimum time between "progress" records
+)
+
+// uploadProgressRecord is one line of the stream
+type uploadProgressRecord struct {
+	Type     string `json:"type"`               // "progress", "file" or "done"
+	Received int64  `json:"received,omitempty"` // progress: request body bytes received so far
+	Total    int64  `json:"total,omitempty"`    // progress: request body size, if the client sent it
+	File     string `json:"file,omitempty"`     // file: name of a saved file
+	Size     int64  `json:"size,omitempty"`     // file: its size in bytes
+	Status   int    `json:"status,omitempty"`   // done: HTTP status of the upload
+	Message  string `json:"message,omitempty"`  // done: the response text
+}
+
+// wantsUploadProgress reports whether the client asked for the progress stream
+func wantsUploadProgress(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), uploadProgressType)
+}
+
+// uploadProgressWriter sends the stream. The response status is always 200 once streaming has
+// started, so whatever the handler writes afterwards (including http.Error) is captured and
+// reported in the final "done" record.
+type uploadProgressWriter struct {
+	http.ResponseWriter
+	rc      *http.ResponseController
+	started bool
+	status  int
+	message bytes.Buffer
+}
+
+// startUploadProgress wraps the request body so receiving reports progress. Full duplex lets
+// HTTP/1.1 responses be written while the body is still being read.
+func startUploadProgress(w http.ResponseWriter, r *http.Request) *uploadProgressWriter {
+	pw := &uploadProgressWriter{ResponseWriter: w, rc: http.NewResponseController(w)}
+	_ = pw.rc.EnableFullDuplex() // HTTP/2 is always full duplex and reports ErrNotSupported
+	r.Body = &progressReader{body: r.Body, pw: pw, total: max(r.ContentLength, 0)}
+	return pw
+}
+
+func (pw *uploadProgressWriter) WriteHeader(code int) {
+	if pw.status == 0 {
+		pw.status = code
+	}
+}
+
+func (pw *uploadProgressWriter) Write(b []byte) (int, error) {
+	if pw.status == 0 {
+		pw.status = http.StatusOK
+	}
+	return pw.message.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (pw *uploadProgressWriter) Unwrap() http.ResponseWriter {
+	return pw.ResponseWriter
+}
+
+// send writes one record and flushes it to the client right away. The headers are only sent
+// with the first record, after the body has started arriving: writing them earlier would
+// keep a client waiting for "100 Continue" from ever sending the body.
+func (pw *uploadProgressWriter) send(record uploadProgressRecord) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	if !pw.started {
+		pw.started = true
+		header := pw.ResponseWriter.Header()
+		header.Del("X-Content-Type-Options") // Set by http.Error calls captured so far
+		header.Set("Content-Type", uploadProgressType)
+		header.Set("Cache-Control", "no-cache")
+		pw.ResponseWriter.WriteHeader(http.StatusOK)
+	}
+	pw.ResponseWriter.Write(append(line, '\n'))
+	pw.rc.Flush()
+}
+
+// fileSaved reports a saved file (no-op on a nil writer, i.e. without the progress stream)
+func (pw *uploadProgressWriter) fileSaved(name string, size int64) {
+	if pw != nil {
+		pw.send(uploadProgressRecord{Type: "file", File: name, Size: size})
+	}
+}
+
+// finish sends the final record with the captured status and response text
+func (pw *uploadProgressWriter) finish() {
+	status := pw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	pw.send(uploadProgressRecord{Type: "done", Status: status, Message: strings.TrimSpace(pw.message.String())})
+}
+
+// progressReader counts request body bytes and sends throttled "progress" records
+type progressReader struct {
+	body     io.ReadCloser
+	pw       *uploadProgressWriter
+	received int64
+	total    int64
+	lastSent time.Time
+	eof      bool
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.body.Read(p)
+	pr.received += int64(n)
+	if pr.eof {
+		return n, err
+	}
+	pr.eof = err == io.EOF
+	if pr.eof || time.Since(pr.lastSent) >= uploadProgressInterval {
+		pr.lastSent = time.Now()
+		pr.pw.send(uploadProgressRecord{Type: "progress", Received: pr.received, Total: pr.total})
+	}
+	return n, err
+}
+
+func (pr *progressReader) Close() error {
+	return pr.body.Close()
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+)
+
+// printShareURLs writes the entry points as "type=URL" lines for scripts that start pair
+// (via -print-urls). Types: upload, download (one per -f/-x file), downloads, zip, tar, code,
+// access-code and session-code (values, not URLs), metrics, notes and history, each only when enabled.
+func printShareURLs(w io.Writer, baseURL string) {
+	if !sandboxMode {
+		fmt.Fprintf(w, "upload=%s%s\n", baseURL, uploadPageLink())
+	}
+	if allowSingleFilePath != "" {
+		fmt.Fprintf(w, "download=%s/download/%s\n", baseURL, url.PathEscape(allowSingleFilePath))
+	}
+	if hasDownloadList() {
+		fmt.Fprintf(w, "downloads=%s%s\n", baseURL, withListToken("/downloads"))
+		fmt.Fprintf(w, "zip=%s%s\n", baseURL, withListToken("/download-zip"))
+		if zipPassword == "" {
+			fmt.Fprintf(w, "tar=%s%s\n", baseURL, withListToken("/download-tar"))
+		}
+		for _, p := range allowMultiFilePaths {
+			if alias := downloadAlias(p); alias != "" {
+				p = alias // The stable name is what scripts should keep
+			}
+			fmt.Fprintf(w, "download=%s/download/%s\n", baseURL, url.PathEscape(p))
+		}
+	}
+	fmt.Fprintf(w, "code=%s/code\n", baseURL)
+	fmt.Fprintf(w, "access-code=%s\n", accessCode)
+	fmt.Fprintf(w, "session-code=%s\n", sessionCode)
+	if metricsEnabled {
+		fmt.Fprintf(w, "metrics=%s/metrics\n", baseURL)
+	}
+	if notesEnabled {
+		fmt.Fprintf(w, "notes=%s/notes\n", baseURL)
+	}
+	if historyEnabled {
+		fmt.Fprintf(w, "history=%s/history\n", baseURL)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build information printed by -version. Release builds set it through the linker:
+//
+//	go build -ldflags "-X main.version=1.4.0 -X main.commit=$(git rev-parse --short HEAD)" -o pair .
+//
+// Without that, the module version (set by go install pair@version) and the VCS revision that
+// go build embeds are used instead.
+var (
+	version string
+	commit  string
+)
+
+// versionString describes this build, e.g. "pair 1.4.0 (commit 1ec9539, go1.25.6 linux/amd64)"
+func versionString() string {
+	v, c := version, commit
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v == "" && info.Main.Version != "(devel)" {
+			v = info.Main.Version
+		}
+		if c == "" {
+			revision, modified := "", false
+			for _, setting := range info.Settings {
+				switch setting.Key {
+				case "vcs.revision":
+					revision = setting.Value[:min(len(setting.Value), 12)]
+				case "vcs.modified":
+					modified = setting.Value == "true"
+				}
+			}
+			if revision != "" && modified {
+				revision += "-dirty" // Built from a tree with uncommitted changes
+			}
+			c = revision
+		}
+	}
+	return fmt.Sprintf("pair %s (commit %s, %s %s/%s)", cmp.Or(v, "devel"), cmp.Or(c, "unknown"), runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Uploads are only accepted between these times (via -upload-window); a zero time is unbounded
+var uploadWindowStart, uploadWindowEnd time.Time
+
+// windowTimeLayouts are the accepted -upload-window times, in local time unless RFC 3339
+var windowTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", "15:04"}
+
+// windowTimeFormat shows the window on the terminal and in the "Uploads closed" message
+const windowTimeFormat = "2006-01-02 15:04:05"
+
+// parseUploadWindow parses -upload-window: a duration from now ("2h"), or START/END where either
+// side may be empty (open-ended) and END may also be a duration after START. A time of day
+// alone ("18:00") means today; if END is only a time of day before START, it means the next day.
+func parseUploadWindow(spec string, now time.Time) (time.Time, time.Time, error) {
+	spec = strings.TrimSpace(spec)
+	if d, err := time.ParseDuration(spec); err == nil {
+		if d <= 0 {
+			return time.Time{}, time.Time{}, fmt.Errorf("duration %s is not positive", spec)
+		}
+		return now, now.Add(d), nil
+	}
+
+	startStr, endStr, found := strings.Cut(spec, "/")
+	if !found {
+		return time.Time{}, time.Time{}, fmt.Errorf("%q is neither a duration nor START/END", spec)
+	}
+	var start, end time.Time
+	var err error
+	startClock := false
+	if startStr = strings.TrimSpace(startStr); startStr != "" {
+		if start, startClock, err = parseWindowTime(startStr, now); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	if endStr = strings.TrimSpace(endStr); endStr != "" {
+		if d, durErr := time.ParseDuration(endStr); durErr == nil && d > 0 {
+			from := start
+			if from.IsZero() {
+				from = now
+			}
+			end = from.Add(d)
+		} else {
+			var endClock bool
+			if end, endClock, err = parseWindowTime(endStr, now); err != nil {
+				return time.Time{}, time.Time{}, err
+			}
+			if endClock && startClock && !end.After(start) {
+				end = end.AddDate(0, 0, 1) // e.g. 22:00/02:00 runs past midnight
+			}
+		}
+	}
+
+	if start.IsZero() && end.IsZero() {
+		return time.Time{}, time.Time{}, fmt.Errorf("give a start, an end or both")
+	}
+	if !start.IsZero() && !end.IsZero() && !end.After(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end %s is not after start %s", end.Format(windowTimeFormat), start.Format(windowTimeFormat))
+	}
+	if !end.IsZero() && !end.After(now) {
+		return time.Time{}, time.Time{}, fmt.Errorf("the window already ended at %s", end.Format(windowTimeFormat))
+	}
+	return start, end, nil
+}
+
+// parseWindowTime parses one side of START/END and reports whether it was a time of day only
+func parseWindowTime(s string, now time.Time) (time.Time, bool, error) {
+	for _, layout := range windowTimeLayouts {
+		t, err := time.ParseInLocation(layout, s, now.Location())
+		if err != nil {
+			continue
+		}
+		if layout == "15:04" {
+			year, month, day := now.Date()
+			return time.Date(year, month, day, t.Hour(), t.Minute(), 0, 0, now.Location()), true, nil
+		}
+		return t, false, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid time %q (use 15:04, 2006-01-02 15:04 or RFC 3339)", s)
+}
+
+// uploadWindowDescription describes the window for the startup banner
+func uploadWindowDescription() string {
+	switch {
+	case uploadWindowStart.IsZero():
+		return "until " + uploadWindowEnd.Format(windowTimeFormat)
+	case uploadWindowEnd.IsZero():
+		return "from " + uploadWindowStart.Format(windowTimeFormat)
+	default:
+		return fmt.Sprintf("from %s until %s", uploadWindowStart.Format(windowTimeFormat), uploadWindowEnd.Format(windowTimeFormat))
+	}
+}
+
+// uploadsClosed rejects the request with 403 outside the -upload-window
+func uploadsClosed(w http.ResponseWriter) bool {
+	now := time.Now()
+	if !uploadWindowStart.IsZero() && now.Before(uploadWindowStart) {
+		http.Error(w, fmt.Sprintf("Uploads closed: they open at %s", uploadWindowStart.Format(windowTimeFormat)), http.StatusForbidden)
+		return true
+	}
+	if !uploadWindowEnd.IsZero() && !now.Before(uploadWindowEnd) {
+		http.Error(w, fmt.Sprintf("Uploads closed since %s", uploadWindowEnd.Format(windowTimeFormat)), http.StatusForbidden)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket uploads on /ws: a client sends files one after another over a single connection.
+// Each file is a text message {"name":"photo.jpg","size":123} (size optional), then its data as
+// binary messages of any size, then the text message {"done":true}. The server acknowledges
+// every binary message with {"received":N} (bytes of this file so far) and answers each file
+// with {"saved":NAME,"bytes":N,"message":...}, or {"error":...} before closing the connection.
+// Data is written to disk before the next message is read, so a fast sender is held back by
+// TCP flow control; a client that waits for each acknowledgement also gets exact progress.
+
+// wsMaxMessageSize is the largest binary message (one chunk) accepted on /ws
+const wsMaxMessageSize = 16 << 20
+
+// errWSUnexpected means the client broke the /ws framing protocol
+var errWSUnexpected = errors.New("expected file data or {\"done\":true}")
+
+// wsUpgrader keeps gorilla's same-origin check: browsers may only connect from pair's own
+// pages, while custom clients (which send no Origin header) are accepted
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  64 << 10,
+	WriteBufferSize: 4 << 10,
+}
+
+// wsHeader is a control message from the client: a file header or the done marker
+type wsHeader struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	Done bool   `json:"done"`
+}
+
+// wsReply is a message from the server
+type wsReply struct {
+	Received int64  `json:"received,omitempty"`
+	Saved    string `json:"saved,omitempty"`
+	Bytes    int64  `json:"bytes,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// wsFileReader reads the binary messages of one file as a stream, up to the done marker
+type wsFileReader struct {
+	conn     *websocket.Conn
+	msg      io.Reader
+	received int64
+	done     bool
+}
+
+func (fr *wsFileReader) Read(p []byte) (int, error) {
+	for {
+		if fr.done {
+			return 0, io.EOF
+		}
+		if fr.msg == nil {
+			kind, msg, err := nextWSMessage(fr.conn)
+			if err != nil {
+				return 0, err
+			}
+			if kind == websocket.TextMessage {
+				var header wsHeader
+				if json.NewDecoder(msg).Decode(&header) != nil || !header.Done {
+					return 0, errWSUnexpected
+				}
+				fr.done = true
+				continue
+			}
+			fr.msg = msg
+		}
+
+		n, err := fr.msg.Read(p)
+		fr.received += int64(n)
+		if err == io.EOF {
+			// One chunk written: acknowledge it, which also reports progress
+			fr.msg = nil
+			if err := fr.conn.WriteJSON(wsReply{Received: fr.received}); err != nil {
+				return n, err
+			}
+			if n == 0 {
+				continue
+			}
+			return n, nil
+		}
+		return n, err
+	}
+}
+
+// nextWSMessage waits for the next message, for at most -upload-idle-timeout if set
+func nextWSMessage(conn *websocket.Conn) (int, io.Reader, error) {
+	if uploadIdleTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(uploadIdleTimeout))
+	}
+	return conn.NextReader()
+}
+
+// wsHandler accepts WebSocket uploads (see above) into the upload directory
+func wsHandler(w http.ResponseWriter, r *http.Request) {
+	if uploadsDisabled(w) {
+		return
+	}
+	if !uploadAuthorized(r) {
+		http.Error(w, "Upload token required", http.StatusUnauthorized)
+		return
+	}
+	if uploadCommand != "" {
+		http.Error(w, "WebSocket uploads are not available with -upload-cmd, use /upload or /put/", http.StatusNotImplemented)
+		return
+	}
+	saveDir, err := uploadDir(r.URL.Query().Get(inboxField))
+	if err != nil {
+		serverError(w, "Failed to create the upload folder", err)
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // The upgrader has answered already
+	}
+	defer conn.Close()
+	conn.SetReadLimit(wsMaxMessageSize)
+	conn.SetReadDeadline(time.Time{}) // Hijacked connections keep the server's deadlines otherwise
+
+	buf := make([]byte, transferBufSize)
+	for files := 0; ; files++ {
+		kind, msg, err := nextWSMessage(conn)
+		if err != nil {
+			return // Closed by the client between files, or gone
+		}
+		var header wsHeader
+		header.Size = -1
+		if kind != websocket.TextMessage || json.NewDecoder(msg).Decode(&header) != nil || header.Name == "" {
+			closeWS(conn, websocket.CloseProtocolError, `expected a file header {"name":...}`)
+			return
+		}
+		if singleUpload && files > 0 {
+			closeWS(conn, websocket.ClosePolicyViolation, "Only one file per upload is accepted")
+			return
+		}
+
+		reply, err := receiveWSFile(conn, r, saveDir, header, buf)
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return // The client went away mid-file; nothing is left behind
+			}
+			conn.WriteJSON(wsReply{Error: err.Error()})
+			closeWS(conn, websocket.CloseNormalClosure, "")
+			return
+		}
+		if err := conn.WriteJSON(reply); err != nil {
+			return
+		}
+	}
+}
+
+// closeWS sends a close frame with code and reason (best effort)
+func closeWS(conn *websocket.Conn, code int, reason string) {
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+}
+
+// receiveWSFile saves one file announced by header, like a PUT /put/ body. A file that is not
+// received completely (disconnect, limit or protocol error) is removed.
+func receiveWSFile(conn *websocket.Conn, r *http.Request, saveDir string, header wsHeader, buf []byte) (wsReply, error) {
+	fileName, err := sanitizeFileName(header.Name)
+	if err != nil {
+		return wsReply{}, fmt.Errorf("Invalid file name: %v", err)
+	}
+	if maxFileSize > 0 && header.Size > maxFileSize {
+		return wsReply{}, fmt.Errorf("File %s is larger than the %s limit", fileName, formatFileSize(maxFileSize))
+	}
+
+	// Reserve the announced size against -max-total; an unknown size is counted once received
+	reserved := max(header.Size, 0)
+	if !reserveUploadSpace(reserved) {
+		return wsReply{}, errUploadSpace()
+	}
+	kept := false
+	defer func() {
+		if !kept {
+			releaseUploadSpace(reserved)
+		}
+	}()
+
+	body, err := sniffUpload(&wsFileReader{conn: conn}, fileName)
+	if err != nil {
+		return wsReply{}, err
+	}
+	target, dstFile, err := createUploadTarget(filepath.Join(saveDir, fileName), header.Size)
+	if errors.Is(err, errUploadConflict) {
+		return wsReply{}, fmt.Errorf("File %s %v", fileName, err)
+	}
+	if err != nil {
+		return wsReply{}, serverFailure(fmt.Sprintf("Failed to create file %s", fileName), err)
+	}
+
+	if maxFileSize > 0 {
+		body = io.LimitReader(body, maxFileSize+1) // One byte more than allowed reveals an oversized file
+	}
+	if header.Size >= 0 {
+		body = io.LimitReader(body, header.Size+1) // One byte more than announced is enough to refuse it
+	} else if remaining := remainingUploadSpace(); remaining >= 0 {
+		body = io.LimitReader(body, remaining+1) // Stop reading once the file can't fit anyway
+	}
+	written, err := io.CopyBuffer(target.writer(dstFile), body, buf)
+	metricBytesUploaded.Add(written)
+	switch {
+	case err != nil:
+		removePartial(dstFile, target.writePath)
+		if isTimeout(err) {
+			return wsReply{}, fmt.Errorf("Upload aborted: no data received for %s", uploadIdleTimeout)
+		}
+		var diskErr *fs.PathError
+		if errors.As(err, &diskErr) {
+			return wsReply{}, serverFailure(fmt.Sprintf("Failed to write file %s", fileName), err)
+		}
+		return wsReply{}, err
+	case maxFileSize > 0 && written > maxFileSize:
+		removePartial(dstFile, target.writePath)
+		return wsReply{}, fmt.Errorf("File %s is larger than the %s limit", fileName, formatFileSize(maxFileSize))
+	case header.Size >= 0 && written != header.Size:
+		removePartial(dstFile, target.writePath)
+		return wsReply{}, fmt.Errorf("File %s announced %d bytes but sent %d", fileName, header.Size, written)
+	case header.Size < 0:
+		if !reserveUploadSpace(written) {
+			removePartial(dstFile, target.writePath)
+			return wsReply{}, errUploadSpace()
+		}
+		reserved = written
+	}
+	if err := closeSaved(dstFile); err != nil {
+		os.Remove(target.writePath)
+		return wsReply{}, serverFailure(fmt.Sprintf("Failed to write file %s", fileName), err)
+	}
+	savePath, err := target.finish()
+	if errors.Is(err, errUploadConflict) {
+		return wsReply{}, fmt.Errorf("File %s %v", fileName, err)
+	}
+	if err != nil {
+		return wsReply{}, serverFailure(fmt.Sprintf("Failed to save file %s", fileName), err)
+	}
+	if savePath == "" {
+		return wsReply{Bytes: written, Message: fmt.Sprintf("%s is already present with identical content, skipped", fileName)}, nil
+	}
+	saved := finishUpload(r, savePath, written)
+	if saved.duplicateOf != "" {
+		return wsReply{Bytes: written, Message: saved.message(saveDir, written)}, nil
+	}
+	kept = true
+	return wsReply{Saved: saved.name, Bytes: written, Message: saved.message(saveDir, written)}, nil
+}
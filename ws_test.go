@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWSAnnouncedSize(t *testing.T) {
+	dir := newTestShare(t)
+	server := httptest.NewServer(newTestMux())
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Announce one byte, then keep sending: the server must stop reading right after it
+	if err := conn.WriteJSON(wsHeader{Name: "small.txt", Size: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, make([]byte, 64*1024)); err != nil {
+		t.Fatal(err)
+	}
+	var reply wsReply
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(reply.Error, "announced 1 bytes but sent 2") {
+		t.Errorf("reply %+v, want the announced size refused after one extra byte", reply)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "small.txt")); err == nil {
+		t.Error("small.txt was saved")
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"github.com/yeka/zip"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// zipArchiveName is the file name offered for /download-zip
+const zipArchiveName = "pair-files.zip"
+
+// zipEncryptionMethods maps -zip-enc values to archive encryption schemes.
+// AES (WinZip AE-2) is strong but needs 7-Zip/WinRAR/macOS Archive Utility 10.15+;
+// ZipCrypto is understood by every archive tool (including Windows Explorer) but is
+// cryptographically broken and should only be used for compatibility.
+var zipEncryptionMethods = map[string]zip.EncryptionMethod{
+	"aes256":    zip.AES256Encryption,
+	"aes128":    zip.AES128Encryption,
+	"zipcrypto": zip.StandardEncryption,
+}
+
+// archiveFiles returns the files that go into an archive download (/download-zip or
+// /download-tar) and their total size. It answers the request itself and returns false if
+// there is nothing to send yet: no files, the share has closed, or a large archive that is
+// confirmed first so a stray tap on a phone does not start gigabytes.
+// ?from=N&to=M limits the archive to those positions (1-based, inclusive) of the list.
+func archiveFiles(w http.ResponseWriter, r *http.Request, archiveName string) ([]DownloadFileInfo, int64, bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is supported", http.StatusMethodNotAllowed)
+		return nil, 0, false
+	}
+
+	if shareExpired(w) {
+		return nil, 0, false
+	}
+	if listHidden(w, r) { // An archive of everything would give the catalog away
+		return nil, 0, false
+	}
+	if remoteStorage() {
+		http.Error(w, "Archives are not available for files in -s3-bucket, download them one by one", http.StatusNotImplemented)
+		return nil, 0, false
+	}
+	if sourceArchive != "" {
+		http.Error(w, "Archives are not available for the entries of -from-archive, download them one by one", http.StatusNotImplemented)
+		return nil, 0, false
+	}
+
+	downloadable := getDownloadableFiles()
+	query := r.URL.Query()
+	if query.Has("from") || query.Has("to") {
+		first, last, err := archiveRange(query.Get("from"), query.Get("to"), len(downloadable))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid file range: %v", err), http.StatusBadRequest)
+			return nil, 0, false
+		}
+		downloadable = downloadable[first-1 : last]
+	}
+
+	// Only files from the allow-list that currently exist go into the archive
+	var files []DownloadFileInfo
+	for _, file := range downloadable {
+		if file.Exists {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		http.Error(w, "No downloadable files available", http.StatusNotFound)
+		return nil, 0, false
+	}
+
+	totalSize := totalFileSize(files)
+	if zipWarnSize > 0 && totalSize > zipWarnSize && r.URL.Query().Get("go") != "1" {
+		serveDownloadConfirm(w, r, archiveName, totalSize)
+		return nil, 0, false
+	}
+	return files, totalSize, true
+}
+
+// archiveRange validates the from/to positions of a partial archive against a list of count
+// files; a missing bound means the start or end of the list
+func archiveRange(from, to string, count int) (int, int, error) {
+	first, last := 1, count
+	var err error
+	if from != "" {
+		if first, err = strconv.Atoi(from); err != nil {
+			return 0, 0, fmt.Errorf("from=%s is not a number", from)
+		}
+	}
+	if to != "" {
+		if last, err = strconv.Atoi(to); err != nil {
+			return 0, 0, fmt.Errorf("to=%s is not a number", to)
+		}
+	}
+	if first < 1 || last > count || first > last {
+		return 0, 0, fmt.Errorf("%d-%d is not within the %d files of the list (1-%d, from <= to)", first, last, count, count)
+	}
+	return first, last, nil
+}
+
+// downloadZipHandler streams all allowed files as one ZIP archive
+// (password-protected when -zip-pass is set)
+func downloadZipHandler(w http.ResponseWriter, r *http.Request) {
+	files, totalSize, ok := archiveFiles(w, r, zipArchiveName)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", contentDisposition(zipArchiveName))
+
+	zipWriter := zip.NewWriter(w)
+	buf := make([]byte, transferBufSize)
+	for _, file := range files {
+		if err := addFileToZip(zipWriter, file, buf); err != nil {
+			// Headers are already sent, the truncated archive is the only signal left
+			fmt.Printf("Failed to add %s to ZIP: %v\n", file.RelPath, err)
+			return
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		fmt.Printf("Failed to finish ZIP: %v\n", err)
+		return
+	}
+	metricDownloads.Add(1)
+	recordTransfer(r, "download", zipArchiveName, totalSize)
+	countDownload()
+}
+
+// addFileToZip writes one allowed file into the archive under its relative path
+func addFileToZip(zipWriter *zip.Writer, file DownloadFileInfo, buf []byte) error {
+	realPath, err := resolveServedPath(file.AbsPath)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(realPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	stat, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := zip.FileInfoHeader(stat)
+	if err != nil {
+		return err
+	}
+	header.Name = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(file.RelPath)), "/")
+	header.Method = zip.Deflate
+	if zipPassword != "" {
+		header.SetPassword(zipPassword)
+		header.SetEncryptionMethod(zipEncryptionMethods[zipEncryptionName])
+	}
+
+	dst, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	n, err := io.CopyBuffer(dst, src, buf)
+	metricBytesDownloaded.Add(n)
+	return err
+}